@@ -0,0 +1,227 @@
+// Package anchor 评论锚点：记录引用文本在笔记中的位置（按 Unicode 字符计数的偏移）和前后文，
+// 笔记修改后据此重新定位
+package anchor
+
+// ContextSize 锚点前后保存的上下文字符数
+const ContextSize = 32
+
+const (
+	// shortText 较短的引用（如单个词）在别处出现的可能性大，重新定位时要求前后文至少部分吻合
+	shortText  = 16
+	minContext = 4
+	// maxFuzzyPattern 超过该长度的引用不做模糊匹配
+	maxFuzzyPattern = 256
+	// maxFuzzyWindow 模糊匹配的搜索范围（字符）
+	maxFuzzyWindow = 20000
+)
+
+// Anchor 引用文本在笔记中的位置 [Start, End) 及前后文
+type Anchor struct {
+	Start  int
+	End    int
+	Prefix string
+	Suffix string
+}
+
+// New 根据位置生成锚点，前后文取自 content
+func New(content []rune, start, end int) Anchor {
+	return Anchor{
+		Start:  start,
+		End:    end,
+		Prefix: string(content[max(0, start-ContextSize):start]),
+		Suffix: string(content[end:min(len(content), end+ContextSize)]),
+	}
+}
+
+// Locate 创建评论时定位引用文本。hint 为客户端提供的起始位置，该处正好是 quote 时直接使用，
+// 否则选择离 hint 最近的出现位置；hint < 0 时取第一处
+func Locate(content, quote string, hint int) (Anchor, bool) {
+	c, q := []rune(content), []rune(quote)
+	if len(q) == 0 {
+		return Anchor{}, false
+	}
+	if hint >= 0 && hint+len(q) <= len(c) && equal(c[hint:hint+len(q)], q) {
+		return New(c, hint, hint+len(q)), true
+	}
+	best := -1
+	for _, i := range occurrences(c, q) {
+		if best < 0 || abs(i-hint) < abs(best-hint) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return Anchor{}, false
+	}
+	return New(c, best, best+len(q)), true
+}
+
+// Reanchor 笔记内容从 old 改为 new 后重新定位锚点。a 必须是相对 old 的有效位置。
+// 引用的文本被删除（或改动超过一半且找不到相近的文本）时返回 false
+func Reanchor(old, new []rune, a Anchor) (Anchor, bool) {
+	if a.Start < 0 || a.End > len(old) || a.Start >= a.End {
+		return Anchor{}, false
+	}
+	// 修改区域：old[p:oldEnd] 替换为 new[p:newEnd]
+	p := commonPrefix(old, new)
+	s := commonSuffix(old[p:], new[p:])
+	oldEnd, newEnd := len(old)-s, len(new)-s
+	delta := len(new) - len(old)
+
+	switch {
+	case a.End <= p:
+		// 修改在锚点之后
+		return New(new, a.Start, a.End), true
+	case a.Start >= oldEnd:
+		// 修改在锚点之前
+		return New(new, a.Start+delta, a.End+delta), true
+	case a.Start <= p && oldEnd <= a.End:
+		// 修改在锚点内部，例如修正错别字；保留的字符不少于一半时沿用
+		kept := (a.End - a.Start) - (oldEnd - p)
+		if kept*2 >= a.End-a.Start && a.End+delta > a.Start {
+			return New(new, a.Start, a.End+delta), true
+		}
+	}
+
+	// 修改与锚点交叉，或一次保存中有多处修改：按原文和上下文在新内容中查找
+	expected := a.Start
+	if a.Start > p {
+		expected = min(max(p, a.Start+delta), newEnd)
+	}
+	return Find(new, old[a.Start:a.End], a.Prefix, a.Suffix, expected, true)
+}
+
+// Find 在 content 中查找 text，有多处时选择前后文最吻合、其次离 expected 最近的位置。
+// fuzzy 为 true 时找不到原文会在 expected 附近查找相近的文本
+func Find(content, text []rune, prefix, suffix string, expected int, fuzzy bool) (Anchor, bool) {
+	if len(text) == 0 {
+		return Anchor{}, false
+	}
+	pre, suf := []rune(prefix), []rune(suffix)
+	best, bestScore := -1, -1
+	required := 0
+	if len(text) < shortText {
+		required = min(minContext, len(pre)+len(suf))
+	}
+	for _, i := range occurrences(content, text) {
+		score := commonSuffix(pre, content[max(0, i-len(pre)):i]) +
+			commonPrefix(suf, content[i+len(text):])
+		if score > bestScore || (score == bestScore && abs(i-expected) < abs(best-expected)) {
+			best, bestScore = i, score
+		}
+	}
+	if best >= 0 {
+		// 原文仍在但前后文都已改变，多半是删除后别处的相同文字
+		if bestScore < required {
+			return Anchor{}, false
+		}
+		return New(content, best, best+len(text)), true
+	}
+	if !fuzzy || len(text) > maxFuzzyPattern {
+		return Anchor{}, false
+	}
+
+	lo := max(0, expected-maxFuzzyWindow/2)
+	hi := min(len(content), lo+maxFuzzyWindow)
+	window := content[lo:hi]
+	maxDist := len(text) / 3
+	end, dist := approximateEnd(text, window, expected-lo+len(text), false)
+	if end < 0 || dist > maxDist {
+		return Anchor{}, false
+	}
+	// 从结束位置反向匹配确定起点
+	from := max(0, end-2*len(text))
+	revEnd, _ := approximateEnd(reversed(text), reversed(window[from:end]), len(text), true)
+	if revEnd <= 0 {
+		return Anchor{}, false
+	}
+	start := lo + end - revEnd
+	return New(content, start, lo+end), true
+}
+
+// approximateEnd Sellers 算法：text 的任意子串与 pattern 的最小编辑距离，
+// 返回该子串的结束位置（多处相同时取离 near 最近的）和距离；没有结果时返回 -1。
+// anchored 为 true 时子串必须从 text 开头开始
+func approximateEnd(pattern, text []rune, near int, anchored bool) (int, int) {
+	m := len(pattern)
+	col := make([]int, m+1)
+	for i := range col {
+		col[i] = i
+	}
+	bestEnd, bestDist := -1, m+1
+	if col[m] < bestDist {
+		bestEnd, bestDist = 0, col[m]
+	}
+	for j, r := range text {
+		diag := col[0]
+		// 不限定起点时 col[0] 恒为 0，匹配可以从任意位置开始
+		if anchored {
+			col[0] = j + 1
+		}
+		for i := 1; i <= m; i++ {
+			cost := 1
+			if pattern[i-1] == r {
+				cost = 0
+			}
+			next := min(col[i]+1, col[i-1]+1, diag+cost)
+			diag, col[i] = col[i], next
+		}
+		end := j + 1
+		if col[m] < bestDist || (col[m] == bestDist && abs(end-near) < abs(bestEnd-near)) {
+			bestEnd, bestDist = end, col[m]
+		}
+	}
+	return bestEnd, bestDist
+}
+
+func occurrences(s, sub []rune) []int {
+	var result []int
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if s[i] == sub[0] && equal(s[i:i+len(sub)], sub) {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+func equal(a, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func commonPrefix(a, b []rune) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+func commonSuffix(a, b []rune) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+	return n
+}
+
+func reversed(s []rune) []rune {
+	r := make([]rune, len(s))
+	for i, c := range s {
+		r[len(s)-1-i] = c
+	}
+	return r
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,220 @@
+package anchor
+
+import (
+	"strings"
+	"testing"
+)
+
+// quoted 锚点在 content 中对应的文本，并检查前后文与位置一致
+func quoted(t *testing.T, content string, a Anchor) string {
+	t.Helper()
+	c := []rune(content)
+	if a.Start < 0 || a.End > len(c) || a.Start >= a.End {
+		t.Fatalf("invalid anchor %+v for %d runes", a, len(c))
+	}
+	if want := New(c, a.Start, a.End); a != want {
+		t.Fatalf("anchor context %+v, want %+v", a, want)
+	}
+	return string(c[a.Start:a.End])
+}
+
+func TestLocate(t *testing.T) {
+	content := "苹果、香蕉、苹果、橘子、苹果"
+	tests := []struct {
+		name      string
+		quote     string
+		hint      int
+		wantStart int
+		wantOK    bool
+	}{
+		{"hint 正好是引用", "苹果", 6, 6, true},
+		{"离 hint 最近的一处", "苹果", 10, 12, true},
+		{"hint < 0 取第一处", "苹果", -1, 0, true},
+		{"hint 超出范围", "橘子", 100, 9, true},
+		{"按字符而不是字节计数", "香蕉", -1, 3, true},
+		{"找不到", "西瓜", 0, 0, false},
+		{"空引用", "", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, ok := Locate(content, tt.quote, tt.hint)
+			if ok != tt.wantOK {
+				t.Fatalf("Locate ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if a.Start != tt.wantStart || quoted(t, content, a) != tt.quote {
+				t.Fatalf("Locate = %+v, want start %d", a, tt.wantStart)
+			}
+		})
+	}
+}
+
+func TestReanchor(t *testing.T) {
+	filler := strings.Repeat("无关的内容。", 10)
+	tests := []struct {
+		name       string
+		old, new   string
+		quote      string
+		occurrence int    // quote 在 old 中的第几处（从 0 开始）
+		want       string // 重新定位后的文本，为空表示成为孤立评论
+		wantStart  int    // want 在 new 中的第几处
+	}{
+		{
+			name:  "修改在锚点之后",
+			old:   "第一段：重要的结论。第二段：细节。",
+			new:   "第一段：重要的结论。第二段：更多的细节。",
+			quote: "重要的结论",
+			want:  "重要的结论",
+		},
+		{
+			name:  "修改在锚点之前",
+			old:   "开头。这里是被评论的句子。结尾。",
+			new:   "新加的一大段开头文字。这里是被评论的句子。结尾。",
+			quote: "被评论的句子",
+			want:  "被评论的句子",
+		},
+		{
+			name:  "修改错别字",
+			old:   "The quick brown fxo jumps over the lazy dog.",
+			new:   "The quick brown fox jumps over the lazy dog.",
+			quote: "quick brown fxo jumps",
+			want:  "quick brown fox jumps",
+		},
+		{
+			name:  "引用的文本被删除",
+			old:   "保留的内容。这句话会被删除。保留的内容二。",
+			new:   "保留的内容。保留的内容二。",
+			quote: "这句话会被删除",
+		},
+		{
+			name:  "引用的大部分被改写",
+			old:   "会议决定：下周一发布新版本。",
+			new:   "会议决定：推迟到月底再讨论。",
+			quote: "下周一发布新版本",
+		},
+		{
+			name:  "删除后别处的相同短词不算",
+			old:   "我们讨论了预算问题。" + filler + "另外，关于预算的分配。",
+			new:   "我们讨论了。" + filler + "另外，关于预算的分配。",
+			quote: "预算",
+		},
+		{
+			name:  "段落被移动",
+			old:   "第一段内容。\n需要评论的一段比较长的文字，包含足够的信息。\n第三段内容。",
+			new:   "需要评论的一段比较长的文字，包含足够的信息。\n第一段内容。\n第三段内容。",
+			quote: "需要评论的一段比较长的文字",
+			want:  "需要评论的一段比较长的文字",
+		},
+		{
+			name:       "多处相同文字按前后文选择",
+			old:        "甲说：好的，没问题。乙说：好的，明天见。丙说：好的，再说吧。",
+			new:        "丙说：好的，再说吧。甲说：好的，没问题。乙说：好的，明天见。",
+			quote:      "好的",
+			occurrence: 1, // 乙说的
+			want:       "好的",
+			wantStart:  2,
+		},
+		{
+			name:  "修改与锚点交叉时模糊匹配",
+			old:   filler + "The deployment finished at noon without errors." + filler,
+			new:   filler + "A deployment finished at noon without any errors." + filler,
+			quote: "The deployment finished at noon without errors",
+			want:  "deployment finished at noon without any errors",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := []rune(tt.old)
+			start := nthIndex(old, []rune(tt.quote), tt.occurrence)
+			if start < 0 {
+				t.Fatalf("quote %q not in old content", tt.quote)
+			}
+			a := New(old, start, start+len([]rune(tt.quote)))
+
+			got, ok := Reanchor(old, []rune(tt.new), a)
+			if tt.want == "" {
+				if ok {
+					t.Fatalf("Reanchor = %+v (%q), want orphaned", got, quoted(t, tt.new, got))
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("Reanchor orphaned, want %q", tt.want)
+			}
+			if text := quoted(t, tt.new, got); !strings.Contains(text, tt.want) || len([]rune(text)) > len([]rune(tt.want))+1 {
+				t.Fatalf("Reanchor = %q, want %q", text, tt.want)
+			}
+			if want := nthIndex([]rune(tt.new), []rune(tt.want), tt.wantStart); abs(got.Start-want) > 1 {
+				t.Fatalf("Reanchor start %d, want %d", got.Start, want)
+			}
+		})
+	}
+
+	if _, ok := Reanchor([]rune("abc"), []rune("abcd"), Anchor{Start: 2, End: 5}); ok {
+		t.Error("Reanchor accepted an anchor outside the old content")
+	}
+}
+
+// 孤立评论的原文恢复后重新关联（handlers 中用不做模糊匹配的 Find）
+func TestReattach(t *testing.T) {
+	original := "项目计划：第一阶段完成需求分析，第二阶段开始开发。"
+	quote := []rune("第一阶段完成需求分析")
+	c := []rune(original)
+	start := nthIndex(c, quote, 0)
+	a := New(c, start, start+len(quote))
+
+	removed := "项目计划：第二阶段开始开发。"
+	if _, ok := Reanchor(c, []rune(removed), a); ok {
+		t.Fatal("anchor not orphaned after its text was removed")
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		quote   []rune
+		want    bool
+	}{
+		{"原文恢复", original, quote, true},
+		{"原文在前后文中恢复", "项目计划：第一阶段完成需求分析，第二阶段开始开发。附录。", quote, true},
+		{"较短的引用出现在别处、前后文不同", "备注：第一阶段完成需求分析。" + removed, quote, false},
+		{"只恢复了一部分", "项目计划：第一阶段完成，第二阶段开始开发。", quote, false},
+		{"短引用没有吻合的前后文", "完全不同的内容，提到需求。", []rune("需求"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Find([]rune(tt.content), tt.quote, a.Prefix, a.Suffix, a.Start, false)
+			if ok != tt.want {
+				t.Fatalf("Find ok = %v, want %v (%+v)", ok, tt.want, got)
+			}
+			if ok && quoted(t, tt.content, got) != string(tt.quote) {
+				t.Fatalf("Find = %q", quoted(t, tt.content, got))
+			}
+		})
+	}
+
+	// 较长的引用不要求前后文吻合
+	long := []rune("第一阶段完成需求分析，第二阶段开始开发")
+	la := New(c, start, start+len(long))
+	moved := "备注。" + string(long) + "。"
+	if got, ok := Find([]rune(moved), long, la.Prefix, la.Suffix, la.Start, false); !ok || quoted(t, moved, got) != string(long) {
+		t.Fatalf("Find long quote = %+v, %v", got, ok)
+	}
+
+	// 短引用在原来的前后文中恢复时重新关联
+	short := []rune("需求")
+	i := nthIndex(c, short, 0)
+	sa := New(c, i, i+len(short))
+	if got, ok := Find(c, short, sa.Prefix, sa.Suffix, sa.Start, false); !ok || got.Start != i {
+		t.Fatalf("Find short quote = %+v, %v; want start %d", got, ok, i)
+	}
+}
+
+func nthIndex(s, sub []rune, n int) int {
+	found := occurrences(s, sub)
+	if n >= len(found) {
+		return -1
+	}
+	return found[n]
+}
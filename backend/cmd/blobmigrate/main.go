@@ -0,0 +1,94 @@
+// blobmigrate 在两个存储后端之间复制附件文件，对象 key 保持不变，
+// 因此迁移后只需修改 GONOTE_STORAGE 等环境变量即可切换，无需改动数据库。
+//
+//	go run ./cmd/blobmigrate -from local -to s3
+//	go run ./cmd/blobmigrate -from s3 -to local -dir ./uploads-backup
+//
+// S3 连接参数读取 GONOTE_S3_* 环境变量，-from-bucket / -to-bucket 可分别覆盖桶名。
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"gonote/storage"
+	"log"
+	"mime"
+	"path"
+)
+
+func main() {
+	env := storage.ConfigFromEnv()
+
+	from := flag.String("from", "local", "源存储：local 或 s3")
+	to := flag.String("to", "s3", "目标存储：local 或 s3")
+	dir := flag.String("dir", env.Dir, "本地存储目录")
+	fromBucket := flag.String("from-bucket", env.Bucket, "源 S3 桶")
+	toBucket := flag.String("to-bucket", env.Bucket, "目标 S3 桶")
+	overwrite := flag.Bool("overwrite", false, "覆盖目标中大小相同的已有对象")
+	dryRun := flag.Bool("dry-run", false, "只列出需要复制的对象")
+	flag.Parse()
+
+	if *from == *to && (*from != "s3" || *fromBucket == *toBucket) {
+		log.Fatal("source and destination are the same")
+	}
+
+	srcCfg, dstCfg := env, env
+	srcCfg.Driver, srcCfg.Dir, srcCfg.Bucket = *from, *dir, *fromBucket
+	dstCfg.Driver, dstCfg.Dir, dstCfg.Bucket = *to, *dir, *toBucket
+
+	src, err := storage.Open(srcCfg)
+	if err != nil {
+		log.Fatal("open source: ", err)
+	}
+	dst, err := storage.Open(dstCfg)
+	if err != nil {
+		log.Fatal("open destination: ", err)
+	}
+
+	ctx := context.Background()
+
+	// 目标中已有且大小一致的对象跳过，中断后可以重新执行
+	existing := map[string]int64{}
+	if !*overwrite {
+		err := dst.Walk(ctx, func(key string, size int64) error {
+			existing[key] = size
+			return nil
+		})
+		if err != nil {
+			log.Fatal("list destination: ", err)
+		}
+	}
+
+	var copied, skipped, failed int
+	err = src.Walk(ctx, func(key string, size int64) error {
+		if s, ok := existing[key]; ok && s == size {
+			skipped++
+			return nil
+		}
+		if *dryRun {
+			log.Printf("would copy %s (%d bytes)", key, size)
+			copied++
+			return nil
+		}
+		n, err := storage.Copy(ctx, dst, src, key, mime.TypeByExtension(path.Ext(key)))
+		if err == nil && n != size {
+			err = errors.New("size mismatch")
+		}
+		if err != nil {
+			log.Printf("ERROR: copy %s: %v", key, err)
+			failed++
+			return nil
+		}
+		copied++
+		return nil
+	})
+	if err != nil {
+		log.Fatal("list source: ", err)
+	}
+
+	log.Printf("done: %d copied, %d skipped, %d failed", copied, skipped, failed)
+	if failed > 0 {
+		log.Fatal("some objects failed to copy")
+	}
+}
@@ -25,14 +25,62 @@ func Connect() {
 		&models.Note{},
 		&models.Folder{},
 		&models.Event{},
+		&models.EventOverride{},
+		&models.EventReminder{},
 		&models.Collaborator{},
 		&models.Family{},
 		&models.FamilyMember{},
 		&models.Attachment{},
 		&models.Comment{},
+		&models.Reaction{},
+		&models.NoteUserState{},
+		&models.AuditLog{},
+		&models.ImportJob{},
+		&models.Blob{},
+		&models.Thumbnail{},
+		&models.UploadSession{},
+		&models.BlobText{},
+		&models.Notification{},
+		&models.NoteMention{},
+		&models.LegacyBlob{},
+		&models.Migration{},
 	)
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
+	if err := migrateJournalIndex(); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+	if err := migrateEventTZID(); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
 	log.Println("Database migration completed")
 }
+
+// migrateEventTZID 没有保存时区的旧事件使用创建者的时区，之前提醒就是按它计算的
+func migrateEventTZID() error {
+	return DB.Exec(`UPDATE events SET tzid = COALESCE(
+		(SELECT NULLIF(timezone, '') FROM users WHERE users.id = events.user_id), 'Asia/Shanghai')
+		WHERE (tzid IS NULL OR tzid = '') AND is_system = ?`, false).Error
+}
+
+// migrateJournalIndex 每个用户的个人日记和每个家庭的日记每天只有一篇。
+// family_id 可能为 NULL 或空字符串，用表达式索引；回收站中的日记不占用日期。
+// 建索引前把重复的日记中较新的改为普通笔记
+func migrateJournalIndex() error {
+	err := DB.Exec(`UPDATE notes SET journal_date = NULL
+		WHERE journal_date IS NOT NULL AND deleted_at IS NULL AND EXISTS (
+			SELECT 1 FROM notes AS older
+			WHERE older.journal_date = notes.journal_date AND older.deleted_at IS NULL
+			AND COALESCE(older.family_id, '') = COALESCE(notes.family_id, '')
+			AND (COALESCE(notes.family_id, '') <> '' OR older.user_id = notes.user_id)
+			AND (older.created_at < notes.created_at OR (older.created_at = notes.created_at AND older.id < notes.id)))`).Error
+	if err != nil {
+		return err
+	}
+	return DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_notes_journal ON notes (
+		journal_date,
+		COALESCE(family_id, ''),
+		(CASE WHEN COALESCE(family_id, '') = '' THEN user_id ELSE '' END)
+	) WHERE journal_date IS NOT NULL AND deleted_at IS NULL`).Error
+}
@@ -0,0 +1,160 @@
+// Package export 将笔记导出为 Markdown 压缩包、独立 HTML 和 PDF
+package export
+
+import (
+	"archive/zip"
+	"fmt"
+	"gonote/models"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Item 待导出的笔记及其在导出包中的目录（如 "personal/工作"）
+type Item struct {
+	Note models.Note
+	Dir  string
+	// Usernames 能被 @ 高亮的用户名（render.SortUsernames 顺序），HTML 导出使用
+	Usernames []string
+}
+
+// Opener 打开附件内容
+type Opener func(a models.Attachment) (io.ReadCloser, error)
+
+var unsafeNameChars = regexp.MustCompile(`[/\\:*?"<>|\x00-\x1f]+`)
+
+// SafeName 将标题等转换为可用作文件名的字符串
+func SafeName(name string) string {
+	name = strings.TrimSpace(unsafeNameChars.ReplaceAllString(name, "_"))
+	name = strings.Trim(name, ".")
+	if name == "" {
+		return "untitled"
+	}
+	if r := []rune(name); len(r) > 80 {
+		name = string(r[:80])
+	}
+	return name
+}
+
+var hashtag = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_-]+)`)
+
+// Tags 提取正文中的 #标签（"# 标题" 这种带空格的不算）
+func Tags(content string) []string {
+	seen := map[string]bool{}
+	tags := make([]string, 0)
+	for _, m := range hashtag.FindAllStringSubmatch(content, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			tags = append(tags, m[1])
+		}
+	}
+	return tags
+}
+
+// FrontMatter 生成笔记的 YAML front matter
+func FrontMatter(n models.Note) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "id: %s\n", strconv.Quote(n.ID))
+	fmt.Fprintf(&b, "title: %s\n", strconv.Quote(n.Title))
+	tags := Tags(n.Content)
+	quoted := make([]string, len(tags))
+	for i, t := range tags {
+		quoted[i] = strconv.Quote(t)
+	}
+	fmt.Fprintf(&b, "tags: [%s]\n", strings.Join(quoted, ", "))
+	fmt.Fprintf(&b, "created: %s\n", n.CreatedAt.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "updated: %s\n", n.UpdatedAt.UTC().Format(time.RFC3339))
+	if n.FamilyID != nil && *n.FamilyID != "" {
+		fmt.Fprintf(&b, "familyId: %s\n", strconv.Quote(*n.FamilyID))
+	}
+	if n.JournalDate != nil {
+		fmt.Fprintf(&b, "journalDate: %s\n", *n.JournalDate)
+	}
+	if n.Archived {
+		b.WriteString("archived: true\n")
+	}
+	b.WriteString("---\n\n")
+	return b.String()
+}
+
+// uniquePath 同一目录下重名时追加序号
+type uniquePath map[string]bool
+
+func (u uniquePath) next(dir, base, ext string) string {
+	p := path.Join(dir, base+ext)
+	for i := 2; u[p]; i++ {
+		p = path.Join(dir, fmt.Sprintf("%s (%d)%s", base, i, ext))
+	}
+	u[p] = true
+	return p
+}
+
+// MissingFile 压缩包中列出无法读取的附件的文件名
+const MissingFile = "MISSING.txt"
+
+// WriteMarkdownZip 导出为 Markdown 压缩包：保留目录结构，附件放在
+// attachments/<笔记ID>/ 下，正文中的附件地址改写为相对路径。
+// 无法打开的附件跳过，列在 MissingFile 中，正文保留原地址
+func WriteMarkdownZip(w io.Writer, items []Item, open Opener) error {
+	zw := zip.NewWriter(w)
+	used := uniquePath{}
+	var missing []string
+
+	for _, item := range items {
+		n := item.Note
+		notePath := used.next(item.Dir, SafeName(n.Title), ".md")
+		depth := strings.Count(notePath, "/")
+		content := n.Content
+
+		attachUsed := uniquePath{}
+		for _, a := range n.Attachments {
+			ext := path.Ext(a.Name)
+			r, err := open(a)
+			if err != nil {
+				missing = append(missing, fmt.Sprintf("%s - %s: %v", notePath, a.Name, err))
+				continue
+			}
+			rel := attachUsed.next(path.Join("attachments", n.ID), SafeName(strings.TrimSuffix(a.Name, ext)), ext)
+			if err := copyToZip(zw, rel, a, r); err != nil {
+				return err
+			}
+			if a.URL != "" {
+				content = strings.ReplaceAll(content, a.URL, strings.Repeat("../", depth)+rel)
+			}
+		}
+
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: notePath, Method: zip.Deflate, Modified: n.UpdatedAt})
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, FrontMatter(n)+content); err != nil {
+			return err
+		}
+	}
+
+	if len(missing) > 0 {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: MissingFile, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, "以下附件无法读取，未包含在导出中：\n\n"+strings.Join(missing, "\n")+"\n"); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func copyToZip(zw *zip.Writer, name string, a models.Attachment, r io.ReadCloser) error {
+	defer r.Close()
+
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: a.CreatedAt})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	return err
+}
@@ -0,0 +1,92 @@
+package export
+
+import (
+	"encoding/base64"
+	"fmt"
+	"gonote/render"
+	"html"
+	"io"
+	"strings"
+)
+
+const htmlHead = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+body { max-width: 860px; margin: 2rem auto; padding: 0 1rem; font-family: -apple-system, "PingFang SC", "Microsoft YaHei", sans-serif; line-height: 1.6; color: #37352f; }
+article { border-top: 1px solid #e9e9e7; padding-top: 1.5rem; margin-top: 2rem; }
+.meta { color: #9b9a97; font-size: 0.85em; }
+table { border-collapse: collapse; } th, td { border: 1px solid #e9e9e7; padding: 4px 8px; }
+pre { padding: 0.75rem; overflow-x: auto; border-radius: 4px; }
+img { max-width: 100%%; }
+.mention { background: #eef3f8; color: #0b6e99; border-radius: 3px; padding: 0 2px; }
+</style>
+</head>
+<body>
+`
+
+// WriteHTML 导出为单个独立 HTML 文件，附件以 data URI 内嵌；无法读取的附件在附件列表中注明
+func WriteHTML(w io.Writer, title string, items []Item, open Opener) error {
+	if _, err := fmt.Fprintf(w, htmlHead, html.EscapeString(title)); err != nil {
+		return err
+	}
+
+	// 目录
+	fmt.Fprintf(w, "<h1>%s</h1>\n<ul>\n", html.EscapeString(title))
+	for _, item := range items {
+		fmt.Fprintf(w, "<li><a href=\"#%s\">%s</a></li>\n", html.EscapeString(item.Note.ID), html.EscapeString(item.Note.Title))
+	}
+	io.WriteString(w, "</ul>\n")
+
+	for _, item := range items {
+		n := item.Note
+		body, err := render.Markdown(n.Content, item.Usernames)
+		if err != nil {
+			return err
+		}
+
+		var links strings.Builder
+		for _, a := range n.Attachments {
+			uri, err := dataURI(a.Type, func() (io.ReadCloser, error) { return open(a) })
+			if err != nil {
+				// 无法读取的附件不中断导出，正文保留原地址
+				fmt.Fprintf(&links, "<li>%s（无法读取，未包含在导出中）</li>\n", html.EscapeString(a.Name))
+				continue
+			}
+			if a.URL != "" {
+				body = strings.ReplaceAll(body, a.URL, uri)
+			}
+			fmt.Fprintf(&links, "<li><a download=\"%s\" href=\"%s\">%s</a></li>\n", html.EscapeString(a.Name), uri, html.EscapeString(a.Name))
+		}
+
+		fmt.Fprintf(w, "<article id=\"%s\">\n<h1>%s</h1>\n", html.EscapeString(n.ID), html.EscapeString(n.Title))
+		fmt.Fprintf(w, "<p class=\"meta\">%s · 创建于 %s · 更新于 %s</p>\n",
+			html.EscapeString(item.Dir), n.CreatedAt.Format("2006-01-02 15:04"), n.UpdatedAt.Format("2006-01-02 15:04"))
+		io.WriteString(w, body)
+		if links.Len() > 0 {
+			fmt.Fprintf(w, "<h3>附件</h3>\n<ul>\n%s</ul>\n", links.String())
+		}
+		io.WriteString(w, "</article>\n")
+	}
+
+	_, err := io.WriteString(w, "</body>\n</html>\n")
+	return err
+}
+
+func dataURI(mimeType string, open func() (io.ReadCloser, error)) (string, error) {
+	r, err := open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,441 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+)
+
+// PDFFontEnv 指定一个含中文字形的 TTF 字体文件（例如 Droid Sans Fallback），
+// 未设置时使用内置的 Go 字体，只覆盖拉丁、希腊和西里尔字母；
+// 内容含中日韩文字时改用 systemCJKFonts 中找到的第一个系统字体
+const PDFFontEnv = "GONOTE_PDF_FONT"
+
+// ErrNoCJKFont 内容含中日韩文字，但没有可用的字体
+var ErrNoCJKFont = errors.New("PDF 导出中文需要支持中文的 TTF 字体，请通过 " + PDFFontEnv + " 指定字体文件")
+
+// systemCJKFonts 常见系统中带中文字形的 TrueType 字体（fpdf 不支持 TTC 和 CFF 格式的 OTF）
+var systemCJKFonts = []string{
+	"/usr/share/fonts/truetype/droid/DroidSansFallbackFull.ttf",          // Debian / Ubuntu: fonts-droid-fallback
+	"/usr/share/fonts/google-droid-sans-fonts/DroidSansFallbackFull.ttf", // Fedora: google-droid-sans-fonts
+	"/usr/share/fonts/droid/DroidSansFallbackFull.ttf",                   // Arch: ttf-droid
+	"/usr/share/fonts/truetype/arphic-gbsn00lp/gbsn00lp.ttf",             // Debian: fonts-arphic-gbsn00lp
+	"/Library/Fonts/Arial Unicode.ttf",                                   // macOS
+	"/System/Library/Fonts/Supplemental/Arial Unicode.ttf",
+	`C:\Windows\Fonts\simhei.ttf`,
+}
+
+// pdfFont 由 LoadPDFFont 确定的中文字体，只在启动时加载一次
+var pdfFont struct {
+	once       sync.Once
+	data       []byte
+	path       string
+	configured bool // 来自 PDFFontEnv，所有内容都使用该字体
+	err        error
+}
+
+// LoadPDFFont 加载 PDF 导出中文使用的字体并返回其路径：PDFFontEnv 指定的文件，未设置时为 systemCJKFonts 中的第一个。
+// 应在启动时调用：指定的文件无法使用（读取失败、不是 TrueType 或没有中文字形）时返回错误，服务应拒绝启动；
+// 未指定且系统中没有时返回 ErrNoCJKFont，此后含中文的内容导出 PDF 都返回该错误
+func LoadPDFFont() (string, error) {
+	pdfFont.once.Do(func() {
+		if path := os.Getenv(PDFFontEnv); path != "" {
+			data, err := os.ReadFile(path)
+			if err == nil {
+				err = checkFont(data, true)
+			}
+			if errors.Is(err, ErrNoCJKFont) {
+				err = errors.New("font has no CJK glyphs")
+			}
+			if err != nil {
+				pdfFont.err = fmt.Errorf("%s=%s: %w", PDFFontEnv, path, err)
+				return
+			}
+			pdfFont.data, pdfFont.path, pdfFont.configured = data, path, true
+			return
+		}
+		for _, path := range systemCJKFonts {
+			data, err := os.ReadFile(path)
+			if err != nil || checkFont(data, true) != nil {
+				continue
+			}
+			pdfFont.data, pdfFont.path = data, path
+			return
+		}
+		pdfFont.err = ErrNoCJKFont
+	})
+	return pdfFont.path, pdfFont.err
+}
+
+var pdfMarkdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+const (
+	bodyFont = "body"
+	monoFont = "mono"
+	lineH    = 6.0
+)
+
+// pdfWriter 遍历 Markdown AST 并输出到 PDF
+type pdfWriter struct {
+	pdf    *fpdf.Fpdf
+	source []byte
+	open   func(url string) (io.ReadCloser, string, bool)
+	images int
+}
+
+// WritePDF 使用纯 Go 渲染器生成 PDF，不依赖外部程序或网络
+func WritePDF(w io.Writer, title string, items []Item, open Opener) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(title, true)
+	pdf.SetAutoPageBreak(true, 15)
+	if err := loadPDFFonts(pdf, needsCJK(title, items)); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		n := item.Note
+		byURL := map[string]int{}
+		for i, a := range n.Attachments {
+			byURL[a.URL] = i
+		}
+		pw := &pdfWriter{pdf: pdf, source: []byte(n.Content)}
+		pw.open = func(url string) (io.ReadCloser, string, bool) {
+			i, ok := byURL[url]
+			if !ok {
+				return nil, "", false
+			}
+			r, err := open(n.Attachments[i])
+			if err != nil {
+				return nil, "", false
+			}
+			return r, n.Attachments[i].Type, true
+		}
+
+		pdf.AddPage()
+		pdf.SetFont(bodyFont, "B", 20)
+		pdf.SetTextColor(55, 53, 47)
+		pdf.MultiCell(0, 10, n.Title, "", "L", false)
+		pdf.SetFont(bodyFont, "", 9)
+		pdf.SetTextColor(155, 154, 151)
+		pdf.MultiCell(0, 5, fmt.Sprintf("%s  Created %s  Updated %s", item.Dir,
+			n.CreatedAt.Format("2006-01-02 15:04"), n.UpdatedAt.Format("2006-01-02 15:04")), "", "L", false)
+		pdf.Ln(4)
+
+		doc := pdfMarkdown.Parser().Parse(text.NewReader(pw.source))
+		pw.blocks(doc, 0)
+
+		if len(n.Attachments) > 0 {
+			pdf.Ln(4)
+			pw.setFont("B", 12)
+			pdf.MultiCell(0, lineH, "Attachments", "", "L", false)
+			pw.setFont("", 10)
+			for _, a := range n.Attachments {
+				pdf.MultiCell(0, lineH, fmt.Sprintf("- %s (%d bytes)", a.Name, a.Size), "", "L", false)
+			}
+		}
+	}
+
+	if err := pdf.Error(); err != nil {
+		return err
+	}
+	return pdf.Output(w)
+}
+
+func loadPDFFonts(pdf *fpdf.Fpdf, cjk bool) error {
+	pdf.AddUTF8FontFromBytes(monoFont, "", gomono.TTF)
+	if _, err := LoadPDFFont(); err == nil && (cjk || pdfFont.configured) {
+		addBodyFont(pdf, pdfFont.data)
+		return pdf.Error()
+	}
+	if cjk {
+		// 内置字体没有中文字形，输出的 PDF 只有方框，不如直接报错
+		return ErrNoCJKFont
+	}
+	pdf.AddUTF8FontFromBytes(bodyFont, "", goregular.TTF)
+	pdf.AddUTF8FontFromBytes(bodyFont, "B", gobold.TTF)
+	pdf.AddUTF8FontFromBytes(bodyFont, "I", goitalic.TTF)
+	pdf.AddUTF8FontFromBytes(bodyFont, "BI", gobold.TTF)
+	return pdf.Error()
+}
+
+// checkFont 检查字体能被 fpdf 使用：TrueType 轮廓（不支持 CFF 的 OTF 和 TTC），
+// cjk 时还需要有中文字形。fpdf 加载无效字体时不报错，输出文字时才 panic
+func checkFont(data []byte, cjk bool) error {
+	if bytes.HasPrefix(data, []byte("OTTO")) {
+		return errors.New("CFF-based OpenType fonts are not supported, use a TTF font")
+	}
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return err
+	}
+	if cjk {
+		if gi, err := f.GlyphIndex(&sfnt.Buffer{}, '中'); err != nil || gi == 0 {
+			return ErrNoCJKFont
+		}
+	}
+	return nil
+}
+
+// addBodyFont 单个字体文件用于全部样式
+func addBodyFont(pdf *fpdf.Fpdf, data []byte) {
+	for _, style := range []string{"", "B", "I", "BI"} {
+		pdf.AddUTF8FontFromBytes(bodyFont, style, data)
+	}
+}
+
+// needsCJK 标题、正文、目录或附件名中是否有中日韩文字
+func needsCJK(title string, items []Item) bool {
+	texts := []string{title}
+	for _, item := range items {
+		texts = append(texts, item.Dir, item.Note.Title, item.Note.Content)
+		for _, a := range item.Note.Attachments {
+			texts = append(texts, a.Name)
+		}
+	}
+	for _, t := range texts {
+		for _, r := range t {
+			if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+				(r >= 0x3000 && r <= 0x303f) || (r >= 0xff00 && r <= 0xffef) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (pw *pdfWriter) setFont(style string, size float64) {
+	pw.pdf.SetFont(bodyFont, style, size)
+	pw.pdf.SetTextColor(55, 53, 47)
+}
+
+// blocks 输出块级节点，indent 为列表/引用的缩进层级
+func (pw *pdfWriter) blocks(parent ast.Node, indent int) {
+	pdf := pw.pdf
+	left, _, _, _ := pdf.GetMargins()
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		pdf.SetLeftMargin(left + float64(indent)*6)
+		pdf.SetX(left + float64(indent)*6)
+
+		switch node := n.(type) {
+		case *ast.Heading:
+			sizes := []float64{0, 18, 15, 13, 12, 11, 11}
+			pdf.Ln(2)
+			pw.setFont("B", sizes[node.Level])
+			pw.inlines(node, "B", sizes[node.Level])
+			pdf.Ln(lineH + 1)
+		case *ast.Paragraph, *ast.TextBlock:
+			pw.setFont("", 11)
+			pw.inlines(node, "", 11)
+			pdf.Ln(lineH)
+			if _, ok := n.(*ast.Paragraph); ok {
+				pdf.Ln(2)
+			}
+		case *ast.List:
+			i := node.Start
+			for item := node.FirstChild(); item != nil; item = item.NextSibling() {
+				marker := "•"
+				if node.IsOrdered() {
+					marker = fmt.Sprintf("%d.", i)
+					i++
+				}
+				pdf.SetX(left + float64(indent)*6)
+				pw.setFont("", 11)
+				pdf.Write(lineH, marker+" ")
+				pw.blocks(item, indent+1)
+			}
+			pdf.Ln(1)
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			pdf.SetFont(monoFont, "", 9)
+			pdf.SetFillColor(247, 246, 243)
+			pdf.SetTextColor(55, 53, 47)
+			pdf.MultiCell(0, 4.5, strings.TrimRight(pw.lines(n), "\n"), "", "L", true)
+			pdf.Ln(2)
+		case *ast.Blockquote:
+			pdf.SetTextColor(120, 119, 116)
+			pw.blocks(node, indent+1)
+		case *ast.ThematicBreak:
+			_, y := pdf.GetXY()
+			w, _ := pdf.GetPageSize()
+			_, _, right, _ := pdf.GetMargins()
+			pdf.SetDrawColor(233, 233, 231)
+			pdf.Line(left, y+2, w-right, y+2)
+			pdf.Ln(5)
+		case *ast.HTMLBlock:
+			pw.setFont("", 11)
+			if s := strings.TrimSpace(htmlTag.ReplaceAllString(pw.lines(n), "")); s != "" {
+				pdf.MultiCell(0, lineH, s, "", "L", false)
+			}
+		case *east.Table:
+			pw.table(node)
+		default:
+			pw.blocks(n, indent)
+		}
+	}
+	pdf.SetLeftMargin(left)
+}
+
+// inlines 输出行内节点，保留粗体、斜体、行内代码和链接
+func (pw *pdfWriter) inlines(parent ast.Node, style string, size float64) {
+	pdf := pw.pdf
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		switch node := n.(type) {
+		case *ast.Text:
+			pdf.SetFont(bodyFont, style, size)
+			pdf.Write(lineH, string(node.Segment.Value(pw.source)))
+			if node.SoftLineBreak() {
+				pdf.Write(lineH, " ")
+			}
+			if node.HardLineBreak() {
+				pdf.Ln(lineH)
+			}
+		case *ast.String:
+			pdf.SetFont(bodyFont, style, size)
+			pdf.Write(lineH, string(node.Value))
+		case *ast.Emphasis:
+			s := style
+			if node.Level >= 2 && !strings.Contains(s, "B") {
+				s += "B"
+			} else if node.Level == 1 && !strings.Contains(s, "I") {
+				s += "I"
+			}
+			pw.inlines(node, s, size)
+		case *ast.CodeSpan:
+			pdf.SetFont(monoFont, "", size-1)
+			pdf.Write(lineH, string(node.Text(pw.source)))
+		case *ast.Link:
+			pdf.SetTextColor(11, 110, 153)
+			pdf.SetFont(bodyFont, style, size)
+			pdf.WriteLinkString(lineH, string(node.Text(pw.source)), string(node.Destination))
+			pdf.SetTextColor(55, 53, 47)
+		case *ast.AutoLink:
+			pdf.SetTextColor(11, 110, 153)
+			pdf.SetFont(bodyFont, style, size)
+			url := string(node.URL(pw.source))
+			pdf.WriteLinkString(lineH, string(node.Label(pw.source)), url)
+			pdf.SetTextColor(55, 53, 47)
+		case *ast.Image:
+			pw.image(string(node.Destination), string(node.Text(pw.source)))
+		case *east.TaskCheckBox:
+			pdf.SetFont(monoFont, "", size)
+			if node.IsChecked {
+				pdf.Write(lineH, "[x] ")
+			} else {
+				pdf.Write(lineH, "[ ] ")
+			}
+		case *ast.RawHTML:
+			// Editor 的颜色标签等，只保留文字
+		default:
+			pw.inlines(n, style, size)
+		}
+	}
+}
+
+// image 嵌入笔记附件中的图片，无法嵌入时输出替代文字
+func (pw *pdfWriter) image(url, alt string) {
+	pdf := pw.pdf
+	r, mimeType, ok := pw.open(url)
+	if !ok {
+		pdf.Write(lineH, "["+alt+"]")
+		return
+	}
+	defer r.Close()
+
+	imageType := map[string]string{"image/png": "PNG", "image/jpeg": "JPG", "image/gif": "GIF"}[mimeType]
+	if imageType == "" {
+		pdf.Write(lineH, "["+alt+"]")
+		return
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		pdf.Write(lineH, "["+alt+"]")
+		return
+	}
+
+	pw.images++
+	name := fmt.Sprintf("img%d", pw.images)
+	opts := fpdf.ImageOptions{ImageType: imageType, ReadDpi: true}
+	info := pdf.RegisterImageOptionsReader(name, opts, bytes.NewReader(data))
+	if info == nil || pdf.Err() {
+		pdf.ClearError()
+		pdf.Write(lineH, "["+alt+"]")
+		return
+	}
+
+	w, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	maxW := w - left - right
+	imgW, imgH := info.Extent()
+	if imgW > maxW {
+		imgH = imgH * maxW / imgW
+		imgW = maxW
+	}
+	pdf.Ln(lineH)
+	pdf.ImageOptions(name, left, pdf.GetY(), imgW, imgH, true, opts, 0, "")
+}
+
+func (pw *pdfWriter) table(t *east.Table) {
+	pdf := pw.pdf
+	w, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+
+	cols := 0
+	for row := t.FirstChild(); row != nil; row = row.NextSibling() {
+		if c := row.ChildCount(); c > cols {
+			cols = c
+		}
+	}
+	if cols == 0 {
+		return
+	}
+	cellW := (w - left - right) / float64(cols)
+
+	pdf.SetDrawColor(233, 233, 231)
+	for row := t.FirstChild(); row != nil; row = row.NextSibling() {
+		style := ""
+		if _, ok := row.(*east.TableHeader); ok {
+			style = "B"
+		}
+		pw.setFont(style, 10)
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			txt := string(cell.Text(pw.source))
+			// 超出单元格宽度时截断
+			for pdf.GetStringWidth(txt) > cellW-2 && len([]rune(txt)) > 1 {
+				r := []rune(txt)
+				txt = string(r[:len(r)-2]) + "…"
+			}
+			pdf.CellFormat(cellW, 7, txt, "1", 0, "L", false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.Ln(3)
+}
+
+func (pw *pdfWriter) lines(n ast.Node) string {
+	var b strings.Builder
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		b.Write(seg.Value(pw.source))
+	}
+	return b.String()
+}
@@ -0,0 +1,76 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// maxDocxXMLSize 解压后的 document.xml 上限，防止压缩炸弹
+const maxDocxXMLSize = 64 << 20
+
+// docxText 读取 word/document.xml 中的文本，段落之间换行
+func docxText(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+	for _, f := range zr.File {
+		if f.Name != "word/document.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+		return wordXMLText(io.LimitReader(rc, maxDocxXMLSize))
+	}
+	return "", errors.New("extract: word/document.xml not found")
+}
+
+func wordXMLText(r io.Reader) (string, error) {
+	var b strings.Builder
+	inText := false
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				b.WriteByte('\t')
+			case "br", "cr":
+				b.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				b.WriteByte('\n')
+			case "tc":
+				b.WriteByte('\t') // 表格单元格
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t)
+			}
+		}
+		if b.Len() >= MaxTextSize {
+			break
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,95 @@
+// Package extract 从附件中提取纯文本，用于全文搜索
+package extract
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxTextSize 提取结果的上限（字节），超出部分截断
+const MaxTextSize = 1 << 20
+
+// ErrUnsupported 不支持提取文本的类型
+var ErrUnsupported = errors.New("extract: unsupported type")
+
+const docxType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
+// Types 支持提取文本的 MIME 类型
+var Types = []string{"application/pdf", docxType, "text/plain", "text/markdown", "text/x-markdown"}
+
+// Supported 是否可以从该类型提取文本。mimeType 可以带参数，如 text/plain; charset=utf-8
+func Supported(mimeType string) bool {
+	return slices.Contains(Types, baseType(mimeType))
+}
+
+// Text 提取文本，结果中连续的空白被合并
+func Text(data []byte, mimeType string) (string, error) {
+	var (
+		text string
+		err  error
+	)
+	switch baseType(mimeType) {
+	case "application/pdf":
+		text, err = pdfText(data)
+	case docxType:
+		text, err = docxText(data)
+	case "text/plain", "text/markdown", "text/x-markdown":
+		text = string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	default:
+		return "", ErrUnsupported
+	}
+	if err != nil {
+		return "", err
+	}
+	return normalize(text), nil
+}
+
+func baseType(mimeType string) string {
+	t, _, _ := strings.Cut(mimeType, ";")
+	return strings.TrimSpace(strings.ToLower(t))
+}
+
+// normalize 去除无效 UTF-8 和控制字符，合并行内空白和多余空行，并截断到 MaxTextSize
+func normalize(text string) string {
+	text = strings.ToValidUTF8(text, "")
+	var b strings.Builder
+	blankLines := 0
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Join(strings.FieldsFunc(line, isSpace), " ")
+		if line == "" {
+			blankLines++
+			continue
+		}
+		if b.Len() > 0 {
+			if blankLines > 0 {
+				b.WriteString("\n\n")
+			} else {
+				b.WriteByte('\n')
+			}
+		}
+		blankLines = 0
+		b.WriteString(line)
+		if b.Len() >= MaxTextSize {
+			break
+		}
+	}
+	return truncate(b.String(), MaxTextSize)
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\r' || r == '\u00a0' || r == '\u3000' || r < 0x20 || r == 0x7f
+}
+
+// truncate 按字节截断，不截断多字节字符
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,48 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// pdfText 按页提取文本；扫描件等没有文本层的 PDF 返回空字符串
+func pdfText(data []byte) (text string, err error) {
+	// 解析库遇到损坏的文件会 panic
+	defer func() {
+		if r := recover(); r != nil {
+			text, err = "", fmt.Errorf("extract: malformed pdf: %v", r)
+		}
+	}()
+
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	fonts := make(map[string]*pdf.Font)
+	for i := 1; i <= r.NumPage(); i++ {
+		p := r.Page(i)
+		if p.V.IsNull() {
+			continue
+		}
+		for _, name := range p.Fonts() {
+			if _, ok := fonts[name]; !ok {
+				f := p.Font(name)
+				fonts[name] = &f
+			}
+		}
+		pageText, err := p.GetPlainText(fonts)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(pageText)
+		b.WriteString("\n\n")
+		if b.Len() >= MaxTextSize {
+			break
+		}
+	}
+	return b.String(), nil
+}
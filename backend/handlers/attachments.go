@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gonote/db"
+	"gonote/middleware"
+	"gonote/models"
+	"gonote/storage"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// UploadAttachment - POST /api/notes/:id/attachments
+// 上传文件并记录为笔记附件，需要笔记的编辑权限
+func UploadAttachment(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	note, err := loadAccessibleNote(c.Param("id"), userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+	if !canEditNote(note, userId) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No permission to edit this note"})
+		return
+	}
+
+	if uploadLimits.MaxSize > 0 {
+		// 预留 multipart 头部的空间，精确的大小在下面检查
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, uploadLimits.MaxSize+1<<20)
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeUploadError(c, fileTooLarge(uploadLimits.MaxSize))
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	if uploadLimits.MaxSize > 0 && file.Size > uploadLimits.MaxSize {
+		writeUploadError(c, fileTooLarge(uploadLimits.MaxSize))
+		return
+	}
+	if err := checkQuota(userId, note, file.Size); err != nil {
+		writeUploadError(c, err)
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	defer src.Close()
+
+	attachment, err := createAttachment(c.Request.Context(), userId, note.ID, file.Filename, src)
+	if err != nil {
+		writeUploadError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, attachment)
+}
+
+// createAttachment 检测类型、限制大小、去除图片元数据后保存文件，并创建附件记录
+func createAttachment(ctx context.Context, userId, noteId, filename string, src io.Reader) (models.Attachment, error) {
+	// 类型以文件内容为准，不使用客户端提供的 Content-Type
+	contentType, r, err := sniffUpload(src)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	// 图片去除 Exif / GPS 等元数据后再保存
+	body, width, height, err := sanitizeImage(contentType, limitUpload(r, contentType))
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	blob, err := saveUpload(ctx, contentType, body)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+
+	id := "a-" + uuid.New().String()
+	attachment := models.Attachment{
+		ID:       id,
+		NoteID:   noteId,
+		UserID:   userId,
+		Name:     sanitizeFilename(filename),
+		Type:     contentType,
+		Size:     blob.Size,
+		FilePath: blob.Key,
+		Hash:     blob.Hash,
+		Width:    width,
+		Height:   height,
+		URL:      attachmentURL(id),
+	}
+	if err := db.DB.Create(&attachment).Error; err != nil {
+		return models.Attachment{}, err
+	}
+	generateThumbnailsAsync(attachment)
+	queueTextExtraction()
+	return attachment, nil
+}
+
+// GetAttachments - GET /api/notes/:id/attachments
+func GetAttachments(c *gin.Context) {
+	note, err := loadAccessibleNote(c.Param("id"), c.GetString("userId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+
+	attachments := make([]models.Attachment, 0)
+	if err := db.DB.Where("note_id = ?", note.ID).Order("created_at asc").Find(&attachments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachments"})
+		return
+	}
+	c.JSON(http.StatusOK, attachments)
+}
+
+// DeleteAttachment - DELETE /api/notes/:id/attachments/:attachmentId
+// 上传者或笔记作者可以删除
+func DeleteAttachment(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	note, err := loadAccessibleNote(c.Param("id"), userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+
+	var attachment models.Attachment
+	if err := db.DB.Where("id = ? AND note_id = ?", c.Param("attachmentId"), note.ID).First(&attachment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+	if attachment.UserID != userId && note.UserID != userId {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No permission to delete this attachment"})
+		return
+	}
+
+	if err := db.DB.Delete(&attachment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attachment"})
+		return
+	}
+	// 同一内容可能被多个附件引用，引用数归零时才删除文件
+	releaseBlob(c.Request.Context(), attachment)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted"})
+}
+
+// MigrateLegacyAttachmentPaths 旧版本 FilePath 保存的是 "uploads/xxx" 本地路径，
+// 改为本地存储根目录下的 key
+func MigrateLegacyAttachmentPaths() {
+	var attachments []models.Attachment
+	db.DB.Where("file_path LIKE ? OR file_path LIKE ?", "uploads/%", "./uploads/%").Find(&attachments)
+	for _, a := range attachments {
+		key := strings.TrimPrefix(strings.TrimPrefix(a.FilePath, "./"), "uploads/")
+		db.DB.Model(&a).Update("file_path", key)
+	}
+	if len(attachments) > 0 {
+		log.Printf("Migrated %d legacy attachment paths", len(attachments))
+	}
+}
+
+// attachmentURL 附件的稳定地址，写入笔记正文；需要登录才能访问
+func attachmentURL(id string) string {
+	return "/api/attachments/" + id + "/download"
+}
+
+// signedAttachmentURL 短期有效的签名地址，用于在渲染后的笔记中嵌入图片
+func signedAttachmentURL(id string, now time.Time) (string, time.Time) {
+	expires := middleware.SignedURLExpiry(now)
+	sig := middleware.SignAttachment(id, expires)
+	return fmt.Sprintf("/api/files/%s?exp=%d&sig=%s", id, expires.Unix(), sig), expires
+}
+
+// DownloadAttachment - GET /api/attachments/:id/download?inline=1&size=small
+// 校验对所属笔记的访问权限，支持 Range 请求；size 参数返回图片缩略图
+func DownloadAttachment(c *gin.Context) {
+	var attachment models.Attachment
+	if err := db.DB.First(&attachment, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+	if _, err := loadAccessibleNote(attachment.NoteID, c.GetString("userId")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+	serveAttachment(c, attachment, c.Query("inline") == "1")
+}
+
+// ServeLegacyUpload - GET /uploads/:name
+// 旧版本正文中的 /uploads/ 链接，按迁移时记录的原文件名找到有权限访问的附件
+func ServeLegacyUpload(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	var attachments []models.Attachment
+	db.DB.Where("legacy_path = ?", c.Param("name")).Order("created_at asc").Find(&attachments)
+	for _, a := range attachments {
+		if _, err := loadAccessibleNote(a.NoteID, userId); err == nil {
+			serveAttachment(c, a, true)
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+}
+
+// GetAttachmentSignedURL - GET /api/attachments/:id/signed-url
+func GetAttachmentSignedURL(c *gin.Context) {
+	var attachment models.Attachment
+	if err := db.DB.First(&attachment, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+	if _, err := loadAccessibleNote(attachment.NoteID, c.GetString("userId")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+
+	url, expires := signedAttachmentURL(attachment.ID, time.Now())
+	c.JSON(http.StatusOK, gin.H{"url": url, "expiresAt": expires})
+}
+
+// ServeSignedFile - GET /api/files/:id?exp=...&sig=...
+// 通过签名鉴权，不需要 Authorization 头
+func ServeSignedFile(c *gin.Context) {
+	id := c.Param("id")
+	if !middleware.VerifyAttachmentSignature(id, c.Query("exp"), c.Query("sig")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "链接无效或已过期"})
+		return
+	}
+
+	var attachment models.Attachment
+	if err := db.DB.First(&attachment, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+	serveAttachment(c, attachment, true)
+}
+
+// inlineTypes 允许在浏览器中直接显示的类型；HTML、SVG 等可执行脚本的类型始终作为下载
+var inlineTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "text/plain", "video/", "audio/"}
+
+func serveAttachment(c *gin.Context, a models.Attachment, inline bool) {
+	if size := c.Query("size"); size != "" {
+		serveThumbnail(c, a, size, inline)
+		return
+	}
+	serveBlob(c, a.FilePath, a.Type, a.Name, inline)
+}
+
+func serveBlob(c *gin.Context, key, contentType, name string, inline bool) {
+	obj, err := storage.Blobs.Open(c.Request.Context(), key)
+	if err == storage.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: failed to open blob %s: %v", key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer obj.Close()
+
+	disposition := "attachment"
+	if inline {
+		for _, t := range inlineTypes {
+			if strings.HasPrefix(contentType, t) {
+				disposition = "inline"
+				break
+			}
+		}
+	}
+
+	if contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": name}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "private, max-age=3600")
+	// ServeContent 处理 Range、If-Modified-Since 等
+	http.ServeContent(c.Writer, c.Request, name, obj.ModTime(), obj)
+}
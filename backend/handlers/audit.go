@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gonote/models"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// recordAudit 写入一条审计记录，失败只记录日志，不影响主流程
+func recordAudit(tx *gorm.DB, userId, action, targetType, targetId string, detail map[string]interface{}) {
+	data, _ := json.Marshal(detail)
+	entry := models.AuditLog{
+		UserID:     userId,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetId,
+		Detail:     string(data),
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		log.Printf("ERROR: failed to record audit %s on %s: %v", action, targetId, err)
+	}
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"gonote/db"
+	"gonote/models"
+	"gonote/storage"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// blobGCGrace 上传后尚未被附件引用的 Blob 至少保留这么久，避免 GC 删掉正在使用的文件
+	blobGCGrace = time.Hour
+	// blobReleaseGrace 删除附件时释放 Blob 的保护期，避开同时进行的相同内容上传
+	blobReleaseGrace = time.Minute
+)
+
+// storedBlob saveUpload 的结果，写入 Attachment 的 FilePath / Hash / Size
+type storedBlob struct {
+	Key  string
+	Hash string
+	Size int64
+}
+
+func blobKey(hash string) string {
+	return "sha256/" + hash[:2] + "/" + hash
+}
+
+// blobLocks 按摘要前两位分段加锁，同一内容的保存、释放和 GC 串行执行：
+// 否则 GC 删除记录之后、删除文件之前，相同内容的上传会重新登记并写入同一个 key，随后文件被 GC 删掉
+var blobLocks [256]sync.Mutex
+
+func lockBlob(hash string) func() {
+	i := uint64(0)
+	if len(hash) >= 2 {
+		i, _ = strconv.ParseUint(hash[:2], 16, 8)
+	}
+	blobLocks[i].Lock()
+	return blobLocks[i].Unlock
+}
+
+// saveUpload 按内容去重保存文件：已有相同 SHA-256 的 Blob 时只刷新引用时间
+func saveUpload(ctx context.Context, contentType string, r io.Reader) (storedBlob, error) {
+	// 先写临时文件计算摘要，确定 key 后再写入存储后端
+	tmp, err := os.CreateTemp("", "gonote-upload-*")
+	if err != nil {
+		return storedBlob{}, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		return storedBlob{}, err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	blob := storedBlob{Key: blobKey(hash), Hash: hash, Size: size}
+
+	unlock := lockBlob(hash)
+	defer unlock()
+	res := db.DB.Model(&models.Blob{}).Where("hash = ?", hash).Update("updated_at", time.Now())
+	if res.Error != nil {
+		return storedBlob{}, res.Error
+	}
+	if res.RowsAffected > 0 {
+		return blob, nil
+	}
+
+	// 先登记再写入，GC 扫描孤立文件时不会删掉正在写入的对象
+	res = db.DB.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.Blob{Hash: hash, Key: blob.Key, Size: size})
+	if res.Error != nil {
+		return storedBlob{}, res.Error
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return storedBlob{}, err
+	}
+	if _, err := storage.Blobs.Put(ctx, blob.Key, tmp, contentType); err != nil {
+		// 只删除本次登记的记录；持有锁，期间没有其他上传引用它
+		if res.RowsAffected > 0 {
+			db.DB.Where("hash = ?", hash).Delete(&models.Blob{})
+		}
+		return storedBlob{}, err
+	}
+	return blob, nil
+}
+
+// unreferencedBlobs 没有附件或缩略图引用、且在 cutoff 之前最后被引用的 Blob
+func unreferencedBlobs(tx *gorm.DB, cutoff time.Time) *gorm.DB {
+	return tx.Where("updated_at < ? AND NOT EXISTS (SELECT 1 FROM attachments WHERE attachments.hash = blobs.hash)"+
+		" AND NOT EXISTS (SELECT 1 FROM thumbnails WHERE thumbnails.hash = blobs.hash)", cutoff)
+}
+
+// deleteBlobIfUnreferenced 条件删除 Blob 记录，删除成功后再删除存储的文件
+func deleteBlobIfUnreferenced(ctx context.Context, blob models.Blob, cutoff time.Time) (bool, error) {
+	unlock := lockBlob(blob.Hash)
+	defer unlock()
+	res := unreferencedBlobs(db.DB, cutoff).Where("hash = ?", blob.Hash).Delete(&models.Blob{})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false, res.Error
+	}
+	return true, storage.Blobs.Delete(ctx, blob.Key)
+}
+
+// releaseBlob 删除附件后调用，引用数归零时删除文件
+func releaseBlob(ctx context.Context, a models.Attachment) {
+	if a.Hash == "" {
+		// 旧数据每个附件一份文件，没有其他引用时直接删除
+		var count int64
+		db.DB.Model(&models.Attachment{}).Where("file_path = ?", a.FilePath).Count(&count)
+		if count == 0 {
+			if err := storage.Blobs.Delete(ctx, a.FilePath); err != nil {
+				log.Printf("ERROR: failed to remove attachment blob %s: %v", a.FilePath, err)
+			}
+		}
+		return
+	}
+	blob := models.Blob{Hash: a.Hash, Key: a.FilePath}
+	if _, err := deleteBlobIfUnreferenced(ctx, blob, time.Now().Add(-blobReleaseGrace)); err != nil {
+		log.Printf("ERROR: failed to release blob %s: %v", a.Hash, err)
+	}
+}
+
+// StartBlobGC 启动后台 GC，每隔 interval 执行一次 CollectBlobs。
+// 第一轮在启动 interval 之后执行，启动时不做删除
+func StartBlobGC(interval time.Duration) {
+	go func() {
+		for {
+			time.Sleep(interval)
+			CollectBlobs(context.Background())
+		}
+	}()
+}
+
+// CollectBlobs 执行一轮 GC：
+//  1. 旧附件转为按内容存储
+//  2. 删除原图已没有附件引用的缩略图记录和提取的文本
+//  3. 删除没有附件或缩略图引用的 Blob
+//  4. 删除 sha256/ 下没有 Blob 记录、且超过 blobGCGrace 的孤立文件
+//  5. 删除已迁移、不再被附件或正文引用的旧文件（LegacyBlob）
+//
+// 存储中的其他对象不处理，S3 桶与其他程序共用时不会误删
+func CollectBlobs(ctx context.Context) {
+	adopted := adoptLegacyAttachments(ctx)
+
+	db.DB.Where("created_at < ? AND NOT EXISTS (SELECT 1 FROM attachments WHERE attachments.hash = thumbnails.source_hash)",
+		time.Now().Add(-blobGCGrace)).Delete(&models.Thumbnail{})
+	db.DB.Where("NOT EXISTS (SELECT 1 FROM attachments WHERE attachments.hash = blob_texts.hash)").Delete(&models.BlobText{})
+
+	var blobs []models.Blob
+	unreferencedBlobs(db.DB, time.Now().Add(-blobGCGrace)).Find(&blobs)
+	removed := 0
+	for _, b := range blobs {
+		ok, err := deleteBlobIfUnreferenced(ctx, b, time.Now().Add(-blobGCGrace))
+		if err != nil {
+			log.Printf("ERROR: blob gc: %s: %v", b.Key, err)
+			continue
+		}
+		if ok {
+			removed++
+		}
+	}
+
+	var candidates []string
+	err := storage.Blobs.Walk(ctx, func(key string, size int64) error {
+		if strings.HasPrefix(key, "sha256/") {
+			candidates = append(candidates, key)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("ERROR: blob gc: list storage: %v", err)
+		return
+	}
+	orphans := 0
+	for _, key := range candidates {
+		if removeOrphanBlob(ctx, key) {
+			orphans++
+		}
+	}
+	var legacy []models.LegacyBlob
+	db.DB.Find(&legacy)
+	for _, l := range legacy {
+		if removeLegacyBlob(ctx, l.Key) {
+			orphans++
+		}
+	}
+
+	if adopted+removed+orphans > 0 {
+		log.Printf("Blob GC: %d legacy attachments adopted, %d unreferenced blobs and %d orphaned files removed", adopted, removed, orphans)
+	}
+}
+
+// removeOrphanBlob 删除 sha256/ 下没有 Blob 记录和附件引用的文件。
+// 持有该内容的锁，检查之后不会有相同内容的上传写入同一个 key
+func removeOrphanBlob(ctx context.Context, key string) bool {
+	unlock := lockBlob(path.Base(key))
+	defer unlock()
+
+	var count int64
+	db.DB.Model(&models.Blob{}).Where("key = ?", key).Count(&count)
+	if count == 0 {
+		db.DB.Model(&models.Attachment{}).Where("file_path = ?", key).Count(&count)
+	}
+	if count > 0 || !orphanExpired(ctx, key) {
+		return false
+	}
+	if err := storage.Blobs.Delete(ctx, key); err != nil {
+		log.Printf("ERROR: blob gc: remove orphan %s: %v", key, err)
+		return false
+	}
+	return true
+}
+
+// removeLegacyBlob 旧文件的内容已另存为 Blob，不再被附件或正文中的旧链接引用时删除
+func removeLegacyBlob(ctx context.Context, key string) bool {
+	var count int64
+	db.DB.Model(&models.Attachment{}).Where("file_path = ?", key).Count(&count)
+	if count > 0 || legacyUploadReferenced(key) {
+		return false
+	}
+	if err := storage.Blobs.Delete(ctx, key); err != nil {
+		log.Printf("ERROR: blob gc: remove legacy file %s: %v", key, err)
+		return false
+	}
+	db.DB.Delete(&models.LegacyBlob{Key: key})
+	return true
+}
+
+// adoptLegacyAttachments 没有 Hash 的附件重新按内容保存，原文件记为 LegacyBlob 留给 GC 删除
+func adoptLegacyAttachments(ctx context.Context) int {
+	var attachments []models.Attachment
+	db.DB.Where("hash = '' OR hash IS NULL").Find(&attachments)
+
+	adopted := 0
+	for _, a := range attachments {
+		obj, err := storage.Blobs.Open(ctx, a.FilePath)
+		if err != nil {
+			log.Printf("WARN: blob gc: attachment %s: %v", a.ID, err)
+			continue
+		}
+		blob, err := saveUpload(ctx, a.Type, obj)
+		obj.Close()
+		if err != nil {
+			log.Printf("ERROR: blob gc: attachment %s: %v", a.ID, err)
+			continue
+		}
+		recordLegacyBlob(a.FilePath)
+		db.DB.Model(&a).Updates(map[string]interface{}{"file_path": blob.Key, "hash": blob.Hash, "size": blob.Size})
+		adopted++
+	}
+	return adopted
+}
+
+func recordLegacyBlob(key string) {
+	if err := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LegacyBlob{Key: key}).Error; err != nil {
+		log.Printf("ERROR: record legacy file %s: %v", key, err)
+	}
+}
+
+// orphanExpired 孤立文件最后修改超过 blobGCGrace 才删除，正在写入或刚迁移的文件保留
+func orphanExpired(ctx context.Context, key string) bool {
+	obj, err := storage.Blobs.Open(ctx, key)
+	if err != nil {
+		return false
+	}
+	defer obj.Close()
+	return time.Since(obj.ModTime()) > blobGCGrace
+}
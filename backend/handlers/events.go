@@ -1,28 +1,40 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"gonote/db"
+	"gonote/lunar"
 	"gonote/models"
+	"gonote/recur"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-// GetEvents - GET /api/events?start=...&end=...
+// GetEvents - GET /api/events?start=...&end=...&tz=...&system=false
 // 仅返回用户自己的事件 + 系统事件
 // 家庭事件请通过 GetFamilyEvents 获取
+// 指定 start / end 时重复事件展开为范围内的每一次（见 expandEvents），否则返回事件本身。
+// 指定范围时还包括内置的二十四节气和法定节假日（见 systemEvents），system=false 时不包括
 func GetEvents(c *gin.Context) {
 	userId := c.GetString("userId")
-	start := c.Query("start")
-	end := c.Query("end")
+	rng, err := parseEventRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	var events []models.Event
 
-	// 返回用户自己的事件 + 系统事件
-	// 注意：家庭事件现在完全隔离
-	query := db.DB.Where("(user_id = ? AND (family_id IS NULL OR family_id = '')) OR is_system = ?", userId, true)
-	if start != "" && end != "" {
-		query = query.Where("date BETWEEN ? AND ?", start, end)
+	query := personalEventsQuery(userId)
+	if rng != nil {
+		query = rangeQuery(query, rng)
 	}
 
 	if err := query.Find(&events).Error; err != nil {
@@ -30,11 +42,22 @@ func GetEvents(c *gin.Context) {
 		return
 	}
 
+	if rng != nil {
+		if c.Query("system") != "false" {
+			events = append(events, systemEvents(rng)...)
+		}
+		events = expandEvents(events, rng)
+	}
+	fillEventReminders(events)
 	c.JSON(http.StatusOK, events)
 }
 
-// CreateEvent - POST /api/events
+// CreateEvent - POST /api/events?tz=...
+// 指定 familyId 时必须是该家庭成员；用户不能创建系统事件。
+// 没有提供 tzid 时，事件的时区为请求参数 tz，其次是用户设置的时区
 func CreateEvent(c *gin.Context) {
+	userId := c.GetString("userId")
+
 	var event models.Event
 	if err := c.ShouldBindJSON(&event); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -42,9 +65,52 @@ func CreateEvent(c *gin.Context) {
 	}
 
 	// Assign current user
-	event.UserID = c.GetString("userId")
+	event.ID = 0
+	event.UserID = userId
+	event.IsSystem = false
+	if event.FamilyID != nil && *event.FamilyID == "" {
+		event.FamilyID = nil
+	}
+	if event.FamilyID != nil {
+		if _, err := findFamilyMember(*event.FamilyID, userId); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "您不是该家庭的成员"})
+			return
+		}
+	}
+	if err := validateEvent(&event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkNotifyUsers(&event, ""); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if event.TZID == "" {
+		loc, err := eventLocation(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		event.TZID = loc.String()
+	}
+	if err := applyLunarDate(&event, eventZone(&event)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	if err := db.DB.Create(&event).Error; err != nil {
+	reminders, err := validateReminders(event.Reminders)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+		return saveReminders(tx, &event, reminders)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create event"})
 		return
 	}
@@ -52,15 +118,373 @@ func CreateEvent(c *gin.Context) {
 	c.JSON(http.StatusCreated, event)
 }
 
+// eventPatch PATCH 请求体，只修改提供的字段。所属家庭不能通过修改接口变更
+type eventPatch struct {
+	Title         *string                `json:"title"`
+	Description   *string                `json:"description"`
+	Date          *time.Time             `json:"date"`
+	TZID          *string                `json:"tzid"`
+	Type          *models.EventType      `json:"type"`
+	Recurrence    *models.RecurrenceType `json:"recurrence"`
+	RRule         *string                `json:"rrule"`
+	ExDate        *string                `json:"exdate"`
+	LunarMonth    *int                   `json:"lunarMonth"`
+	LunarDay      *int                   `json:"lunarDay"`
+	LunarLeap     *bool                  `json:"lunarLeap"`
+	NotifyUsers   *string                `json:"notifyUsers"`
+	ShowCountdown *bool                  `json:"showCountdown"`
+	Reminders     *[]int                 `json:"reminders"`
+}
+
+// UpdateEvent - PUT/PATCH /api/events/:id
+// PUT 替换全部可编辑字段，PATCH 只修改提供的字段；事件 ID 不变，tzid 为空时保留原来的时区。
+// 系统事件只读，权限见 canEditEvent。
+// 修改时间、时区或重复规则后原来的每一次不再对应，单独修改和取消的重复会被清除
+func UpdateEvent(c *gin.Context) {
+	event, ok := loadEditableEvent(c)
+	if !ok {
+		return
+	}
+	previous := *event
+	var reminders *[]int // nil 时保留原来的提醒
+
+	if c.Request.Method == http.MethodPut {
+		var req models.Event
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		event.Title = req.Title
+		event.Description = req.Description
+		event.Date = req.Date
+		if req.TZID != "" {
+			event.TZID = req.TZID
+		}
+		event.Type = req.Type
+		event.Recurrence = req.Recurrence
+		event.RRule = req.RRule
+		event.ExDate = req.ExDate
+		event.LunarMonth = req.LunarMonth
+		event.LunarDay = req.LunarDay
+		event.LunarLeap = req.LunarLeap
+		event.NotifyUsers = req.NotifyUsers
+		event.ShowCountdown = req.ShowCountdown
+		reminders = &req.Reminders
+	} else {
+		var req eventPatch
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Title != nil {
+			event.Title = *req.Title
+		}
+		if req.Description != nil {
+			event.Description = *req.Description
+		}
+		if req.Date != nil {
+			event.Date = *req.Date
+		}
+		if req.TZID != nil && *req.TZID != "" {
+			event.TZID = *req.TZID
+		}
+		if req.Type != nil {
+			event.Type = *req.Type
+		}
+		if req.Recurrence != nil {
+			event.Recurrence = *req.Recurrence
+			// 只改 recurrence 时使用其默认规则
+			if req.RRule == nil {
+				event.RRule = ""
+			}
+		}
+		if req.RRule != nil {
+			event.RRule = *req.RRule
+		}
+		if req.ExDate != nil {
+			event.ExDate = *req.ExDate
+		}
+		if req.LunarMonth != nil || req.LunarDay != nil || req.LunarLeap != nil {
+			if req.LunarMonth != nil {
+				event.LunarMonth = *req.LunarMonth
+			}
+			if req.LunarDay != nil {
+				event.LunarDay = *req.LunarDay
+			}
+			if req.LunarLeap != nil {
+				event.LunarLeap = *req.LunarLeap
+			}
+		} else if req.Date != nil || req.Type != nil {
+			// 只改日期或类型时农历月日从新的日期推出
+			event.LunarMonth, event.LunarDay, event.LunarLeap = 0, 0, false
+		}
+		if req.NotifyUsers != nil {
+			event.NotifyUsers = *req.NotifyUsers
+		}
+		if req.ShowCountdown != nil {
+			event.ShowCountdown = *req.ShowCountdown
+		}
+		reminders = req.Reminders
+	}
+	if err := validateEvent(event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkNotifyUsers(event, previous.NotifyUsers); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := applyLunarDate(event, eventZone(event)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if reminders != nil {
+		validated, err := validateReminders(*reminders)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		reminders = &validated
+	}
+
+	resetOccurrences := !event.Date.Equal(previous.Date) || event.TZID != previous.TZID || event.RRule != previous.RRule ||
+		event.Type != previous.Type || event.LunarMonth != previous.LunarMonth ||
+		event.LunarDay != previous.LunarDay || event.LunarLeap != previous.LunarLeap
+	if resetOccurrences {
+		event.ExDate = ""
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if resetOccurrences {
+			if err := tx.Where("event_id = ?", event.ID).Delete(&models.EventOverride{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(event).Error; err != nil {
+			return err
+		}
+		// 时间、重复规则等修改后重新计算下一次提醒
+		if reminders != nil {
+			return saveReminders(tx, event, *reminders)
+		}
+		return rescheduleReminders(tx, event)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update event"})
+		return
+	}
+	c.JSON(http.StatusOK, event)
+}
+
 // DeleteEvent - DELETE /api/events/:id
+// 系统事件不能删除，权限与修改相同
 func DeleteEvent(c *gin.Context) {
-	id := c.Param("id")
-	userId := c.GetString("userId")
+	event, ok := loadEditableEvent(c)
+	if !ok {
+		return
+	}
 
-	if err := db.DB.Where("id = ? AND user_id = ?", id, userId).Delete(&models.Event{}).Error; err != nil {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("event_id = ?", event.ID).Delete(&models.EventOverride{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("event_id = ?", event.ID).Delete(&models.EventReminder{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(event).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete event"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Event deleted"})
 }
+
+// loadEditableEvent 加载当前用户可以修改的事件，失败时已写入响应：
+// 看不到的事件返回 404，系统事件和没有权限的事件返回 403
+func loadEditableEvent(c *gin.Context) (*models.Event, bool) {
+	userId := c.GetString("userId")
+
+	var event models.Event
+	if err := db.DB.First(&event, "id = ?", c.Param("id")).Error; err != nil || !canViewEvent(&event, userId) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil, false
+	}
+	if event.IsSystem {
+		c.JSON(http.StatusForbidden, gin.H{"error": "系统事件为只读"})
+		return nil, false
+	}
+	if !canEditEvent(&event, userId) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No permission to modify this event"})
+		return nil, false
+	}
+	return &event, true
+}
+
+// canViewEvent 系统事件所有人可见；家庭事件家庭成员可见；个人事件仅创建者可见
+func canViewEvent(event *models.Event, userId string) bool {
+	switch {
+	case event.IsSystem:
+		return true
+	case event.FamilyID != nil && *event.FamilyID != "":
+		_, err := findFamilyMember(*event.FamilyID, userId)
+		return err == nil
+	default:
+		return event.UserID == userId
+	}
+}
+
+// canEditEvent 系统事件只读；个人事件仅创建者可以修改；
+// 家庭事件中 owner 可以修改全部事件，普通成员只能修改自己创建的事件
+func canEditEvent(event *models.Event, userId string) bool {
+	if event.IsSystem {
+		return false
+	}
+	if event.FamilyID == nil || *event.FamilyID == "" {
+		return event.UserID == userId
+	}
+	member, err := findFamilyMember(*event.FamilyID, userId)
+	if err != nil {
+		return false
+	}
+	return member.Role == "owner" || event.UserID == userId
+}
+
+// validateEvent 检查必填字段和枚举值，类型和重复规则为空时使用默认值。
+// 设置了 rrule 时规范化并以其 FREQ 作为 recurrence；否则 rrule 由 recurrence 推出
+func validateEvent(event *models.Event) error {
+	if strings.TrimSpace(event.Title) == "" {
+		return errors.New("Event title is required")
+	}
+	if event.Date.IsZero() {
+		return errors.New("Event date is required")
+	}
+	if event.TZID != "" {
+		if _, err := time.LoadLocation(event.TZID); err != nil || event.TZID == "Local" {
+			return fmt.Errorf("Invalid tzid %q", event.TZID)
+		}
+	}
+	switch event.Type {
+	case "":
+		event.Type = models.EventTypeSolar
+	case models.EventTypeSolar, models.EventTypeLunar:
+	default:
+		// holiday、term 仅用于系统事件
+		return fmt.Errorf("Invalid event type %q", event.Type)
+	}
+	switch event.Recurrence {
+	case "":
+		event.Recurrence = models.RecurrenceNone
+	case models.RecurrenceNone, models.RecurrenceDaily, models.RecurrenceWeekly, models.RecurrenceMonthly, models.RecurrenceYearly:
+	default:
+		return fmt.Errorf("Invalid recurrence %q", event.Recurrence)
+	}
+	if event.RRule != "" {
+		rule, err := recur.Parse(event.RRule)
+		if err != nil {
+			return fmt.Errorf("Invalid rrule: %v", err)
+		}
+		if event.Type == models.EventTypeLunar {
+			if err := checkLunarRule(rule); err != nil {
+				return err
+			}
+		}
+		event.RRule = rule.String()
+		event.Recurrence = models.RecurrenceType(strings.ToLower(string(rule.Freq)))
+	} else if event.Recurrence != models.RecurrenceNone {
+		if event.Type == models.EventTypeLunar && event.Recurrence != models.RecurrenceYearly {
+			return errors.New("lunar events only support yearly recurrence")
+		}
+		event.RRule = "FREQ=" + strings.ToUpper(string(event.Recurrence))
+	}
+	if event.Recurrence == models.RecurrenceNone {
+		event.ExDate = ""
+	}
+	exdates, err := recur.ParseDates(event.ExDate)
+	if err != nil {
+		return fmt.Errorf("Invalid exdate: %v", err)
+	}
+	event.ExDate = recur.FormatDates(exdates)
+	if event.NotifyUsers != "" {
+		var users []string
+		if err := json.Unmarshal([]byte(event.NotifyUsers), &users); err != nil {
+			return errors.New("notifyUsers must be a JSON array of user IDs")
+		}
+	}
+	return nil
+}
+
+// checkNotifyUsers 只能提醒能看到这个事件的人：个人事件仅创建者，家庭事件为家庭成员。
+// 新加入的用户没有权限时返回错误；previous 中原有的用户失去权限（例如已退出家庭）时直接移除
+func checkNotifyUsers(event *models.Event, previous string) error {
+	if event.NotifyUsers == "" {
+		return nil
+	}
+	var users, existing []string
+	if err := json.Unmarshal([]byte(event.NotifyUsers), &users); err != nil {
+		return errors.New("notifyUsers must be a JSON array of user IDs")
+	}
+	if previous != "" {
+		json.Unmarshal([]byte(previous), &existing)
+	}
+	kept := make([]string, 0, len(users))
+	for _, id := range users {
+		if canViewEvent(event, id) {
+			kept = append(kept, id)
+			continue
+		}
+		if !slices.Contains(existing, id) {
+			return fmt.Errorf("notifyUsers: user %q cannot view this event", id)
+		}
+	}
+	if len(kept) < len(users) {
+		data, _ := json.Marshal(kept)
+		event.NotifyUsers = string(data)
+	}
+	return nil
+}
+
+// applyLunarDate 农历事件保存农历月日。
+// 没有提供农历月日时从 Date 在 loc 时区的日期推出；提供时 Date 改为当天或之后第一个对应的日期（规则同 lunar.InYear），
+// 保留原来的钟点。公历事件清空农历字段
+func applyLunarDate(event *models.Event, loc *time.Location) error {
+	if event.Type != models.EventTypeLunar {
+		event.LunarMonth, event.LunarDay, event.LunarLeap = 0, 0, false
+		return nil
+	}
+
+	local := event.Date.In(loc)
+	start, err := lunar.FromSolar(local)
+	if err != nil {
+		return errors.New("Lunar dates are supported from 1900 to 2100")
+	}
+	if event.LunarMonth == 0 && event.LunarDay == 0 {
+		event.LunarMonth, event.LunarDay, event.LunarLeap = start.Month, start.Day, start.Leap
+		return nil
+	}
+
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	for year := start.Year; year <= start.Year+1; year++ {
+		d, err := lunar.InYear(year, event.LunarMonth, event.LunarDay, event.LunarLeap)
+		if err != nil {
+			return errors.New("Invalid lunar date: lunarMonth must be 1-12 and lunarDay 1-30")
+		}
+		solar, err := d.Solar(loc)
+		if err != nil {
+			return errors.New("Lunar dates are supported from 1900 to 2100")
+		}
+		if !solar.Before(day) {
+			hour, min, sec := local.Clock()
+			event.Date = time.Date(solar.Year(), solar.Month(), solar.Day(), hour, min, sec, 0, loc)
+			return nil
+		}
+	}
+	return errors.New("Lunar dates are supported from 1900 to 2100") // 不会发生：下一年总有对应的日期
+}
+
+// personalEventsQuery 用户自己的事件 + 系统事件
+// 注意：家庭事件现在完全隔离
+func personalEventsQuery(userId string) *gorm.DB {
+	return db.DB.Where("((user_id = ? AND (family_id IS NULL OR family_id = '')) OR is_system = ?)", userId, true)
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"gonote/db"
+	"gonote/export"
+	"gonote/models"
+	"gonote/storage"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportNote - GET /api/notes/:id/export?format=zip|html|pdf
+func ExportNote(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	note, err := loadAccessibleNote(c.Param("id"), userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+	db.DB.Preload("Attachments").First(note, "id = ?", note.ID)
+
+	writeExport(c, note.Title, []export.Item{{Note: *note}})
+}
+
+// ExportFolder - GET /api/export/folders/:id?format=...
+// 导出个人空间中某个文件夹的笔记
+func ExportFolder(c *gin.Context) {
+	userId := c.GetString("userId")
+	folderId := c.Param("id")
+
+	var notes []models.Note
+	db.DB.Preload("Attachments").
+		Where("user_id = ? AND (family_id IS NULL OR family_id = '') AND folder_id = ?", userId, folderId).
+		Order("updated_at desc").Find(&notes)
+
+	folders := folderNames(userId, "")
+	writeExport(c, folders.name(folderId), exportItems(notes, "", folders))
+}
+
+// ExportFamily - GET /api/export/families/:id?format=...
+// 导出家庭空间的全部笔记
+func ExportFamily(c *gin.Context) {
+	userId := c.GetString("userId")
+	familyId := c.Param("id")
+
+	if _, err := findFamilyMember(familyId, userId); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "您不是该家庭的成员"})
+		return
+	}
+	var family models.Family
+	db.DB.First(&family, "id = ?", familyId)
+
+	var notes []models.Note
+	db.DB.Preload("Attachments").Where("family_id = ?", familyId).Order("updated_at desc").Find(&notes)
+
+	writeExport(c, family.Name, exportItems(notes, "", folderNames(userId, familyId)))
+}
+
+// ExportAccount - GET /api/export/account?format=...
+// 导出个人笔记和所有已加入家庭的笔记
+func ExportAccount(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	var user models.User
+	db.DB.First(&user, "id = ?", userId)
+
+	var personal []models.Note
+	db.DB.Preload("Attachments").
+		Where("user_id = ? AND (family_id IS NULL OR family_id = '')", userId).
+		Order("updated_at desc").Find(&personal)
+	items := exportItems(personal, "personal", folderNames(userId, ""))
+
+	var members []models.FamilyMember
+	db.DB.Preload("Family").Where("user_id = ?", userId).Find(&members)
+	for _, m := range members {
+		var notes []models.Note
+		db.DB.Preload("Attachments").Where("family_id = ?", m.FamilyID).Order("updated_at desc").Find(&notes)
+		dir := path.Join("families", export.SafeName(m.Family.Name))
+		items = append(items, exportItems(notes, dir, folderNames(userId, m.FamilyID))...)
+	}
+
+	writeExport(c, "GoNote "+user.Username, items)
+}
+
+// folderIndex 文件夹 ID 到名称的映射，没有 Folder 记录时直接用 ID
+type folderIndex map[string]string
+
+func folderNames(userId, familyId string) folderIndex {
+	var folders []models.Folder
+	if familyId != "" {
+		db.DB.Where("family_id = ?", familyId).Find(&folders)
+	} else {
+		db.DB.Where("user_id = ? AND (family_id IS NULL OR family_id = '')", userId).Find(&folders)
+	}
+	idx := folderIndex{}
+	for _, f := range folders {
+		idx[f.ID] = f.Name
+	}
+	return idx
+}
+
+func (idx folderIndex) name(id string) string {
+	if name, ok := idx[id]; ok && name != "" {
+		return name
+	}
+	return id
+}
+
+func exportItems(notes []models.Note, base string, folders folderIndex) []export.Item {
+	items := make([]export.Item, 0, len(notes))
+	for _, n := range notes {
+		dir := base
+		if n.FolderID != "" {
+			dir = path.Join(base, export.SafeName(folders.name(n.FolderID)))
+		}
+		items = append(items, export.Item{Note: n, Dir: dir})
+	}
+	return items
+}
+
+func openAttachment(a models.Attachment) (io.ReadCloser, error) {
+	return storage.Blobs.Open(context.Background(), a.FilePath)
+}
+
+// writeExport 按 format 参数输出导出文件，默认 Markdown 压缩包
+func writeExport(c *gin.Context, title string, items []export.Item) {
+	base := export.SafeName(title) + "-" + time.Now().Format("20060102")
+
+	var (
+		ext, contentType string
+		write            func(w io.Writer) error
+	)
+	switch c.DefaultQuery("format", "zip") {
+	case "zip", "markdown":
+		ext, contentType = ".zip", "application/zip"
+		write = func(w io.Writer) error { return export.WriteMarkdownZip(w, items, openAttachment) }
+	case "html":
+		ext, contentType = ".html", "text/html; charset=utf-8"
+		for i := range items {
+			items[i].Usernames = mentionUsernames(db.DB, &items[i].Note)
+		}
+		write = func(w io.Writer) error { return export.WriteHTML(w, title, items, openAttachment) }
+	case "pdf":
+		ext, contentType = ".pdf", "application/pdf"
+		write = func(w io.Writer) error { return export.WritePDF(w, title, items, openAttachment) }
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be zip, html or pdf"})
+		return
+	}
+
+	// 先写入临时文件，出错时还能返回错误状态，不会给客户端一个截断的文件。
+	// 无法打开的附件不会中断导出，导出文件中会注明
+	tmp, err := os.CreateTemp("", "gonote-export-*")
+	if err != nil {
+		log.Printf("ERROR: export %q failed: %v", title, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "导出失败"})
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if err := write(tmp); err != nil {
+		if errors.Is(err, export.ErrNoCJKFont) {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("ERROR: export %q failed: %v", title, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "导出失败"})
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": base + ext}))
+	http.ServeContent(c.Writer, c.Request, base+ext, time.Now(), tmp)
+}
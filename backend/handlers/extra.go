@@ -1,57 +1,30 @@
 package handlers
 
 import (
-	"fmt"
+	"gonote/anchor"
 	"gonote/db"
 	"gonote/models"
 	"net/http"
-	"os"
-	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-// UploadFile - POST /api/upload
-func UploadFile(c *gin.Context) {
-	file, err := c.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
-		return
-	}
-
-	// Create uploads dir if not exists
-	uploadDir := "./uploads"
-	if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
-		os.Mkdir(uploadDir, 0755)
-	}
-
-	// Generate unique filename
-	filename := fmt.Sprintf("%d_%s", time.Now().UnixNano(), file.Filename)
-	dst := filepath.Join(uploadDir, filename)
-
-	if err := c.SaveUploadedFile(file, dst); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
-		return
-	}
-
-	// Construct public URL
-	// In production this should be a variable base URL
-	url := "/uploads/" + filename
-
-	c.JSON(http.StatusOK, gin.H{
-		"url":      url,
-		"filename": file.Filename,
-		"type":     file.Header.Get("Content-Type"),
-		"size":     file.Size,
-	})
-}
-
 // AddComment - POST /api/notes/:id/comments
+// 能访问笔记的用户都可以评论；parentId 不为空时为回复
 func AddComment(c *gin.Context) {
 	noteId := c.Param("id")
 	userId := c.GetString("userId")
 
+	note, err := loadAccessibleNote(noteId, userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+
 	// Get Username for snapshot
 	var user models.User
 	if err := db.DB.Where("id = ?", userId).First(&user).Error; err != nil {
@@ -62,15 +35,22 @@ func AddComment(c *gin.Context) {
 	var req struct {
 		Content    string `json:"content"`
 		QuotedText string `json:"quotedText"`
+		ParentID   string `json:"parentId"`
+		// 引用文本的起始位置，笔记中有多处相同文本时用于区分
+		AnchorStart *int `json:"anchorStart"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if strings.TrimSpace(req.Content) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Comment content is required"})
+		return
+	}
 
 	comment := models.Comment{
-		ID:         fmt.Sprintf("c-%d", time.Now().UnixNano()),
+		ID:         "c-" + uuid.New().String(),
 		NoteID:     noteId,
 		UserID:     userId,
 		Username:   user.Username,
@@ -79,10 +59,272 @@ func AddComment(c *gin.Context) {
 		CreatedAt:  time.Now(),
 	}
 
+	if req.ParentID != "" {
+		parent, err := findComment(noteId, req.ParentID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Parent comment not found"})
+			return
+		}
+		// 回复的回复挂在同一主题下，只保留一层
+		rootId := parent.ID
+		if parent.ParentID != nil {
+			rootId = *parent.ParentID
+		}
+		comment.ParentID = &rootId
+		comment.QuotedText = ""
+	} else if req.QuotedText != "" {
+		hint := -1
+		if req.AnchorStart != nil {
+			hint = *req.AnchorStart
+		}
+		// 找不到时（客户端内容尚未保存）在下次保存笔记时定位
+		if a, ok := anchor.Locate(note.Content, req.QuotedText, hint); ok {
+			setCommentAnchor(&comment, a)
+		}
+	}
+
 	if err := db.DB.Create(&comment).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save comment"})
 		return
 	}
+	publishComment(note, &comment)
+	// 被 @ 的用户只收到提及通知，不再重复收到评论通知
+	mentioned := newMentions(db.DB, note, "", comment.Content)
+	notifyMentions(db.DB, note, userId, comment.Content, mentioned)
+	notifyCommentCreated(db.DB, note, &comment, mentioned...)
 
 	c.JSON(http.StatusCreated, comment)
 }
+
+// GetComments - GET /api/notes/:id/comments?resolved=true|false
+// 按讨论串返回评论，回复在主题评论的 replies 中；resolved 为空时返回全部
+func GetComments(c *gin.Context) {
+	noteId := c.Param("id")
+	userId := c.GetString("userId")
+
+	if _, err := loadAccessibleNote(noteId, userId); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+
+	query := db.DB.Where("note_id = ? AND parent_id IS NULL", noteId)
+	switch c.Query("resolved") {
+	case "":
+	case "true":
+		query = query.Where("resolved = ?", true)
+	case "false":
+		query = query.Where("resolved = ?", false)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "resolved must be true or false"})
+		return
+	}
+
+	threads := []models.Comment{}
+	if err := query.Order("created_at asc").Find(&threads).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
+		return
+	}
+	if len(threads) > 0 {
+		ids := make([]string, len(threads))
+		index := make(map[string]int, len(threads))
+		for i, t := range threads {
+			ids[i] = t.ID
+			index[t.ID] = i
+		}
+		var replies []models.Comment
+		db.DB.Where("parent_id IN ?", ids).Order("created_at asc").Find(&replies)
+		for _, r := range replies {
+			i := index[*r.ParentID]
+			threads[i].Replies = append(threads[i].Replies, r)
+		}
+	}
+	fillCommentReactions(threads, userId)
+
+	c.JSON(http.StatusOK, threads)
+}
+
+// UpdateComment - PUT /api/notes/:id/comments/:commentId
+// 评论作者或笔记作者可以修改内容，与删除相同
+func UpdateComment(c *gin.Context) {
+	userId := c.GetString("userId")
+	note, comment, ok := loadCommentForUser(c)
+	if !ok {
+		return
+	}
+	if comment.UserID != userId && note.UserID != userId {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No permission to edit this comment"})
+		return
+	}
+
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Comment content is required"})
+		return
+	}
+
+	now := time.Now()
+	comment.Content = req.Content
+	comment.EditedAt = &now
+	if err := db.DB.Model(comment).Updates(map[string]interface{}{"content": comment.Content, "edited_at": now}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
+	c.JSON(http.StatusOK, comment)
+}
+
+// DeleteComment - DELETE /api/notes/:id/comments/:commentId
+// 评论作者或笔记作者可以删除；删除主题评论时同时删除全部回复
+func DeleteComment(c *gin.Context) {
+	userId := c.GetString("userId")
+	note, comment, ok := loadCommentForUser(c)
+	if !ok {
+		return
+	}
+	if comment.UserID != userId && note.UserID != userId {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No permission to delete this comment"})
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		ids := []string{comment.ID}
+		if comment.ParentID == nil {
+			var replies []string
+			if err := tx.Model(&models.Comment{}).Where("parent_id = ?", comment.ID).Pluck("id", &replies).Error; err != nil {
+				return err
+			}
+			ids = append(ids, replies...)
+		}
+		if err := tx.Where("target_type = ? AND target_id IN ?", reactionTargetComment, ids).Delete(&models.Reaction{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&models.Comment{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted"})
+}
+
+// ResolveComment - PUT /api/notes/:id/comments/:commentId/resolve
+// 请求体为 {"resolved": true}；可编辑笔记的用户和主题评论作者可以解决或重新打开讨论
+func ResolveComment(c *gin.Context) {
+	userId := c.GetString("userId")
+	note, comment, ok := loadCommentForUser(c)
+	if !ok {
+		return
+	}
+	if comment.ParentID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only top-level comments can be resolved"})
+		return
+	}
+	if comment.UserID != userId && !canEditNote(note, userId) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No permission to resolve this comment"})
+		return
+	}
+
+	var req struct {
+		Resolved bool `json:"resolved"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{"resolved": req.Resolved, "resolved_by": "", "resolved_at": nil}
+	comment.Resolved, comment.ResolvedBy, comment.ResolvedAt = req.Resolved, "", nil
+	if req.Resolved {
+		now := time.Now()
+		updates["resolved_by"], updates["resolved_at"] = userId, now
+		comment.ResolvedBy, comment.ResolvedAt = userId, &now
+	}
+	if err := db.DB.Model(comment).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
+	c.JSON(http.StatusOK, comment)
+}
+
+func setCommentAnchor(comment *models.Comment, a anchor.Anchor) {
+	comment.AnchorStart, comment.AnchorEnd = &a.Start, &a.End
+	comment.AnchorPrefix, comment.AnchorSuffix = a.Prefix, a.Suffix
+	comment.Orphaned = false
+}
+
+// reanchorComments 笔记内容从 oldContent 改为 newContent 后重新定位评论锚点。
+// 引用文本已删除的评论标记为 orphaned，之后原文恢复时重新关联
+func reanchorComments(tx *gorm.DB, noteId, oldContent, newContent string) error {
+	if oldContent == newContent {
+		return nil
+	}
+	var comments []models.Comment
+	err := tx.Where("note_id = ? AND parent_id IS NULL AND quoted_text <> ''", noteId).Find(&comments).Error
+	if err != nil {
+		return err
+	}
+
+	old, cur := []rune(oldContent), []rune(newContent)
+	for _, cm := range comments {
+		var (
+			a  anchor.Anchor
+			ok bool
+		)
+		switch {
+		case cm.AnchorStart == nil || cm.AnchorEnd == nil:
+			// 旧评论或创建时没有定位到
+			a, ok = anchor.Locate(newContent, cm.QuotedText, -1)
+		case cm.Orphaned:
+			a, ok = anchor.Find(cur, []rune(cm.QuotedText), cm.AnchorPrefix, cm.AnchorSuffix, *cm.AnchorStart, false)
+		default:
+			a, ok = anchor.Reanchor(old, cur, anchor.Anchor{
+				Start: *cm.AnchorStart, End: *cm.AnchorEnd, Prefix: cm.AnchorPrefix, Suffix: cm.AnchorSuffix,
+			})
+		}
+
+		var updates map[string]interface{}
+		switch {
+		case ok:
+			updates = map[string]interface{}{
+				"anchor_start": a.Start, "anchor_end": a.End,
+				"anchor_prefix": a.Prefix, "anchor_suffix": a.Suffix, "orphaned": false,
+			}
+		case !cm.Orphaned:
+			updates = map[string]interface{}{"orphaned": true}
+		default:
+			continue
+		}
+		if err := tx.Model(&cm).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func findComment(noteId, commentId string) (*models.Comment, error) {
+	var comment models.Comment
+	if err := db.DB.Where("id = ? AND note_id = ?", commentId, noteId).First(&comment).Error; err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// loadCommentForUser 检查笔记访问权限并加载评论，失败时已写入响应
+func loadCommentForUser(c *gin.Context) (*models.Note, *models.Comment, bool) {
+	note, err := loadAccessibleNote(c.Param("id"), c.GetString("userId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return nil, nil, false
+	}
+	comment, err := findComment(note.ID, c.Param("commentId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return nil, nil, false
+	}
+	return note, comment, true
+}
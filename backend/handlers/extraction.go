@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"gonote/db"
+	"gonote/extract"
+	"gonote/models"
+	"gonote/storage"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxExtractSize 超过该大小的附件不提取文本
+const maxExtractSize = 64 << 20
+
+// 读取附件失败后的重试间隔，每次翻倍，最长 maxExtractRetryDelay
+const (
+	extractRetryDelay    = time.Minute
+	maxExtractRetryDelay = 24 * time.Hour
+)
+
+// extractionWake 有新附件时唤醒提取任务
+var extractionWake = make(chan struct{}, 1)
+
+// queueTextExtraction 通知后台提取新附件的文本，不阻塞
+func queueTextExtraction() {
+	select {
+	case extractionWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartTextExtraction 启动后台文本提取：有新附件时立即执行，否则每隔 interval 检查一次
+func StartTextExtraction(interval time.Duration) {
+	go func() {
+		for {
+			extractPendingTexts(context.Background())
+			select {
+			case <-extractionWake:
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+// extractableAttachments 类型支持提取、且内容尚未提取过（或读取失败已到重试时间）的附件
+func extractableAttachments(tx *gorm.DB) *gorm.DB {
+	var conds []string
+	var args []interface{}
+	for _, t := range extract.Types {
+		conds = append(conds, "type = ? OR type LIKE ?")
+		args = append(args, t, t+";%")
+	}
+	return tx.Where("hash <> '' AND NOT EXISTS (SELECT 1 FROM blob_texts WHERE blob_texts.hash = attachments.hash"+
+		" AND (blob_texts.status <> ? OR blob_texts.next_attempt_at > ?))", "retry", time.Now()).
+		Where(strings.Join(conds, " OR "), args...)
+}
+
+// extractPendingTexts 按内容逐个提取，相同内容只提取一次
+func extractPendingTexts(ctx context.Context) {
+	for {
+		var pending []models.Attachment
+		err := extractableAttachments(db.DB.Model(&models.Attachment{})).
+			Select("hash, MIN(file_path) AS file_path, MIN(type) AS type, MIN(size) AS size").
+			Group("hash").Limit(20).Find(&pending).Error
+		if err != nil {
+			log.Printf("ERROR: text extraction: %v", err)
+			return
+		}
+		if len(pending) == 0 {
+			break
+		}
+		for _, a := range pending {
+			text := extractAttachmentText(ctx, a)
+			switch text.Status {
+			case "failed":
+				log.Printf("WARN: text extraction: %s: %s", a.Hash, text.Error)
+			case "retry":
+				var previous models.BlobText
+				db.DB.Where("hash = ?", a.Hash).Limit(1).Find(&previous)
+				text.Attempts = previous.Attempts + 1
+				next := time.Now().Add(extractBackoff(text.Attempts))
+				text.NextAttemptAt = &next
+				log.Printf("WARN: text extraction: %s: %s (attempt %d, retry at %s)",
+					a.Hash, text.Error, text.Attempts, next.Format(time.RFC3339))
+			}
+			if err := db.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&text).Error; err != nil {
+				log.Printf("ERROR: text extraction: %s: %v", a.Hash, err)
+				return
+			}
+		}
+	}
+}
+
+// extractAttachmentText 读取存储失败时返回 retry，稍后重试；
+// 文件过大或内容无法解析时返回 failed，不再重试
+func extractAttachmentText(ctx context.Context, a models.Attachment) models.BlobText {
+	result := models.BlobText{Hash: a.Hash, Status: "failed"}
+	if a.Size > maxExtractSize {
+		result.Error = "file too large"
+		return result
+	}
+	obj, err := storage.Blobs.Open(ctx, a.FilePath)
+	if err != nil {
+		result.Status = "retry"
+		result.Error = err.Error()
+		return result
+	}
+	data, err := io.ReadAll(io.LimitReader(obj, maxExtractSize))
+	obj.Close()
+	if err != nil {
+		result.Status = "retry"
+		result.Error = err.Error()
+		return result
+	}
+	text, err := extract.Text(data, a.Type)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Status = "done"
+	result.Text = text
+	return result
+}
+
+// extractBackoff 第 attempts 次读取失败后的等待时间
+func extractBackoff(attempts int) time.Duration {
+	delay := extractRetryDelay
+	for i := 1; i < attempts && delay < maxExtractRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxExtractRetryDelay)
+}
+
+// searchNotes 标题、正文或附件中提取的文本包含关键词
+func searchNotes(query *gorm.DB, search string) *gorm.DB {
+	like := "%" + search + "%"
+	return query.Where("title LIKE ? OR content LIKE ? OR EXISTS (SELECT 1 FROM attachments"+
+		" JOIN blob_texts ON blob_texts.hash = attachments.hash"+
+		" WHERE attachments.note_id = notes.id AND blob_texts.text LIKE ?)", like, like, like)
+}
+
+// fillAttachmentMatches 为搜索结果填充内容匹配的附件和匹配位置附近的文本
+func fillAttachmentMatches(notes []models.Note, search string) {
+	if len(notes) == 0 || search == "" {
+		return
+	}
+	ids := make([]string, len(notes))
+	for i, n := range notes {
+		ids[i] = n.ID
+	}
+	var rows []struct {
+		ID     string
+		NoteID string
+		Name   string
+		Text   string
+	}
+	db.DB.Table("attachments").
+		Select("attachments.id, attachments.note_id, attachments.name, blob_texts.text").
+		Joins("JOIN blob_texts ON blob_texts.hash = attachments.hash").
+		Where("attachments.note_id IN ? AND blob_texts.text LIKE ?", ids, "%"+search+"%").
+		Order("attachments.created_at").
+		Scan(&rows)
+
+	pattern := regexp.MustCompile("(?i)" + regexp.QuoteMeta(search))
+	matches := map[string][]models.AttachmentMatch{}
+	for _, r := range rows {
+		matches[r.NoteID] = append(matches[r.NoteID], models.AttachmentMatch{
+			AttachmentID: r.ID,
+			Name:         r.Name,
+			Snippet:      snippet(r.Text, pattern),
+		})
+	}
+	for i := range notes {
+		notes[i].MatchedAttachments = matches[notes[i].ID]
+	}
+}
+
+// snippet 截取匹配位置前后的文本
+func snippet(text string, pattern *regexp.Regexp) string {
+	const before, after = 40, 80
+	loc := pattern.FindStringIndex(text)
+	if loc == nil {
+		loc = []int{0, 0}
+	}
+	head := []rune(text[:loc[0]])
+	tail := []rune(text[loc[0]:])
+
+	prefix := ""
+	if len(head) > before {
+		head = head[len(head)-before:]
+		prefix = "…"
+	}
+	suffix := ""
+	if len(tail) > after {
+		tail = tail[:after]
+		suffix = "…"
+	}
+	s := prefix + string(head) + string(tail) + suffix
+	return strings.Join(strings.Fields(s), " ")
+}
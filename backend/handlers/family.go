@@ -97,6 +97,8 @@ func JoinFamily(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "加入家庭失败"})
 		return
 	}
+	publishFamilyMember(eventFamilyJoined, family.ID, userId)
+	notifyFamilyJoined(db.DB, &family, userId)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":  "成功加入家庭",
@@ -134,6 +136,8 @@ func LeaveFamily(c *gin.Context) {
 	if count == 0 {
 		db.DB.Delete(&models.Family{}, "id = ?", req.FamilyID)
 	}
+	// 退出的用户的其他设备也需要更新
+	publishFamilyMember(eventFamilyLeft, req.FamilyID, userId, userId)
 
 	c.JSON(http.StatusOK, gin.H{"message": "已退出家庭"})
 }
@@ -193,7 +197,8 @@ func GetFamilyMembers(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
-// GetFamilyNotes - 获取指定家庭的共享笔记
+// GetFamilyNotes - 获取指定家庭的共享笔记，当前用户置顶的笔记在前
+// search 参数与 GetNotes 相同，同时匹配附件内容
 func GetFamilyNotes(c *gin.Context) {
 	userId := c.GetString("userId")
 	familyId := c.Param("id")
@@ -206,12 +211,21 @@ func GetFamilyNotes(c *gin.Context) {
 	}
 
 	var notes []models.Note
-	db.DB.Where("family_id = ?", familyId).Order("updated_at desc").Find(&notes)
+	query := filterArchived(db.DB.Where("family_id = ?", familyId), c.Query("archived"))
+	search := c.Query("search")
+	if search != "" {
+		query = searchNotes(query, search)
+	}
+	pinnedFirst(query, userId).Find(&notes)
+	fillNoteStates(notes, userId)
+	fillAttachmentMatches(notes, search)
+	fillNoteReactions(notes, userId)
 
 	c.JSON(http.StatusOK, notes)
 }
 
 // GetFamilyEvents - 获取指定家庭的共享事件
+// 支持与 GetEvents 相同的 start / end / tz 参数展开重复事件
 func GetFamilyEvents(c *gin.Context) {
 	userId := c.GetString("userId")
 	familyId := c.Param("id")
@@ -223,8 +237,31 @@ func GetFamilyEvents(c *gin.Context) {
 		return
 	}
 
+	rng, err := parseEventRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var events []models.Event
-	db.DB.Where("family_id = ?", familyId).Order("date asc").Find(&events)
+	query := db.DB.Where("family_id = ?", familyId).Order("date asc")
+	if rng != nil {
+		query = rangeQuery(query, rng)
+	}
+	query.Find(&events)
 
+	if rng != nil {
+		events = expandEvents(events, rng)
+	}
+	fillEventReminders(events)
 	c.JSON(http.StatusOK, events)
 }
+
+// findFamilyMember 查询用户在指定家庭中的成员记录，不是成员时返回错误
+func findFamilyMember(familyId, userId string) (*models.FamilyMember, error) {
+	var member models.FamilyMember
+	if err := db.DB.Where("family_id = ? AND user_id = ?", familyId, userId).First(&member).Error; err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gonote/db"
+	"gonote/importer"
+	"gonote/models"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ImportNotes - POST /api/import
+// 上传 zip（Obsidian 仓库 / Notion 导出 / 含 .enex 的压缩包）或单个 .enex 文件，
+// 创建后台导入任务，通过 GetImportJob 轮询进度
+func ImportNotes(c *gin.Context) {
+	userId := c.GetString("userId")
+	familyId := c.PostForm("familyId")
+	source := importer.Source(c.DefaultPostForm("source", string(importer.SourceAuto)))
+
+	switch source {
+	case importer.SourceAuto, importer.SourceObsidian, importer.SourceNotion, importer.SourceEvernote:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source must be auto, obsidian, notion or evernote"})
+		return
+	}
+	if familyId != "" {
+		if _, err := findFamilyMember(familyId, userId); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "您不是该家庭的成员"})
+			return
+		}
+	}
+
+	if uploadLimits.MaxImport > 0 {
+		// 留出 multipart 表单其他部分的空间
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, uploadLimits.MaxImport+1<<20)
+	}
+	file, err := c.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) || (err == nil && uploadLimits.MaxImport > 0 && file.Size > uploadLimits.MaxImport) {
+		writeUploadError(c, fileTooLarge(uploadLimits.MaxImport))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if ext != ".zip" && ext != ".enex" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only .zip and .enex files can be imported"})
+		return
+	}
+
+	// 上传内容先落到临时文件，任务结束后删除
+	tmp, err := os.CreateTemp("", "gonote-import-*"+ext)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save upload"})
+		return
+	}
+	tmp.Close()
+	if err := c.SaveUploadedFile(file, tmp.Name()); err != nil {
+		os.Remove(tmp.Name())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save upload"})
+		return
+	}
+
+	job := models.ImportJob{
+		ID:       "imp-" + uuid.New().String(),
+		UserID:   userId,
+		Filename: file.Filename,
+		Source:   string(source),
+		Status:   models.ImportPending,
+	}
+	if familyId != "" {
+		job.FamilyID = &familyId
+	}
+	if err := db.DB.Create(&job).Error; err != nil {
+		os.Remove(tmp.Name())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create import job"})
+		return
+	}
+
+	go runImportJob(job, tmp.Name())
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetImportJob - GET /api/import/:id
+func GetImportJob(c *gin.Context) {
+	var job models.ImportJob
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Param("id"), c.GetString("userId")).First(&job).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import job not found"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// FailInterruptedImports 服务重启时，将上次未完成的导入任务标记为失败
+func FailInterruptedImports() {
+	now := time.Now()
+	db.DB.Model(&models.ImportJob{}).
+		Where("status IN ?", []models.ImportStatus{models.ImportPending, models.ImportRunning}).
+		Updates(map[string]interface{}{"status": models.ImportFailed, "error": "服务重启，导入已中断", "finished_at": now})
+}
+
+func runImportJob(job models.ImportJob, tmpPath string) {
+	defer os.Remove(tmpPath)
+
+	fail := func(err error) {
+		log.Printf("ERROR: import job %s failed: %v", job.ID, err)
+		now := time.Now()
+		db.DB.Model(&job).Updates(map[string]interface{}{"status": models.ImportFailed, "error": err.Error(), "finished_at": now})
+	}
+	// 解析器遇到异常文件时 panic 不能让整个服务退出
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("ERROR: import job %s panicked: %v\n%s", job.ID, r, debug.Stack())
+			fail(errors.New("导入文件格式异常，无法解析"))
+		}
+	}()
+
+	result, err := importer.Open(tmpPath, job.Filename, importer.Source(job.Source), uploadLimits.MaxImport)
+	if errors.Is(err, importer.ErrTooLarge) {
+		fail(fmt.Errorf("解压后的内容超过导入大小限制（%s）", formatBytes(uploadLimits.MaxImport)))
+		return
+	}
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer result.Close()
+
+	db.DB.Model(&job).Updates(map[string]interface{}{
+		"status": models.ImportRunning,
+		"source": string(result.Source),
+		"total":  len(result.Notes),
+	})
+
+	familyId := ""
+	if job.FamilyID != nil {
+		familyId = *job.FamilyID
+	}
+	folders := map[string]string{}
+	imported := 0
+
+	for i, n := range result.Notes {
+		if err := importNote(job.UserID, familyId, n, folders); err != nil {
+			log.Printf("WARN: import job %s: note %q skipped: %v", job.ID, n.Title, err)
+		} else {
+			imported++
+		}
+		db.DB.Model(&job).Updates(map[string]interface{}{"processed": i + 1, "imported": imported})
+	}
+
+	now := time.Now()
+	db.DB.Model(&job).Updates(map[string]interface{}{"status": models.ImportDone, "finished_at": now})
+	recordAudit(db.DB, job.UserID, "note.import", "import", job.ID, map[string]interface{}{
+		"source":   result.Source,
+		"filename": job.Filename,
+		"imported": imported,
+		"total":    len(result.Notes),
+	})
+}
+
+// importNote 保存一篇导入的笔记及其附件，folders 缓存 目录路径 -> 文件夹 ID
+func importNote(userId, familyId string, in importer.Note, folders map[string]string) error {
+	note := models.Note{
+		ID:        "n-" + uuid.New().String(),
+		UserID:    userId,
+		Title:     in.Title,
+		Content:   in.Content,
+		CreatedAt: in.Created,
+		UpdatedAt: in.Updated,
+	}
+	if familyId != "" {
+		note.FamilyID = &familyId
+	}
+	if note.UpdatedAt.IsZero() {
+		note.UpdatedAt = note.CreatedAt
+	}
+
+	if in.Folder != "" {
+		folderId, err := importFolder(userId, familyId, in.Folder, folders)
+		if err != nil {
+			return err
+		}
+		note.FolderID = folderId
+	}
+
+	// 先保存附件文件，把正文中的占位地址替换为真实 URL。
+	// 类型不允许、超过大小或配额的附件跳过，正文中保留文件名
+	ctx := context.Background()
+	attachments := make([]models.Attachment, 0, len(in.Attachments))
+	urls := make([]string, len(in.Attachments))
+	var added int64
+	for i, a := range in.Attachments {
+		name := sanitizeFilename(a.Name)
+		blob, contentType, width, height, err := saveImportedAttachment(ctx, a)
+		if err == nil {
+			err = checkQuota(userId, &note, added+blob.Size)
+		}
+		var rejected *uploadError
+		if errors.As(err, &rejected) {
+			log.Printf("WARN: import: attachment %q of %q skipped: %v", a.Name, in.Title, err)
+			urls[i] = name
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		added += blob.Size
+
+		id := "a-" + uuid.New().String()
+		url := attachmentURL(id)
+		attachments = append(attachments, models.Attachment{
+			ID:       id,
+			NoteID:   note.ID,
+			UserID:   userId,
+			Name:     name,
+			Type:     contentType,
+			Size:     blob.Size,
+			FilePath: blob.Key,
+			Hash:     blob.Hash,
+			Width:    width,
+			Height:   height,
+			URL:      url,
+		})
+		urls[i] = url
+	}
+	note.Content = importer.ReplacePlaceholders(note.Content, urls)
+
+	// 失败时已保存的文件没有附件引用，由 GC 清理
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&note).Error; err != nil {
+			return err
+		}
+		if len(attachments) > 0 {
+			return tx.Create(&attachments).Error
+		}
+		return nil
+	})
+	if err == nil && len(attachments) > 0 {
+		queueTextExtraction()
+	}
+	return err
+}
+
+// saveImportedAttachment 与普通上传相同：检测类型、限制大小、去除图片元数据
+func saveImportedAttachment(ctx context.Context, a importer.Attachment) (storedBlob, string, int, int, error) {
+	r, err := a.Open()
+	if err != nil {
+		return storedBlob{}, "", 0, 0, err
+	}
+	defer r.Close()
+
+	contentType, body, err := sniffUpload(r)
+	if err != nil {
+		return storedBlob{}, "", 0, 0, err
+	}
+	clean, width, height, err := sanitizeImage(contentType, limitUpload(body, contentType))
+	if err != nil {
+		return storedBlob{}, "", 0, 0, err
+	}
+	blob, err := saveUpload(ctx, contentType, clean)
+	return blob, contentType, width, height, err
+}
+
+// importFolder 查找或创建导入目录对应的文件夹，名称为完整的相对路径
+func importFolder(userId, familyId, dir string, cache map[string]string) (string, error) {
+	if id, ok := cache[dir]; ok {
+		return id, nil
+	}
+
+	var folder models.Folder
+	query := db.DB.Where("name = ?", dir)
+	if familyId != "" {
+		query = query.Where("family_id = ?", familyId)
+	} else {
+		query = query.Where("user_id = ? AND (family_id IS NULL OR family_id = '')", userId)
+	}
+	err := query.First(&folder).Error
+	if err == gorm.ErrRecordNotFound {
+		folder = models.Folder{
+			ID:     fmt.Sprintf("f-%s", uuid.New().String()[:8]),
+			UserID: userId,
+			Name:   dir,
+			Icon:   "📁",
+			Type:   "user",
+		}
+		if familyId != "" {
+			folder.FamilyID = &familyId
+			folder.Type = "family"
+		}
+		err = db.DB.Create(&folder).Error
+	}
+	if err != nil {
+		return "", err
+	}
+	cache[dir] = folder.ID
+	return folder.ID, nil
+}
@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"bytes"
+	"gonote/db"
+	"gonote/models"
+	"net/http"
+	"text/template"
+	"time"
+	_ "time/tzdata" // 保证无系统时区数据的环境也能解析用户时区
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const journalDateLayout = "2006-01-02"
+
+// defaultJournalTemplate 用户和家庭都未配置模板时使用
+const defaultJournalTemplate = `# {{.Date}} {{.Weekday}}
+{{if .Events}}
+## 今日事件
+{{range .Events}}
+- {{.Title}}{{end}}
+{{end}}
+## 今日记录
+
+`
+
+var chineseWeekdays = [...]string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"}
+
+// journalTemplateData 日记模板可用的变量
+type journalTemplateData struct {
+	Date     string
+	Weekday  string
+	Username string
+	Family   string
+	Events   []models.Event
+}
+
+// GetTodayJournal - GET /api/journal/today?familyId=...&tz=...
+// 查找或创建用户（或家庭）今天的日记，"今天" 按用户时区计算
+func GetTodayJournal(c *gin.Context) {
+	userId := c.GetString("userId")
+	familyId := c.Query("familyId")
+
+	var user models.User
+	if err := db.DB.First(&user, "id = ?", userId).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "User not found"})
+		return
+	}
+
+	var family *models.Family
+	if familyId != "" {
+		if _, err := findFamilyMember(familyId, userId); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "您不是该家庭的成员"})
+			return
+		}
+		family = &models.Family{}
+		if err := db.DB.First(family, "id = ?", familyId).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "家庭不存在"})
+			return
+		}
+	}
+
+	loc, err := resolveLocation(c.Query("tz"), user.Timezone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid timezone"})
+		return
+	}
+	today := time.Now().In(loc)
+	date := today.Format(journalDateLayout)
+
+	if note, err := findJournalNote(userId, familyId, date); err == nil {
+		c.JSON(http.StatusOK, note)
+		return
+	} else if err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch journal"})
+		return
+	}
+
+	// 渲染模板
+	dayStart := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, loc)
+	events, _ := journalDayEvents(userId, familyId, dayStart)
+	data := journalTemplateData{
+		Date:     date,
+		Weekday:  chineseWeekdays[today.Weekday()],
+		Username: user.Username,
+		Events:   events,
+	}
+	tmpl := user.JournalTemplate
+	if family != nil {
+		data.Family = family.Name
+		tmpl = family.JournalTemplate
+	}
+	content, err := renderJournalTemplate(tmpl, data)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render journal template"})
+		return
+	}
+
+	note := models.Note{
+		ID:          "n-" + uuid.New().String(),
+		UserID:      userId,
+		Title:       date + " 日记",
+		Content:     content,
+		JournalDate: &date,
+	}
+	if family != nil {
+		note.FamilyID = &family.ID
+	}
+
+	// 同时打开日记的请求由唯一索引保证只创建一篇，没有插入时返回已创建的那篇
+	res := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&note)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create journal"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		existing, err := findJournalNote(userId, familyId, date)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch journal"})
+			return
+		}
+		c.JSON(http.StatusOK, existing)
+		return
+	}
+	publishNote(eventNoteCreated, &note, userId)
+	c.JSON(http.StatusCreated, note)
+}
+
+// GetJournalCalendar - GET /api/journal/calendar?month=2026-01&familyId=...
+// 返回指定月份中有日记的日期，用于日历视图标记
+func GetJournalCalendar(c *gin.Context) {
+	userId := c.GetString("userId")
+	familyId := c.Query("familyId")
+
+	month, err := time.Parse("2006-01", c.Query("month"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "month must be YYYY-MM"})
+		return
+	}
+	if familyId != "" {
+		if _, err := findFamilyMember(familyId, userId); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "您不是该家庭的成员"})
+			return
+		}
+	}
+
+	first := month.Format(journalDateLayout)
+	last := month.AddDate(0, 1, -1).Format(journalDateLayout)
+
+	var entries []struct {
+		ID          string `json:"noteId"`
+		JournalDate string `json:"date"`
+		Title       string `json:"title"`
+	}
+	err = journalScope(userId, familyId).Model(&models.Note{}).
+		Select("id, journal_date, title").
+		Where("journal_date BETWEEN ? AND ?", first, last).
+		Order("journal_date asc").
+		Scan(&entries).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch journal calendar"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"month": c.Query("month"),
+		"days":  entries,
+	})
+}
+
+// GetJournalDay - GET /api/journal/day/:date?familyId=...&tz=...
+// 日视图：返回当天的日记（可能为空）和当天的事件
+func GetJournalDay(c *gin.Context) {
+	userId := c.GetString("userId")
+	familyId := c.Query("familyId")
+	date := c.Param("date")
+
+	if familyId != "" {
+		if _, err := findFamilyMember(familyId, userId); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "您不是该家庭的成员"})
+			return
+		}
+	}
+
+	var user models.User
+	db.DB.First(&user, "id = ?", userId)
+	loc, err := resolveLocation(c.Query("tz"), user.Timezone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid timezone"})
+		return
+	}
+	dayStart, err := time.ParseInLocation(journalDateLayout, date, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+		return
+	}
+
+	var journal *models.Note
+	if note, err := findJournalNote(userId, familyId, date); err == nil {
+		journal = note
+	} else if err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch journal"})
+		return
+	}
+
+	events, err := journalDayEvents(userId, familyId, dayStart)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"date":    date,
+		"journal": journal,
+		"events":  events,
+	})
+}
+
+// UpdateJournalSettings - PUT /api/journal/settings
+// 更新时区和日记模板；指定 familyId 时更新家庭模板（仅家庭创建者）
+func UpdateJournalSettings(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	var req struct {
+		FamilyID string  `json:"familyId"`
+		Timezone *string `json:"timezone"`
+		Template *string `json:"template"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Template != nil && *req.Template != "" {
+		if _, err := template.New("journal").Parse(*req.Template); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "模板格式错误: " + err.Error()})
+			return
+		}
+	}
+
+	if req.FamilyID != "" {
+		member, err := findFamilyMember(req.FamilyID, userId)
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "您不是该家庭的成员"})
+			return
+		}
+		if member.Role != "owner" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "只有家庭创建者可以修改家庭日记模板"})
+			return
+		}
+		if req.Template != nil {
+			db.DB.Model(&models.Family{}).Where("id = ?", req.FamilyID).Update("journal_template", *req.Template)
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Settings updated"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Timezone != nil {
+		if _, err := time.LoadLocation(*req.Timezone); err != nil || *req.Timezone == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid timezone"})
+			return
+		}
+		updates["timezone"] = *req.Timezone
+	}
+	if req.Template != nil {
+		updates["journal_template"] = *req.Template
+	}
+	if len(updates) > 0 {
+		if err := db.DB.Model(&models.User{}).Where("id = ?", userId).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update settings"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Settings updated"})
+}
+
+// journalScope 个人日记按作者隔离，家庭日记在家庭内共享
+func journalScope(userId, familyId string) *gorm.DB {
+	if familyId != "" {
+		return db.DB.Where("family_id = ?", familyId)
+	}
+	return db.DB.Where("user_id = ? AND (family_id IS NULL OR family_id = '')", userId)
+}
+
+func findJournalNote(userId, familyId, date string) (*models.Note, error) {
+	var note models.Note
+	err := journalScope(userId, familyId).
+		Preload("Attachments").Preload("Comments").Preload("Collaborators").
+		Where("journal_date = ?", date).
+		First(&note).Error
+	if err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// journalDayEvents 返回从 dayStart 开始的一整天内的事件
+func journalDayEvents(userId, familyId string, dayStart time.Time) ([]models.Event, error) {
+	query := personalEventsQuery(userId)
+	if familyId != "" {
+		query = db.DB.Where("family_id = ?", familyId)
+	}
+
+	events := make([]models.Event, 0)
+	err := query.
+		Where("date >= ? AND date < ?", dayStart.UTC(), dayStart.AddDate(0, 0, 1).UTC()).
+		Order("date asc").
+		Find(&events).Error
+	return events, err
+}
+
+func renderJournalTemplate(tmpl string, data journalTemplateData) (string, error) {
+	if tmpl == "" {
+		tmpl = defaultJournalTemplate
+	}
+	t, err := template.New("journal").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// resolveLocation 优先使用请求参数中的时区，其次是用户设置，最后回退到 Asia/Shanghai
+func resolveLocation(requested, userTimezone string) (*time.Location, error) {
+	name := requested
+	if name == "" {
+		name = userTimezone
+	}
+	if name == "" {
+		name = "Asia/Shanghai"
+	}
+	return time.LoadLocation(name)
+}
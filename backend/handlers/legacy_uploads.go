@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"gonote/db"
+	"gonote/models"
+	"gonote/storage"
+	"io"
+	"log"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/google/uuid"
+)
+
+// legacyUploadPattern 旧版本 POST /api/upload 把文件保存在 ./uploads 根目录，
+// 前端写入正文的是 "http://host/uploads/<时间戳>_<文件名>"。文件名可能含空格，
+// 先尽量多地匹配，再由 legacyUploadCandidates 逐步缩短
+var legacyUploadPattern = regexp.MustCompile(`(?:https?://[^/\s"'<>()\\]+)?/uploads/([^/"'<>()\\?#\r\n\t]+)`)
+
+const legacyUploadsMigration = "legacy_uploads"
+
+// MigrateLegacyUploads 启动时执行 adoptLegacyUploads，不删除任何文件。全部成功后不再执行：
+// 升级之后写入正文的 /uploads/ 链接不登记，否则知道旧文件名的用户可以把别人的文件挂到自己的笔记上。
+// 有失败时下次启动重试
+func MigrateLegacyUploads() {
+	var done int64
+	db.DB.Model(&models.Migration{}).Where("name = ?", legacyUploadsMigration).Count(&done)
+	if done > 0 {
+		return
+	}
+	n, failed := adoptLegacyUploads(context.Background())
+	if n > 0 {
+		log.Printf("Migrated %d legacy /uploads links to attachments", n)
+	}
+	if failed > 0 {
+		log.Printf("WARN: %d legacy /uploads links could not be migrated, retrying on next start", failed)
+		return
+	}
+	db.DB.Create(&models.Migration{Name: legacyUploadsMigration})
+}
+
+// adoptLegacyUploads 把正文中引用的旧版上传文件登记为附件（按内容保存为 Blob），
+// 并把链接改写为附件地址。返回改写的链接数和失败的数量
+func adoptLegacyUploads(ctx context.Context) (int, int) {
+	var notes []models.Note
+	// 回收站中的笔记恢复后链接也要可用
+	db.DB.Unscoped().Select("id", "user_id", "content").Where("content LIKE ?", "%/uploads/%").Find(&notes)
+
+	total, failed := 0, 0
+	for _, note := range notes {
+		content, n := rewriteLegacyUploads(note.Content, func(name string) (string, int, bool) {
+			a, used, err := adoptLegacyUpload(ctx, note, name)
+			if err != nil {
+				log.Printf("WARN: legacy upload %q in note %s: %v", name, note.ID, err)
+				failed++
+				return "", 0, false
+			}
+			if a == nil {
+				return "", 0, false
+			}
+			return a.URL, used, true
+		})
+		if n == 0 {
+			continue
+		}
+		// 只在正文没有被同时修改时写回，否则下次启动重新处理（附件按 legacy_path 复用）
+		res := db.DB.Unscoped().Model(&models.Note{}).Where("id = ? AND content = ?", note.ID, note.Content).
+			UpdateColumn("content", content)
+		if res.Error != nil || res.RowsAffected == 0 {
+			log.Printf("ERROR: legacy upload: update note %s: %v", note.ID, res.Error)
+			failed++
+			continue
+		}
+		total += n
+	}
+	return total, failed
+}
+
+// rewriteLegacyUploads 替换正文中的旧链接。adopt 返回新地址和实际使用的文件名长度，
+// 文件名之后的部分保持不变；/api/uploads/（tus 上传地址）不处理
+func rewriteLegacyUploads(content string, adopt func(name string) (string, int, bool)) (string, int) {
+	var b strings.Builder
+	last, n := 0, 0
+	for _, m := range legacyUploadPattern.FindAllStringSubmatchIndex(content, -1) {
+		start, nameStart, nameEnd := m[0], m[2], m[3]
+		if strings.HasSuffix(content[:start], "/api") {
+			continue
+		}
+		newURL, used, ok := adopt(content[nameStart:nameEnd])
+		if !ok {
+			continue
+		}
+		b.WriteString(content[last:start])
+		b.WriteString(newURL)
+		last = nameStart + used
+		n++
+	}
+	if n == 0 {
+		return content, 0
+	}
+	b.WriteString(content[last:])
+	return b.String(), n
+}
+
+// adoptLegacyUpload 找到或创建旧文件对应的附件。name 为链接中匹配到的文件名，
+// 可能带有后续文本，返回实际使用的长度；文件不存在时返回 nil
+func adoptLegacyUpload(ctx context.Context, note models.Note, name string) (*models.Attachment, int, error) {
+	for _, candidate := range legacyUploadCandidates(name) {
+		key := candidate
+		if unescaped, err := url.PathUnescape(candidate); err == nil {
+			key = unescaped
+		}
+		if key == "" || key != path.Base(key) || strings.HasPrefix(key, ".") {
+			continue
+		}
+
+		var existing models.Attachment
+		if db.DB.Where("note_id = ? AND legacy_path = ?", note.ID, key).Limit(1).Find(&existing).RowsAffected > 0 {
+			return &existing, len(candidate), nil
+		}
+
+		obj, err := storage.Blobs.Open(ctx, key)
+		if err == storage.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		a, err := saveLegacyUpload(ctx, note, key, obj)
+		obj.Close()
+		if err != nil {
+			return nil, 0, err
+		}
+		return a, len(candidate), nil
+	}
+	return nil, 0, nil
+}
+
+// legacyUploadCandidates 按空格从长到短截断，"1_a b.png 的截图" 依次尝试
+// "1_a b.png 的截图"、"1_a b.png"、"1_a"
+func legacyUploadCandidates(name string) []string {
+	name = strings.TrimRight(name, " .,;:!?，。；：！？、")
+	var candidates []string
+	for name != "" {
+		candidates = append(candidates, name)
+		i := strings.LastIndexByte(name, ' ')
+		if i < 0 {
+			break
+		}
+		name = strings.TrimRight(name[:i], " .,;:!?，。；：！？、")
+	}
+	return candidates
+}
+
+// saveLegacyUpload 按内容保存旧文件并创建附件。旧文件上传时没有类型检查，
+// 这里只检测类型，不按白名单拒绝，避免丢失已有数据
+func saveLegacyUpload(ctx context.Context, note models.Note, key string, r io.Reader) (*models.Attachment, error) {
+	head := make([]byte, 3072)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+	contentType := mimetype.Detect(head).String()
+
+	blob, err := saveUpload(ctx, contentType, io.MultiReader(bytes.NewReader(head), r))
+	if err != nil {
+		return nil, err
+	}
+
+	// 旧文件名为 "<时间戳>_<原文件名>"
+	name := key
+	if i := strings.IndexByte(key, '_'); i > 0 && strings.Trim(key[:i], "0123456789") == "" {
+		name = key[i+1:]
+	}
+	id := "a-" + uuid.New().String()
+	attachment := models.Attachment{
+		ID:         id,
+		NoteID:     note.ID,
+		UserID:     note.UserID,
+		Name:       sanitizeFilename(name),
+		Type:       contentType,
+		Size:       blob.Size,
+		FilePath:   blob.Key,
+		Hash:       blob.Hash,
+		LegacyPath: key,
+		URL:        attachmentURL(id),
+	}
+	if err := db.DB.Create(&attachment).Error; err != nil {
+		return nil, err
+	}
+	recordLegacyBlob(key)
+	generateThumbnailsAsync(attachment)
+	queueTextExtraction()
+	return &attachment, nil
+}
+
+// legacyUploadReferenced 正文中仍有指向该文件的旧链接（迁移失败），GC 删除旧文件时跳过
+func legacyUploadReferenced(key string) bool {
+	if strings.Contains(key, "/") {
+		return false
+	}
+	pattern := "%/uploads/" + escapeLike(key) + "%"
+	var count int64
+	db.DB.Unscoped().Model(&models.Note{}).Where(`content LIKE ? ESCAPE '\'`, pattern).Count(&count)
+	if count == 0 && url.PathEscape(key) != key {
+		db.DB.Unscoped().Model(&models.Note{}).
+			Where(`content LIKE ? ESCAPE '\'`, "%/uploads/"+escapeLike(url.PathEscape(key))+"%").Count(&count)
+	}
+	return count > 0
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"gonote/lunar"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lunarDateResponse 农历日期及其中文名
+type lunarDateResponse struct {
+	lunar.Date
+	MonthName string `json:"monthName"`
+	DayName   string `json:"dayName"`
+	Label     string `json:"label"`
+	Solar     string `json:"solar"` // 对应的公历日期 2006-01-02
+}
+
+func newLunarDateResponse(d lunar.Date, solar time.Time) lunarDateResponse {
+	return lunarDateResponse{
+		Date:      d,
+		MonthName: d.MonthName(),
+		DayName:   d.DayName(),
+		Label:     d.MonthName() + d.DayName(),
+		Solar:     solar.Format("2006-01-02"),
+	}
+}
+
+// SolarToLunar - GET /api/lunar/from-solar?date=2026-02-17
+// 公历日期转农历
+func SolarToLunar(c *gin.Context) {
+	t, err := time.Parse("2006-01-02", c.Query("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected 2006-01-02"})
+		return
+	}
+	d, err := lunar.FromSolar(t)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, newLunarDateResponse(d, t))
+}
+
+// LunarToSolar - GET /api/lunar/to-solar?year=2026&month=4&day=8&leap=false
+// 农历日期转公历，日期不存在（如小月三十、该年没有的闰月）时返回 400
+func LunarToSolar(c *gin.Context) {
+	var d lunar.Date
+	var err error
+	if d.Year, err = strconv.Atoi(c.Query("year")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year"})
+		return
+	}
+	if d.Month, err = strconv.Atoi(c.Query("month")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid month"})
+		return
+	}
+	if d.Day, err = strconv.Atoi(c.Query("day")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid day"})
+		return
+	}
+	d.Leap = c.Query("leap") == "true"
+
+	t, err := d.Solar(time.UTC)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, newLunarDateResponse(d, t))
+}
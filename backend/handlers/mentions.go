@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"fmt"
+	"gonote/models"
+	"gonote/render"
+	"log"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// parseMentions 返回文本中 @ 到的用户 ID（去重，保持出现顺序）。
+// 边界规则和最长用户名匹配与渲染共用 render.MentionBoundary / render.MatchMention，
+// 通知的人和页面上高亮的人一致；users 须按用户名长度从长到短排列
+func parseMentions(text string, users []models.User) []string {
+	usernames := make([]string, len(users))
+	byName := make(map[string]string, len(users))
+	for i, u := range users {
+		usernames[i] = u.Username
+		byName[u.Username] = u.ID
+	}
+	var ids []string
+	for i := 0; i < len(text); i++ {
+		if text[i] != '@' {
+			continue
+		}
+		if prev, _ := utf8.DecodeLastRuneInString(text[:i]); i > 0 && !render.MentionBoundary(prev) {
+			continue
+		}
+		name := render.MatchMention(text[i+1:], usernames)
+		if name == "" {
+			continue
+		}
+		if id := byName[name]; !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+		i += len(name)
+	}
+	return ids
+}
+
+// mentionCandidates 能访问笔记的用户，按用户名从长到短排列
+func mentionCandidates(tx *gorm.DB, note *models.Note) []models.User {
+	var users []models.User
+	if err := tx.Select("id", "username").Where("id IN ?", noteAudience(tx, note)).Find(&users).Error; err != nil {
+		log.Printf("ERROR: failed to load users of note %s: %v", note.ID, err)
+		return nil
+	}
+	slices.SortFunc(users, func(a, b models.User) int { return len(b.Username) - len(a.Username) })
+	return users
+}
+
+// mentionUsernames 渲染笔记时能被 @ 高亮的用户名，与 mentionCandidates 相同的用户和顺序
+func mentionUsernames(tx *gorm.DB, note *models.Note) []string {
+	users := mentionCandidates(tx, note)
+	usernames := make([]string, len(users))
+	for i, u := range users {
+		usernames[i] = u.Username
+	}
+	return usernames
+}
+
+// newMentions newText 中新增的 @（oldText 中已有的不算），解析为能访问笔记的用户 ID。
+// 不存在的用户名和无权访问笔记的用户被忽略
+func newMentions(tx *gorm.DB, note *models.Note, oldText, newText string) []string {
+	if !strings.Contains(newText, "@") {
+		return nil
+	}
+	users := mentionCandidates(tx, note)
+	previous := parseMentions(oldText, users)
+	var ids []string
+	for _, id := range parseMentions(newText, users) {
+		if !slices.Contains(previous, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// recordNoteMentions 记录笔记正文中的 @，返回之前没有记录过的用户
+func recordNoteMentions(tx *gorm.DB, noteId string, userIds []string) []string {
+	if len(userIds) == 0 {
+		return nil
+	}
+	var existing []string
+	if err := tx.Model(&models.NoteMention{}).Where("note_id = ? AND user_id IN ?", noteId, userIds).Pluck("user_id", &existing).Error; err != nil {
+		log.Printf("ERROR: failed to load mentions of note %s: %v", noteId, err)
+		return nil
+	}
+	var added []string
+	var rows []models.NoteMention
+	for _, id := range userIds {
+		if !slices.Contains(existing, id) {
+			added = append(added, id)
+			rows = append(rows, models.NoteMention{NoteID: noteId, UserID: id})
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+		log.Printf("ERROR: failed to record mentions of note %s: %v", noteId, err)
+		return nil
+	}
+	return added
+}
+
+// notifyMentions 通知被 @ 的用户；message 为评论内容，在笔记正文中被提及时为空
+func notifyMentions(tx *gorm.DB, note *models.Note, actorId, message string, userIds []string) {
+	if len(userIds) == 0 {
+		return
+	}
+	where := "笔记"
+	if message != "" {
+		where = "评论"
+	}
+	notify(tx, models.Notification{
+		Type:       models.NotificationMention,
+		Title:      fmt.Sprintf("%s 在《%s》的%s中提到了你", usernameOf(tx, actorId), noteTitle(note), where),
+		Message:    message,
+		ActorID:    actorId,
+		TargetType: "note",
+		TargetID:   note.ID,
+	}, userIds...)
+}
@@ -1,15 +1,25 @@
 package handlers
 
 import (
+	"fmt"
 	"gonote/db"
+	"gonote/middleware"
 	"gonote/models"
+	"gonote/render"
+	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// GetNotes - GET /api/notes?folderId=...
-// 返回用户自己的笔记
+// GetNotes - GET /api/notes?folderId=...&archived=...&favorite=...&search=...
+// 返回用户自己的笔记，置顶笔记在前；默认不包含已归档笔记。
+// search 同时匹配附件中提取的文本，匹配的附件在 matchedAttachments 中返回
 func GetNotes(c *gin.Context) {
 	userId := c.GetString("userId")
 	folderId := c.Query("folderId")
@@ -26,36 +36,75 @@ func GetNotes(c *gin.Context) {
 	}
 
 	if search != "" {
-		query = query.Where("title LIKE ? OR content LIKE ?", "%"+search+"%", "%"+search+"%")
+		query = searchNotes(query, search)
 	}
 
-	if err := query.Preload("Attachments").Preload("Comments").Preload("Collaborators").Order("updated_at desc").Find(&notes).Error; err != nil {
+	query = filterArchived(query, c.Query("archived"))
+	if c.Query("favorite") == "true" {
+		query = query.Where("EXISTS (SELECT 1 FROM note_user_states s WHERE s.note_id = notes.id AND s.user_id = ? AND s.favorite)", userId)
+	}
+
+	if err := pinnedFirst(query, userId).Preload("Attachments").Preload("Comments").Preload("Collaborators").Find(&notes).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notes"})
 		return
 	}
 
+	fillNoteStates(notes, userId)
+	fillAttachmentMatches(notes, search)
+	fillNoteReactions(notes, userId)
 	c.JSON(http.StatusOK, notes)
 }
 
+// createNoteRequest 创建笔记时客户端可以设置的字段。附件、评论、协作者、版本、归档和日记日期
+// 都有各自的接口，不能随创建请求写入（GORM 会把请求中的附件、评论改挂到新笔记上）
+type createNoteRequest struct {
+	ID               string  `json:"id"`
+	Title            string  `json:"title"`
+	Content          string  `json:"content"`
+	FolderID         string  `json:"folderId"`
+	FamilyID         *string `json:"familyId"`
+	IsPublic         bool    `json:"isPublic"`
+	PublicPermission string  `json:"publicPermission"`
+}
+
 // CreateNote - POST /api/notes
 func CreateNote(c *gin.Context) {
-	var note models.Note
-	if err := c.ShouldBindJSON(&note); err != nil {
+	var req createNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	note.UserID = c.GetString("userId")
-	// Ensure ID is set (if not provided by frontend)
+	note := models.Note{
+		ID:               req.ID,
+		UserID:           c.GetString("userId"),
+		FamilyID:         req.FamilyID,
+		FolderID:         req.FolderID,
+		Title:            req.Title,
+		Content:          req.Content,
+		IsPublic:         req.IsPublic,
+		PublicPermission: req.PublicPermission,
+	}
+	if note.FamilyID != nil && *note.FamilyID == "" {
+		note.FamilyID = nil
+	}
+	familyId := ""
+	if note.FamilyID != nil {
+		familyId = *note.FamilyID
+	}
+	if !checkTargetSpace(c, note.UserID, familyId, note.FolderID) {
+		return
+	}
+	// 前端离线创建时自带 ID，未提供时生成
 	if note.ID == "" {
-		// In real app, use UUID lib. For now, assume frontend sends ID or simple fallback
-		note.ID = "n-" + note.Title // simplified
+		note.ID = "n-" + uuid.New().String()
 	}
 
-	if err := db.DB.Create(&note).Error; err != nil {
+	if err := db.DB.Omit(clause.Associations).Create(&note).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create note"})
 		return
 	}
+	publishNote(eventNoteCreated, &note, note.UserID)
 	c.JSON(http.StatusCreated, note)
 }
 
@@ -75,17 +124,35 @@ func UpdateNote(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	audience := noteAudience(db.DB, &note)
 
 	// Update fields
+	if note.Title != updateData.Title || note.Content != updateData.Content {
+		note.Version++
+	}
+	if updateData.FolderID != note.FolderID {
+		familyId := ""
+		if note.FamilyID != nil {
+			familyId = *note.FamilyID
+		}
+		if !checkTargetSpace(c, userId, familyId, updateData.FolderID) {
+			return
+		}
+	}
+	oldContent := note.Content
 	note.Title = updateData.Title
 	note.Content = updateData.Content
 	note.UpdatedAt = updateData.UpdatedAt
 	note.FolderID = updateData.FolderID
 	note.IsPublic = updateData.IsPublic
 	note.PublicPermission = updateData.PublicPermission
-	note.FamilyID = updateData.FamilyID
+	// FamilyID 不在此处修改，请使用 MoveNote / CopyNote（会校验家庭成员身份）
 
 	// Update Collaborators (if provided)
+	var previousCollaborators []models.Collaborator
+	if updateData.Collaborators != nil {
+		db.DB.Where("note_id = ?", note.ID).Find(&previousCollaborators)
+	}
 	if len(updateData.Collaborators) > 0 {
 		var collaborators []models.Collaborator
 		for _, c := range updateData.Collaborators {
@@ -94,13 +161,21 @@ func UpdateNote(c *gin.Context) {
 		}
 		// Replace existing collaborators
 		db.DB.Model(&note).Association("Collaborators").Replace(collaborators)
+		notifyCollaboratorsAdded(db.DB, &note, userId, previousCollaborators, collaborators)
 	} else if updateData.Collaborators != nil { // explicitly empty array
 		db.DB.Model(&note).Association("Collaborators").Clear()
 	}
 
 	db.DB.Save(&note)
+	if err := reanchorComments(db.DB, note.ID, oldContent, note.Content); err != nil {
+		log.Printf("ERROR: reanchor comments of note %s: %v", note.ID, err)
+	}
+	// 只通知本次新增、且在该笔记中没有通知过的 @；协作者变更已生效，新加入的协作者也可以被提及
+	mentioned := recordNoteMentions(db.DB, note.ID, newMentions(db.DB, &note, oldContent, note.Content))
+	notifyMentions(db.DB, &note, userId, "", mentioned)
 	// Return updated note with collaborators
 	db.DB.Preload("Attachments").Preload("Comments").Preload("Collaborators").First(&note, "id = ?", note.ID)
+	publishNote(eventNoteUpdated, &note, userId, audience...)
 	c.JSON(http.StatusOK, note)
 }
 
@@ -109,9 +184,237 @@ func DeleteNote(c *gin.Context) {
 	id := c.Param("id")
 	userId := c.GetString("userId")
 
+	// 删除前取得能看到笔记的用户
+	var note models.Note
+	var audience []string
+	if err := db.DB.Where("id = ? AND user_id = ?", id, userId).First(&note).Error; err == nil {
+		audience = noteAudience(db.DB, &note)
+	}
+
 	if err := db.DB.Where("id = ? AND user_id = ?", id, userId).Delete(&models.Note{}).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete note"})
 		return
 	}
+	if audience != nil {
+		publishNoteDeleted(&note, userId, audience)
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Note deleted"})
 }
+
+// renderCache 渲染结果缓存，键为 (笔记 ID, 版本, 能被提及的用户名)
+var renderCache = render.NewCache(500)
+
+// RenderNote - GET /api/notes/:id/render
+// 服务端渲染笔记内容为经过过滤的 HTML
+func RenderNote(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	note, err := loadAccessibleNote(c.Param("id"), userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+
+	// 附件地址替换为签名链接，签名按时间段对齐，ETag 随之变化；
+	// 能被 @ 的用户（家庭成员、协作者）变化时同样变化
+	now := time.Now()
+	usernames := mentionUsernames(db.DB, note)
+	etag := fmt.Sprintf(`"%s-%d-%d-%08x"`, note.ID, note.Version, middleware.SignedURLExpiry(now).Unix(), render.UsernamesHash(usernames))
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	html, err := renderCache.Markdown(note.ID, note.Version, note.Content, usernames)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render note"})
+		return
+	}
+
+	var attachments []models.Attachment
+	db.DB.Where("note_id = ?", note.ID).Find(&attachments)
+	for _, a := range attachments {
+		if a.URL != "" {
+			signed, _ := signedAttachmentURL(a.ID, now)
+			html = strings.ReplaceAll(html, a.URL, signed)
+		}
+	}
+
+	c.Header("ETag", etag)
+	c.JSON(http.StatusOK, gin.H{
+		"id":      note.ID,
+		"version": note.Version,
+		"title":   note.Title,
+		"html":    html,
+	})
+}
+
+// SetNotePinned - PUT /api/notes/:id/pin
+func SetNotePinned(c *gin.Context) {
+	updateNoteUserState(c, "pinned")
+}
+
+// SetNoteFavorite - PUT /api/notes/:id/favorite
+func SetNoteFavorite(c *gin.Context) {
+	updateNoteUserState(c, "favorite")
+}
+
+// updateNoteUserState 更新当前用户对笔记的个人状态，请求体为 {"value": true}
+func updateNoteUserState(c *gin.Context, column string) {
+	userId := c.GetString("userId")
+
+	note, err := loadAccessibleNote(c.Param("id"), userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+
+	var req struct {
+		Value bool `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	err = db.DB.Model(&models.NoteUserState{}).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "note_id"}, {Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{column: req.Value, "updated_at": now}),
+	}).Create(map[string]interface{}{
+		"note_id":    note.ID,
+		"user_id":    userId,
+		column:       req.Value,
+		"updated_at": now,
+	}).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update note state"})
+		return
+	}
+
+	var state models.NoteUserState
+	db.DB.First(&state, "note_id = ? AND user_id = ?", note.ID, userId)
+	c.JSON(http.StatusOK, state)
+}
+
+// ArchiveNote - PUT /api/notes/:id/archive
+// 归档状态对所有人生效，仅笔记作者或可编辑的协作者/家庭成员可操作
+func ArchiveNote(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	note, err := loadAccessibleNote(c.Param("id"), userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+	if !canEditNote(note, userId) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No permission to archive this note"})
+		return
+	}
+
+	var req struct {
+		Archived bool `json:"archived"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{"archived": req.Archived, "archived_at": nil}
+	if req.Archived {
+		updates["archived_at"] = time.Now()
+	}
+	if err := db.DB.Model(note).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to archive note"})
+		return
+	}
+
+	publishNote(eventNoteUpdated, note, userId)
+	c.JSON(http.StatusOK, note)
+}
+
+// loadAccessibleNote 加载用户可访问的笔记：作者、所属家庭的成员或协作者
+func loadAccessibleNote(noteId, userId string) (*models.Note, error) {
+	var note models.Note
+	if err := db.DB.First(&note, "id = ?", noteId).Error; err != nil {
+		return nil, err
+	}
+	if !canAccessNote(&note, userId) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &note, nil
+}
+
+// canAccessNote 作者、家庭成员和协作者可以访问
+func canAccessNote(note *models.Note, userId string) bool {
+	if note.UserID == userId {
+		return true
+	}
+	if note.FamilyID != nil && *note.FamilyID != "" {
+		if _, err := findFamilyMember(*note.FamilyID, userId); err == nil {
+			return true
+		}
+	}
+	var count int64
+	db.DB.Model(&models.Collaborator{}).Where("note_id = ? AND user_id = ?", note.ID, userId).Count(&count)
+	return count > 0
+}
+
+// canEditNote 作者、家庭成员和拥有 edit 权限的协作者可以编辑
+func canEditNote(note *models.Note, userId string) bool {
+	if note.UserID == userId {
+		return true
+	}
+	if note.FamilyID != nil && *note.FamilyID != "" {
+		if _, err := findFamilyMember(*note.FamilyID, userId); err == nil {
+			return true
+		}
+	}
+	var count int64
+	db.DB.Model(&models.Collaborator{}).Where("note_id = ? AND user_id = ? AND permission = ?", note.ID, userId, "edit").Count(&count)
+	return count > 0
+}
+
+// filterArchived archived 参数：空 - 排除已归档；true - 只看已归档；all - 全部
+func filterArchived(query *gorm.DB, archived string) *gorm.DB {
+	switch archived {
+	case "all":
+		return query
+	case "true":
+		return query.Where("archived = ?", true)
+	default:
+		return query.Where("archived = ?", false)
+	}
+}
+
+// pinnedFirst 当前用户置顶的笔记排在前面，其余按更新时间倒序
+func pinnedFirst(query *gorm.DB, userId string) *gorm.DB {
+	return query.Order(clause.OrderBy{Expression: clause.Expr{
+		SQL:  "EXISTS (SELECT 1 FROM note_user_states s WHERE s.note_id = notes.id AND s.user_id = ? AND s.pinned) DESC, notes.updated_at DESC",
+		Vars: []interface{}{userId},
+	}})
+}
+
+// fillNoteStates 填充当前用户的置顶/收藏状态
+func fillNoteStates(notes []models.Note, userId string) {
+	if len(notes) == 0 {
+		return
+	}
+	ids := make([]string, len(notes))
+	for i, n := range notes {
+		ids[i] = n.ID
+	}
+
+	var states []models.NoteUserState
+	db.DB.Where("user_id = ? AND note_id IN ?", userId, ids).Find(&states)
+	byNote := make(map[string]models.NoteUserState, len(states))
+	for _, s := range states {
+		byNote[s.NoteID] = s
+	}
+	for i := range notes {
+		if s, ok := byNote[notes[i].ID]; ok {
+			notes[i].Pinned = s.Pinned
+			notes[i].Favorite = s.Favorite
+		}
+	}
+}
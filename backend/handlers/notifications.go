@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"gonote/db"
+	"gonote/models"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultNotificationLimit = 50
+	maxNotificationLimit     = 200
+)
+
+// notify 给 userIds 中的每个用户发送一条通知，跳过触发者本人和重复的用户。
+// 与 recordAudit 一样失败只记录日志，不影响主流程
+func notify(tx *gorm.DB, n models.Notification, userIds ...string) {
+	seen := map[string]bool{n.ActorID: true, "": true}
+	var batch []models.Notification
+	for _, id := range userIds {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		item := n
+		item.ID = "nt-" + uuid.New().String()
+		item.UserID = id
+		item.IsRead = false
+		item.ReadAt = nil
+		batch = append(batch, item)
+	}
+	if len(batch) == 0 {
+		return
+	}
+	if err := tx.Create(&batch).Error; err != nil {
+		log.Printf("ERROR: failed to create %s notifications for %s %s: %v", n.Type, n.TargetType, n.TargetID, err)
+		return
+	}
+	for _, item := range batch {
+		streamHub.Publish(eventNotificationSent, item, item.UserID)
+	}
+}
+
+// notifyCommentCreated 新评论通知笔记作者；回复同时通知主题评论的作者。skip 中的用户不通知
+func notifyCommentCreated(tx *gorm.DB, note *models.Note, comment *models.Comment, skip ...string) {
+	var recipients []string
+	skipped := map[string]bool{}
+	for _, id := range skip {
+		skipped[id] = true
+	}
+	if !skipped[note.UserID] {
+		recipients = append(recipients, note.UserID)
+	}
+	title := fmt.Sprintf("%s 评论了《%s》", comment.Username, noteTitle(note))
+	if comment.ParentID != nil {
+		var root models.Comment
+		if err := tx.Select("user_id").First(&root, "id = ?", *comment.ParentID).Error; err == nil && !skipped[root.UserID] {
+			recipients = append(recipients, root.UserID)
+		}
+		title = fmt.Sprintf("%s 回复了《%s》中的评论", comment.Username, noteTitle(note))
+	}
+	notify(tx, models.Notification{
+		Type:       models.NotificationComment,
+		Title:      title,
+		Message:    comment.Content,
+		ActorID:    comment.UserID,
+		TargetType: "note",
+		TargetID:   note.ID,
+	}, recipients...)
+}
+
+// notifyCollaboratorsAdded 通知新加入的协作者，before 为修改前的协作者
+func notifyCollaboratorsAdded(tx *gorm.DB, note *models.Note, actorId string, before, after []models.Collaborator) {
+	existing := make(map[string]bool, len(before))
+	for _, c := range before {
+		existing[c.UserID] = true
+	}
+	var added []string
+	for _, c := range after {
+		if !existing[c.UserID] {
+			added = append(added, c.UserID)
+		}
+	}
+	if len(added) == 0 {
+		return
+	}
+	notify(tx, models.Notification{
+		Type:       models.NotificationCollaborator,
+		Title:      fmt.Sprintf("%s 邀请你协作《%s》", usernameOf(tx, actorId), noteTitle(note)),
+		ActorID:    actorId,
+		TargetType: "note",
+		TargetID:   note.ID,
+	}, added...)
+}
+
+// notifyFamilyJoined 通知家庭的其他成员有新成员加入
+func notifyFamilyJoined(tx *gorm.DB, family *models.Family, userId string) {
+	notify(tx, models.Notification{
+		Type:       models.NotificationFamily,
+		Title:      fmt.Sprintf("%s 加入了家庭「%s」", usernameOf(tx, userId), family.Name),
+		ActorID:    userId,
+		TargetType: "family",
+		TargetID:   family.ID,
+	}, familyMemberIds(tx, family.ID)...)
+}
+
+// notifyEventReminder 发送事件提醒，接收者为 NotifyUsers，为空时提醒事件创建者。
+// 只发给当前仍能查看事件的用户（例如已退出家庭的成员不再收到）。
+// event 为要提醒的那一次（重复事件展开后的一次），minutes 为提前的分钟数，时间按 loc 显示
+func notifyEventReminder(tx *gorm.DB, event *models.Event, minutes int, loc *time.Location) {
+	var users []string
+	if event.NotifyUsers != "" {
+		if err := json.Unmarshal([]byte(event.NotifyUsers), &users); err != nil {
+			log.Printf("WARN: event %d has invalid notifyUsers: %v", event.ID, err)
+		}
+	}
+	if len(users) == 0 {
+		users = []string{event.UserID}
+	}
+	recipients := make([]string, 0, len(users))
+	for _, id := range users {
+		if canViewEvent(event, id) {
+			recipients = append(recipients, id)
+		}
+	}
+	if len(recipients) == 0 {
+		return
+	}
+	message := event.Date.In(loc).Format("2006-01-02 15:04") + " 开始"
+	if minutes > 0 {
+		message += "（提前 " + formatReminderOffset(minutes) + "提醒）"
+	}
+	if event.Description != "" {
+		message += "\n" + event.Description
+	}
+	notify(tx, models.Notification{
+		Type:       models.NotificationReminder,
+		Title:      "事件提醒：" + event.Title,
+		Message:    message,
+		TargetType: "event",
+		TargetID:   strconv.FormatUint(uint64(event.ID), 10),
+	}, recipients...)
+}
+
+func noteTitle(note *models.Note) string {
+	if note.Title == "" {
+		return "无标题笔记"
+	}
+	return note.Title
+}
+
+func usernameOf(tx *gorm.DB, userId string) string {
+	var user models.User
+	if err := tx.Select("username").First(&user, "id = ?", userId).Error; err != nil {
+		return "有人"
+	}
+	return user.Username
+}
+
+// GetNotifications - GET /api/notifications?unread=true&limit=50&before=<RFC3339>
+// 按时间倒序返回当前用户的通知，before 用于翻页
+func GetNotifications(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	query := db.DB.Where("user_id = ?", userId)
+	if c.Query("unread") == "true" {
+		query = query.Where("is_read = ?", false)
+	}
+	if before := c.Query("before"); before != "" {
+		t, err := time.Parse(time.RFC3339Nano, before)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "before must be an RFC 3339 time"})
+			return
+		}
+		query = query.Where("created_at < ?", t)
+	}
+	limit := defaultNotificationLimit
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = min(n, maxNotificationLimit)
+	}
+
+	notifications := []models.Notification{}
+	if err := query.Order("created_at desc").Limit(limit).Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+	c.JSON(http.StatusOK, notifications)
+}
+
+// GetUnreadNotificationCount - GET /api/notifications/unread-count
+func GetUnreadNotificationCount(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	var count int64
+	if err := db.DB.Model(&models.Notification{}).Where("user_id = ? AND is_read = ?", userId, false).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"count": count})
+}
+
+// MarkNotificationRead - PUT /api/notifications/:id/read
+func MarkNotificationRead(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	var notification models.Notification
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Param("id"), userId).First(&notification).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+	if !notification.IsRead {
+		now := time.Now()
+		notification.IsRead, notification.ReadAt = true, &now
+		if err := db.DB.Model(&notification).Updates(map[string]interface{}{"is_read": true, "read_at": now}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, notification)
+}
+
+// MarkAllNotificationsRead - PUT /api/notifications/read-all
+// 将当前用户的全部未读通知标记为已读，返回更新的数量
+func MarkAllNotificationsRead(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	result := db.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND is_read = ?", userId, false).
+		Updates(map[string]interface{}{"is_read": true, "read_at": time.Now()})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"updated": result.RowsAffected})
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gonote/db"
+	"gonote/models"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+)
+
+// uploadConfig 上传限制，启动时从环境变量读取
+type uploadConfig struct {
+	MaxSize      int64    // 单个文件上限
+	MaxImageSize int64    // 图片上限
+	AllowedTypes []string // 允许的 MIME 类型，"image/*" 表示整类
+	UserQuota    int64    // 个人空间配额，0 表示不限
+	FamilyQuota  int64    // 每个家庭的配额，0 表示不限
+	MaxImport    int64    // 导入文件上限，也是解析时解压读入内存的总量上限
+}
+
+var uploadLimits = loadUploadConfig()
+
+var defaultAllowedTypes = []string{
+	"image/png", "image/jpeg", "image/gif", "image/webp", "image/heic", "image/heif",
+	"audio/*", "video/*",
+	"application/pdf", "text/plain", "text/csv",
+	"application/zip",
+	"application/msword",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"application/vnd.ms-excel",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"application/vnd.ms-powerpoint",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+}
+
+func loadUploadConfig() uploadConfig {
+	cfg := uploadConfig{
+		MaxSize:      envSize("GONOTE_MAX_UPLOAD_SIZE", 50<<20),
+		MaxImageSize: envSize("GONOTE_MAX_IMAGE_SIZE", 20<<20),
+		AllowedTypes: defaultAllowedTypes,
+		UserQuota:    envSize("GONOTE_USER_QUOTA", 1<<30),
+		FamilyQuota:  envSize("GONOTE_FAMILY_QUOTA", 5<<30),
+		MaxImport:    envSize("GONOTE_MAX_IMPORT_SIZE", 512<<20),
+	}
+	if v := os.Getenv("GONOTE_UPLOAD_ALLOWED_TYPES"); v != "" {
+		cfg.AllowedTypes = nil
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				cfg.AllowedTypes = append(cfg.AllowedTypes, t)
+			}
+		}
+	}
+	return cfg
+}
+
+// envSize 读取字节数，支持 K / M / G 后缀（按 1024 计），如 "50M"、"1GB"
+func envSize(key string, def int64) int64 {
+	v := strings.ToUpper(strings.TrimSpace(os.Getenv(key)))
+	if v == "" {
+		return def
+	}
+	v = strings.TrimSuffix(strings.TrimSuffix(v, "B"), "I")
+	mult := int64(1)
+	if n := len(v); n > 0 {
+		switch v[n-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		}
+		if mult > 1 {
+			v = v[:n-1]
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	if err != nil || n < 0 {
+		log.Printf("WARN: invalid %s=%q, using default", key, os.Getenv(key))
+		return def
+	}
+	return n * mult
+}
+
+// uploadError 上传校验失败，Status 为返回给客户端的状态码
+type uploadError struct {
+	Status  int
+	Message string
+}
+
+func (e *uploadError) Error() string { return e.Message }
+
+func fileTooLarge(limit int64) error {
+	return &uploadError{http.StatusRequestEntityTooLarge, fmt.Sprintf("文件超过大小限制（%s）", formatBytes(limit))}
+}
+
+// writeUploadError 按 uploadError 的状态码返回，其他错误按 500 处理
+func writeUploadError(c *gin.Context, err error) {
+	var ue *uploadError
+	if errors.As(err, &ue) {
+		c.JSON(ue.Status, gin.H{"error": ue.Message})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30 && n%(1<<30) == 0:
+		return fmt.Sprintf("%dGB", n>>30)
+	case n >= 1<<20:
+		return fmt.Sprintf("%.0fMB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.0fKB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%dB", n)
+	}
+}
+
+// typeAllowed 检查检测到的类型是否在白名单中，别名（如 application/x-pdf）也算
+func typeAllowed(detected *mimetype.MIME) bool {
+	for _, t := range uploadLimits.AllowedTypes {
+		if prefix, ok := strings.CutSuffix(t, "/*"); ok {
+			if strings.HasPrefix(detected.String(), prefix+"/") {
+				return true
+			}
+		} else if detected.Is(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffUpload 根据文件内容判断类型，不信任客户端提供的 Content-Type。
+// 返回的 Reader 包含已读取的头部，后续应从它读取完整内容
+func sniffUpload(r io.Reader) (string, io.Reader, error) {
+	head := make([]byte, 3072)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", nil, err
+	}
+	head = head[:n]
+
+	detected := mimetype.Detect(head)
+	if !typeAllowed(detected) {
+		return "", nil, &uploadError{http.StatusUnsupportedMediaType, fmt.Sprintf("不支持的文件类型：%s", detected.String())}
+	}
+	return detected.String(), io.MultiReader(bytes.NewReader(head), r), nil
+}
+
+// limitUpload 读取超过上限时返回 fileTooLarge，图片使用单独的上限
+func limitUpload(r io.Reader, mimeType string) io.Reader {
+	limit := uploadLimits.MaxSize
+	if strings.HasPrefix(mimeType, "image/") && uploadLimits.MaxImageSize > 0 && uploadLimits.MaxImageSize < limit {
+		limit = uploadLimits.MaxImageSize
+	}
+	if limit <= 0 {
+		return r
+	}
+	return &limitedReader{r: r, remaining: limit, limit: limit}
+}
+
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	limit     int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, fileTooLarge(l.limit)
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, fileTooLarge(l.limit)
+	}
+	return n, err
+}
+
+// sanitizeFilename 去掉路径、控制字符和 Windows 保留字符，限制长度并保留扩展名
+func sanitizeFilename(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = path.Base(name)
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		return "file"
+	}
+
+	const maxLen = 200
+	if len(name) > maxLen {
+		ext := path.Ext(name)
+		if len(ext) > 20 {
+			ext = ""
+		}
+		stem := name[:maxLen-len(ext)]
+		for !utf8.ValidString(stem) {
+			stem = stem[:len(stem)-1]
+		}
+		name = stem + ext
+	}
+	return name
+}
+
+// storageUsage 附件占用的字节数：个人空间按上传者统计，家庭空间按家庭统计。
+// 按附件逻辑大小计算，去重后共用的文件也分别计入；已删除笔记的附件不计入
+func storageUsage(userId string, familyId string) int64 {
+	var used int64
+	q := db.DB.Model(&models.Attachment{}).
+		Joins("JOIN notes ON notes.id = attachments.note_id AND notes.deleted_at IS NULL").
+		Select("COALESCE(SUM(attachments.size), 0)")
+	if familyId != "" {
+		q = q.Where("notes.family_id = ?", familyId)
+	} else {
+		q = q.Where("attachments.user_id = ? AND (notes.family_id IS NULL OR notes.family_id = '')", userId)
+	}
+	q.Scan(&used)
+	return used
+}
+
+// checkQuota 检查向笔记所在空间再写入 add 字节是否超出配额
+func checkQuota(userId string, note *models.Note, add int64) error {
+	familyId, quota := "", uploadLimits.UserQuota
+	if note.FamilyID != nil && *note.FamilyID != "" {
+		familyId, quota = *note.FamilyID, uploadLimits.FamilyQuota
+	}
+	if quota <= 0 {
+		return nil
+	}
+	if storageUsage(userId, familyId)+add > quota {
+		msg := "个人存储空间不足"
+		if familyId != "" {
+			msg = "家庭存储空间不足"
+		}
+		return &uploadError{http.StatusRequestEntityTooLarge, fmt.Sprintf("%s（配额 %s）", msg, formatBytes(quota))}
+	}
+	return nil
+}
+
+// GetStorageUsage - GET /api/storage/usage
+// 返回个人空间和已加入家庭的附件占用与配额，quota 为 0 表示不限
+func GetStorageUsage(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	var members []models.FamilyMember
+	db.DB.Preload("Family").Where("user_id = ?", userId).Find(&members)
+	families := make([]gin.H, 0, len(members))
+	for _, m := range members {
+		families = append(families, gin.H{
+			"familyId": m.FamilyID,
+			"name":     m.Family.Name,
+			"used":     storageUsage(userId, m.FamilyID),
+			"quota":    uploadLimits.FamilyQuota,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": gin.H{
+			"used":  storageUsage(userId, ""),
+			"quota": uploadLimits.UserQuota,
+		},
+		"families": families,
+		"limits": gin.H{
+			"maxUploadSize": uploadLimits.MaxSize,
+			"maxImageSize":  uploadLimits.MaxImageSize,
+			"allowedTypes":  uploadLimits.AllowedTypes,
+		},
+	})
+}
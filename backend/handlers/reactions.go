@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"fmt"
+	"gonote/db"
+	"gonote/models"
+	"net/http"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxEmojiSize 组合表情（如带肤色的家庭表情）由多个字符组成
+const maxEmojiSize = 32
+
+const (
+	reactionTargetNote    = "note"
+	reactionTargetComment = "comment"
+)
+
+// AddNoteReaction - POST /api/notes/:id/reactions
+// 请求体为 {"emoji": "👍"}；能访问笔记的用户都可以回应，重复回应不报错
+func AddNoteReaction(c *gin.Context) {
+	note, ok := loadReactionNote(c)
+	if !ok {
+		return
+	}
+	addReaction(c, note, reactionTargetNote, note.ID, nil)
+}
+
+// RemoveNoteReaction - DELETE /api/notes/:id/reactions/:emoji
+func RemoveNoteReaction(c *gin.Context) {
+	note, ok := loadReactionNote(c)
+	if !ok {
+		return
+	}
+	removeReaction(c, reactionTargetNote, note.ID)
+}
+
+// AddCommentReaction - POST /api/notes/:id/comments/:commentId/reactions
+func AddCommentReaction(c *gin.Context) {
+	note, comment, ok := loadCommentForUser(c)
+	if !ok {
+		return
+	}
+	addReaction(c, note, reactionTargetComment, comment.ID, comment)
+}
+
+// RemoveCommentReaction - DELETE /api/notes/:id/comments/:commentId/reactions/:emoji
+func RemoveCommentReaction(c *gin.Context) {
+	_, comment, ok := loadCommentForUser(c)
+	if !ok {
+		return
+	}
+	removeReaction(c, reactionTargetComment, comment.ID)
+}
+
+func loadReactionNote(c *gin.Context) (*models.Note, bool) {
+	note, err := loadAccessibleNote(c.Param("id"), c.GetString("userId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return nil, false
+	}
+	return note, true
+}
+
+// addReaction 新增回应时通知笔记或评论的作者，返回该对象的回应统计
+func addReaction(c *gin.Context, note *models.Note, targetType, targetId string, comment *models.Comment) {
+	userId := c.GetString("userId")
+
+	var req struct {
+		Emoji string `json:"emoji"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validEmoji(req.Emoji) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid emoji"})
+		return
+	}
+
+	reaction := models.Reaction{TargetType: targetType, TargetID: targetId, UserID: userId, Emoji: req.Emoji}
+	result := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&reaction)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add reaction"})
+		return
+	}
+	if result.RowsAffected > 0 {
+		notifyReaction(db.DB, note, comment, userId, req.Emoji)
+	}
+	writeReactionCounts(c, targetType, targetId)
+}
+
+func removeReaction(c *gin.Context, targetType, targetId string) {
+	err := db.DB.Where("target_type = ? AND target_id = ? AND user_id = ? AND emoji = ?",
+		targetType, targetId, c.GetString("userId"), c.Param("emoji")).Delete(&models.Reaction{}).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove reaction"})
+		return
+	}
+	writeReactionCounts(c, targetType, targetId)
+}
+
+func writeReactionCounts(c *gin.Context, targetType, targetId string) {
+	counts, err := reactionCounts(db.DB, targetType, []string{targetId}, c.GetString("userId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reactions"})
+		return
+	}
+	result := counts[targetId]
+	if result == nil {
+		result = []models.ReactionCount{}
+	}
+	c.JSON(http.StatusOK, gin.H{"targetType": targetType, "targetId": targetId, "reactions": result})
+}
+
+// validEmoji 只接受表情符号及其组合用字符（零宽连接符、变体选择符、肤色、键帽）
+func validEmoji(s string) bool {
+	if s == "" || len(s) > maxEmojiSize {
+		return false
+	}
+	symbol := false
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.So, r), r == '\u20e3':
+			symbol = true
+		case unicode.In(r, unicode.Sk, unicode.Mn, unicode.Me), r == '\u200d':
+		case r == '#' || r == '*' || (r >= '0' && r <= '9'): // 键帽 #️⃣ 1️⃣
+		default:
+			return false
+		}
+	}
+	return symbol
+}
+
+// reactionCounts 按对象汇总回应，同一对象内按第一次回应的时间排序
+func reactionCounts(tx *gorm.DB, targetType string, ids []string, userId string) (map[string][]models.ReactionCount, error) {
+	counts := map[string][]models.ReactionCount{}
+	if len(ids) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		TargetID string
+		Emoji    string
+		Count    int
+		Reacted  bool
+	}
+	err := tx.Model(&models.Reaction{}).
+		Select("target_id, emoji, COUNT(*) AS count, MAX(CASE WHEN user_id = ? THEN 1 ELSE 0 END) AS reacted", userId).
+		Where("target_type = ? AND target_id IN ?", targetType, ids).
+		Group("target_id, emoji").
+		Order("MIN(created_at), emoji").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		counts[r.TargetID] = append(counts[r.TargetID], models.ReactionCount{Emoji: r.Emoji, Count: r.Count, Reacted: r.Reacted})
+	}
+	return counts, nil
+}
+
+// fillNoteReactions 为笔记列表及已加载的评论填充回应统计
+func fillNoteReactions(notes []models.Note, userId string) {
+	ids := make([]string, len(notes))
+	var commentIds []string
+	for i, n := range notes {
+		ids[i] = n.ID
+		for _, cm := range n.Comments {
+			commentIds = append(commentIds, cm.ID)
+		}
+	}
+	counts, err := reactionCounts(db.DB, reactionTargetNote, ids, userId)
+	if err != nil {
+		return
+	}
+	commentCounts, err := reactionCounts(db.DB, reactionTargetComment, commentIds, userId)
+	if err != nil {
+		return
+	}
+	for i := range notes {
+		notes[i].Reactions = counts[notes[i].ID]
+		for j := range notes[i].Comments {
+			notes[i].Comments[j].Reactions = commentCounts[notes[i].Comments[j].ID]
+		}
+	}
+}
+
+// fillCommentReactions 为讨论串（包括回复）填充回应统计
+func fillCommentReactions(threads []models.Comment, userId string) {
+	var ids []string
+	for _, t := range threads {
+		ids = append(ids, t.ID)
+		for _, r := range t.Replies {
+			ids = append(ids, r.ID)
+		}
+	}
+	counts, err := reactionCounts(db.DB, reactionTargetComment, ids, userId)
+	if err != nil {
+		return
+	}
+	for i := range threads {
+		threads[i].Reactions = counts[threads[i].ID]
+		for j := range threads[i].Replies {
+			threads[i].Replies[j].Reactions = counts[threads[i].Replies[j].ID]
+		}
+	}
+}
+
+// notifyReaction 通知笔记作者（comment 为空时）或评论作者
+func notifyReaction(tx *gorm.DB, note *models.Note, comment *models.Comment, actorId, emoji string) {
+	n := models.Notification{
+		Type:       models.NotificationReaction,
+		ActorID:    actorId,
+		TargetType: "note",
+		TargetID:   note.ID,
+	}
+	recipient := note.UserID
+	if comment != nil {
+		recipient = comment.UserID
+		n.Title = fmt.Sprintf("%s 对你在《%s》中的评论回应了 %s", usernameOf(tx, actorId), noteTitle(note), emoji)
+		n.Message = comment.Content
+	} else {
+		n.Title = fmt.Sprintf("%s 对《%s》回应了 %s", usernameOf(tx, actorId), noteTitle(note), emoji)
+	}
+	notify(tx, n, recipient)
+}
@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"errors"
+	"gonote/db"
+	"gonote/lunar"
+	"gonote/models"
+	"gonote/recur"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// eventRange 事件查询的时间范围 [From, To)。Loc 为查看者的时区，用于解析日期范围和输出，
+// 重复按事件自己的时区展开（见 eventZone）
+type eventRange struct {
+	From, To time.Time
+	Loc      *time.Location
+}
+
+// contains 时间是否在范围内
+func (r *eventRange) contains(t time.Time) bool {
+	return !t.Before(r.From) && t.Before(r.To)
+}
+
+// parseEventRange 解析 start / end / tz 查询参数。
+// start、end 可以是日期（2006-01-02，end 当天包含在内）或 RFC 3339 时间；都没有时返回 nil
+func parseEventRange(c *gin.Context) (*eventRange, error) {
+	start, end := c.Query("start"), c.Query("end")
+	if start == "" && end == "" {
+		return nil, nil
+	}
+	if start == "" || end == "" {
+		return nil, errors.New("start and end must be provided together")
+	}
+
+	loc, err := eventLocation(c)
+	if err != nil {
+		return nil, err
+	}
+
+	rng := &eventRange{Loc: loc}
+	if rng.From, err = parseRangeBound(start, loc, false); err != nil {
+		return nil, err
+	}
+	if rng.To, err = parseRangeBound(end, loc, true); err != nil {
+		return nil, err
+	}
+	if !rng.From.Before(rng.To) {
+		return nil, errors.New("end must be after start")
+	}
+	if rng.To.Sub(rng.From) > maxEventRange {
+		return nil, errors.New("time range must not exceed 5 years")
+	}
+	return rng, nil
+}
+
+// maxEventRange 一次查询展开的最大范围，避免每日重复的事件展开过多
+const maxEventRange = 5*366*24*time.Hour + time.Hour
+
+func parseRangeBound(s string, loc *time.Location, inclusiveDay bool) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		if inclusiveDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.New("Invalid date " + s + ", expected 2006-01-02 or RFC 3339")
+}
+
+// eventLocation 查看者的时区：请求参数 tz，其次是用户设置。用于解析查询范围、输出时间和新事件的默认时区
+func eventLocation(c *gin.Context) (*time.Location, error) {
+	var user models.User
+	db.DB.Select("timezone").First(&user, "id = ?", c.GetString("userId"))
+	loc, err := resolveLocation(c.Query("tz"), user.Timezone)
+	if err != nil {
+		return nil, errors.New("Invalid timezone")
+	}
+	return loc, nil
+}
+
+// zoneCache 已加载的时区，展开重复时每个事件都要用到
+var zoneCache sync.Map
+
+// eventZone 展开事件的重复和计算农历日期使用的时区：事件保存的 TZID，
+// 没有或无法加载时为创建者的时区
+func eventZone(event *models.Event) *time.Location {
+	if event.TZID != "" {
+		if loc, ok := zoneCache.Load(event.TZID); ok {
+			return loc.(*time.Location)
+		}
+		if loc, err := time.LoadLocation(event.TZID); err == nil {
+			zoneCache.Store(event.TZID, loc)
+			return loc
+		}
+	}
+	return ownerLocation(event.UserID)
+}
+
+// rangeQuery 限定查询范围：不重复的事件在范围内，重复的事件在范围结束前开始。
+// 数据库中的时间按文本比较，时区不同时不准确，这里放宽一天，准确的范围由 expandEvents 过滤
+func rangeQuery(query *gorm.DB, rng *eventRange) *gorm.DB {
+	from := rng.From.Add(-24 * time.Hour).UTC()
+	to := rng.To.Add(24 * time.Hour).UTC()
+	return query.Where(
+		"((recurrence IN ? AND (rrule IS NULL OR rrule = '') AND date >= ? AND date < ?) OR ((recurrence NOT IN ? OR rrule <> '') AND date < ?))",
+		nonRecurring, from, to, nonRecurring, to,
+	)
+}
+
+var nonRecurring = []string{"", string(models.RecurrenceNone)}
+
+// isRecurring 设置了重复规则的事件
+func isRecurring(event *models.Event) bool {
+	return event.RRule != "" || !slices.Contains(nonRecurring, string(event.Recurrence))
+}
+
+// occurrenceRule 公历重复规则（recur.Rule）和农历每年重复（lunar.YearlyRule）共同的展开方法
+type occurrenceRule interface {
+	Between(dtstart, from, to time.Time) []time.Time
+	Next(dtstart, after time.Time) (time.Time, bool)
+}
+
+// eventRule 事件的重复规则，RRule 为空时由 Recurrence 推出。
+// 农历事件按农历月日每年重复，loc 用于没有保存农历月日的旧数据从 Date 推出农历日期
+func eventRule(event *models.Event, loc *time.Location) (occurrenceRule, error) {
+	text := event.RRule
+	if text == "" {
+		text = "FREQ=" + strings.ToUpper(string(event.Recurrence))
+	}
+	rule, err := recur.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if event.Type != models.EventTypeLunar {
+		return rule, nil
+	}
+
+	if err := checkLunarRule(rule); err != nil {
+		return nil, err
+	}
+	month, day, leap := event.LunarMonth, event.LunarDay, event.LunarLeap
+	if month == 0 {
+		d, err := lunar.FromSolar(event.Date.In(loc))
+		if err != nil {
+			return nil, err
+		}
+		month, day, leap = d.Month, d.Day, d.Leap
+	}
+	return &lunar.YearlyRule{
+		Month: month, Day: day, Leap: leap,
+		Interval: rule.Interval, Count: rule.Count, Until: rule.Until,
+	}, nil
+}
+
+// checkLunarRule 农历事件只支持每年重复，可以带 INTERVAL、COUNT、UNTIL
+func checkLunarRule(rule *recur.Rule) error {
+	if rule.Freq != recur.Yearly || len(rule.ByDay) > 0 || len(rule.ByMonthDay) > 0 || len(rule.ByMonth) > 0 {
+		return errors.New("lunar events only support yearly recurrence")
+	}
+	return nil
+}
+
+// expandEvents 把重复事件展开为范围内的每一次，应用单独修改并去掉取消的重复，按时间排序
+func expandEvents(events []models.Event, rng *eventRange) []models.Event {
+	var ids []uint
+	for i := range events {
+		if isRecurring(&events[i]) {
+			ids = append(ids, events[i].ID)
+		}
+	}
+	overrides := map[uint][]models.EventOverride{}
+	if len(ids) > 0 {
+		var rows []models.EventOverride
+		db.DB.Where("event_id IN ?", ids).Find(&rows)
+		for _, o := range rows {
+			overrides[o.EventID] = append(overrides[o.EventID], o)
+		}
+	}
+
+	result := []models.Event{}
+	for _, event := range events {
+		if isRecurring(&event) {
+			result = append(result, expandEvent(event, overrides[event.ID], rng)...)
+		} else if rng.contains(event.Date) {
+			result = append(result, event)
+		}
+	}
+	slices.SortStableFunc(result, func(a, b models.Event) int { return a.Date.Compare(b.Date) })
+	return result
+}
+
+// expandEvent 展开单个重复事件。按事件的时区展开，输出的时间换算为 rng.Loc。
+// 规则无法解析时按不重复处理
+func expandEvent(event models.Event, overrides []models.EventOverride, rng *eventRange) []models.Event {
+	loc := eventZone(&event)
+	rule, err := eventRule(&event, loc)
+	if err != nil {
+		log.Printf("event %d: invalid rrule %q: %v", event.ID, event.RRule, err)
+		if rng.contains(event.Date) {
+			return []models.Event{event}
+		}
+		return nil
+	}
+	exdates, err := recur.ParseDates(event.ExDate)
+	if err != nil {
+		log.Printf("event %d: invalid exdate %q: %v", event.ID, event.ExDate, err)
+	}
+	cancelled := map[int64]bool{}
+	for _, t := range exdates {
+		cancelled[t.Unix()] = true
+	}
+	byOccurrence := map[int64]*models.EventOverride{}
+	for i := range overrides {
+		byOccurrence[overrides[i].OccurrenceDate.Unix()] = &overrides[i]
+	}
+
+	var result []models.Event
+	seen := map[int64]bool{}
+	for _, t := range rule.Between(event.Date.In(loc), rng.From, rng.To) {
+		key := t.Unix()
+		seen[key] = true
+		if cancelled[key] {
+			continue
+		}
+		if occurrence := eventOccurrence(event, t.In(rng.Loc), byOccurrence[key]); rng.contains(occurrence.Date) {
+			result = append(result, occurrence)
+		}
+	}
+	// 原本在范围外、被改到范围内的重复
+	for _, o := range byOccurrence {
+		key := o.OccurrenceDate.Unix()
+		if seen[key] || cancelled[key] || o.Date == nil || !rng.contains(*o.Date) {
+			continue
+		}
+		result = append(result, eventOccurrence(event, o.OccurrenceDate.In(rng.Loc), o))
+	}
+	return result
+}
+
+// eventOccurrence 重复事件在 t 的一次，有单独修改时应用修改
+func eventOccurrence(event models.Event, t time.Time, override *models.EventOverride) models.Event {
+	occurrence := t
+	event.OccurrenceDate = &occurrence
+	event.Date = t
+	if override != nil {
+		event.Overridden = true
+		if override.Title != nil {
+			event.Title = *override.Title
+		}
+		if override.Description != nil {
+			event.Description = *override.Description
+		}
+		if override.Date != nil {
+			event.Date = *override.Date
+		}
+	}
+	return event
+}
+
+// isOccurrence t 是否是事件的一次重复（包括已取消的），按事件的时区展开
+func isOccurrence(event *models.Event, t time.Time) bool {
+	loc := eventZone(event)
+	rule, err := eventRule(event, loc)
+	if err != nil {
+		return false
+	}
+	times := rule.Between(event.Date.In(loc), t, t.Add(time.Second))
+	return len(times) > 0 && times[0].Equal(t)
+}
+
+// loadOccurrence 加载可修改的重复事件和 :date 指定的一次，失败时已写入响应。
+// 返回的时区为查看者的时区，用于输出
+func loadOccurrence(c *gin.Context) (*models.Event, time.Time, *time.Location, bool) {
+	event, ok := loadEditableEvent(c)
+	if !ok {
+		return nil, time.Time{}, nil, false
+	}
+	if !isRecurring(event) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Event is not recurring"})
+		return nil, time.Time{}, nil, false
+	}
+	t, err := recur.ParseDate(c.Param("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, time.Time{}, nil, false
+	}
+
+	loc, err := eventLocation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, time.Time{}, nil, false
+	}
+	if !isOccurrence(event, t) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Occurrence not found"})
+		return nil, time.Time{}, nil, false
+	}
+	return event, t.UTC(), loc, true
+}
+
+// occurrencePatch 单独修改一次重复的请求体，为空的字段沿用事件本身
+type occurrencePatch struct {
+	Title       *string    `json:"title"`
+	Description *string    `json:"description"`
+	Date        *time.Time `json:"date"`
+}
+
+// UpdateEventOccurrence - PUT /api/events/:id/occurrences/:date
+// 单独修改重复事件的一次，:date 为该次原本的时间（20060102T150405Z 或 RFC 3339）。
+// 再次修改时替换之前的修改；权限与修改事件相同
+func UpdateEventOccurrence(c *gin.Context) {
+	event, t, loc, ok := loadOccurrence(c)
+	if !ok {
+		return
+	}
+
+	var req occurrencePatch
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Title != nil && strings.TrimSpace(*req.Title) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Event title is required"})
+		return
+	}
+	if slices.Contains(strings.Split(event.ExDate, ","), recur.FormatDate(t)) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Occurrence has been cancelled"})
+		return
+	}
+
+	override := models.EventOverride{EventID: event.ID, OccurrenceDate: t}
+	db.DB.Where("event_id = ? AND occurrence_date = ?", event.ID, t).First(&override)
+	override.Title = req.Title
+	override.Description = req.Description
+	override.Date = req.Date
+	if override.Date != nil {
+		utc := override.Date.UTC()
+		override.Date = &utc
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&override).Error; err != nil {
+			return err
+		}
+		return rescheduleReminders(tx, event)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update occurrence"})
+		return
+	}
+
+	c.JSON(http.StatusOK, eventOccurrence(*event, t.In(loc), &override))
+}
+
+// CancelEventOccurrence - DELETE /api/events/:id/occurrences/:date
+// 取消重复事件的一次：加入 EXDATE，并删除该次的单独修改
+func CancelEventOccurrence(c *gin.Context) {
+	event, t, _, ok := loadOccurrence(c)
+	if !ok {
+		return
+	}
+
+	exdates, _ := recur.ParseDates(event.ExDate)
+	event.ExDate = recur.FormatDates(append(exdates, t))
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("event_id = ? AND occurrence_date = ?", event.ID, t).Delete(&models.EventOverride{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(event).Update("exdate", event.ExDate).Error; err != nil {
+			return err
+		}
+		return rescheduleReminders(tx, event)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel occurrence"})
+		return
+	}
+
+	c.JSON(http.StatusOK, event)
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"gonote/db"
+	"gonote/models"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	maxReminders       = 5
+	maxReminderMinutes = 366 * 24 * 60
+	// reminderCatchUp 服务停止期间错过的提醒，事件开始不超过这么久的仍然补发，更早的跳过
+	reminderCatchUp = 24 * time.Hour
+	// reminderSearchYears 查找下一次重复的最大范围
+	reminderSearchYears = 100
+)
+
+// StartEventReminders 启动事件提醒：每隔 interval 发送到期的提醒。
+// 启动时先执行一次，补发服务停止期间错过的提醒
+func StartEventReminders(interval time.Duration) {
+	go func() {
+		for {
+			SendDueReminders(time.Now())
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// SendDueReminders 发送 NextAt 不晚于 now 的提醒，并把 NextAt 推进到 now 之后的下一次。
+// 推进和创建通知在同一个事务中，且只在 NextAt 未被改动时推进，重启或并发执行都不会重复发送
+func SendDueReminders(now time.Time) {
+	var due []models.EventReminder
+	if err := db.DB.Where("next_at <= ?", now).Order("next_at").Find(&due).Error; err != nil {
+		log.Printf("ERROR: load due reminders: %v", err)
+		return
+	}
+
+	events := map[uint]*models.Event{}
+	for _, r := range due {
+		event, ok := events[r.EventID]
+		if !ok {
+			event = &models.Event{}
+			if err := db.DB.First(event, "id = ?", r.EventID).Error; err != nil {
+				event = nil
+			}
+			events[r.EventID] = event
+		}
+		if event == nil {
+			// 事件已删除
+			db.DB.Delete(&models.EventReminder{}, r.ID)
+			continue
+		}
+		if err := sendReminder(event, r, now); err != nil {
+			log.Printf("ERROR: event %d reminder %d: %v", event.ID, r.ID, err)
+		}
+	}
+}
+
+// sendReminder 处理一个到期的提醒：依次经过 now 之前的每一次，仍在补发范围内的发送通知。
+// 与查询相同，按事件的时区展开，通知中的时间也按该时区显示
+func sendReminder(event *models.Event, r models.EventReminder, now time.Time) error {
+	loc := eventZone(event)
+	var overrides []models.EventOverride
+	db.DB.Where("event_id = ?", event.ID).Find(&overrides)
+	before := time.Duration(r.Minutes) * time.Minute
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		var sent []models.Event
+		next := r.NextAt
+		for next != nil && !next.After(now) {
+			// 提醒时间对应的那一次
+			occurrence, ok := nextOccurrence(event, overrides, next.Add(before-time.Nanosecond), loc)
+			if ok && !occurrence.Date.After(next.Add(before)) && occurrence.Date.After(now.Add(-reminderCatchUp)) {
+				sent = append(sent, occurrence)
+			}
+			next = nextReminderAt(event, overrides, r.Minutes, *next, loc)
+		}
+
+		updates := map[string]any{"next_at": next}
+		if len(sent) > 0 {
+			updates["last_sent_at"] = now
+		}
+		res := tx.Model(&models.EventReminder{}).Where("id = ? AND next_at = ?", r.ID, r.NextAt).Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil // 已由其他执行推进，或事件刚被修改
+		}
+		for i := range sent {
+			notifyEventReminder(tx, &sent[i], r.Minutes, loc)
+		}
+		return nil
+	})
+}
+
+// nextReminderAt 在 after 之后（不含）的下一次提醒时间，没有时返回 nil
+func nextReminderAt(event *models.Event, overrides []models.EventOverride, minutes int, after time.Time, loc *time.Location) *time.Time {
+	before := time.Duration(minutes) * time.Minute
+	occurrence, ok := nextOccurrence(event, overrides, after.Add(before), loc)
+	if !ok {
+		return nil
+	}
+	at := occurrence.Date.Add(-before).UTC()
+	return &at
+}
+
+// nextOccurrence 开始时间在 after 之后（不含）的第一次，已应用单独修改并去掉取消的重复
+func nextOccurrence(event *models.Event, overrides []models.EventOverride, after time.Time, loc *time.Location) (models.Event, bool) {
+	if !isRecurring(event) {
+		return *event, event.Date.After(after)
+	}
+	from := after
+	for i := 0; i < reminderSearchYears; i++ {
+		rng := &eventRange{From: from, To: from.AddDate(1, 0, 0), Loc: loc}
+		occurrences := expandEvent(*event, overrides, rng)
+		slices.SortFunc(occurrences, func(a, b models.Event) int { return a.Date.Compare(b.Date) })
+		for _, o := range occurrences {
+			if o.Date.After(after) {
+				return o, true
+			}
+		}
+		from = rng.To
+	}
+	return models.Event{}, false
+}
+
+// ownerLocation 事件创建者的时区，没有保存时区的事件按它展开重复
+func ownerLocation(userId string) *time.Location {
+	var user models.User
+	db.DB.Select("timezone").First(&user, "id = ?", userId)
+	loc, err := resolveLocation("", user.Timezone)
+	if err != nil {
+		return chinaLocation
+	}
+	return loc
+}
+
+// validateReminders 检查提前的分钟数，去重后按从早到晚排序
+func validateReminders(minutes []int) ([]int, error) {
+	result := slices.Clone(minutes)
+	slices.SortFunc(result, func(a, b int) int { return b - a })
+	result = slices.Compact(result)
+	if len(result) > maxReminders {
+		return nil, fmt.Errorf("At most %d reminders per event", maxReminders)
+	}
+	for _, m := range result {
+		if m < 0 || m > maxReminderMinutes {
+			return nil, errors.New("Reminder minutes must be between 0 and 527040 (366 days)")
+		}
+	}
+	return result, nil
+}
+
+// saveReminders 替换事件的提醒并计算下一次提醒时间
+func saveReminders(tx *gorm.DB, event *models.Event, minutes []int) error {
+	if err := tx.Where("event_id = ?", event.ID).Delete(&models.EventReminder{}).Error; err != nil {
+		return err
+	}
+	event.Reminders = minutes
+	if len(minutes) == 0 {
+		return nil
+	}
+	reminders := make([]models.EventReminder, len(minutes))
+	for i, m := range minutes {
+		reminders[i] = models.EventReminder{EventID: event.ID, Minutes: m}
+	}
+	scheduleReminders(tx, event, reminders)
+	return tx.Create(&reminders).Error
+}
+
+// rescheduleReminders 事件或某次重复修改后，重新计算事件所有提醒的下一次提醒时间，并填充 event.Reminders
+func rescheduleReminders(tx *gorm.DB, event *models.Event) error {
+	var reminders []models.EventReminder
+	if err := tx.Where("event_id = ?", event.ID).Order("minutes desc").Find(&reminders).Error; err != nil {
+		return err
+	}
+	scheduleReminders(tx, event, reminders)
+	event.Reminders = nil
+	for _, r := range reminders {
+		if err := tx.Model(&r).Update("next_at", r.NextAt).Error; err != nil {
+			return err
+		}
+		event.Reminders = append(event.Reminders, r.Minutes)
+	}
+	return nil
+}
+
+// scheduleReminders 从现在起计算每个提醒的下一次提醒时间
+func scheduleReminders(tx *gorm.DB, event *models.Event, reminders []models.EventReminder) {
+	loc := eventZone(event)
+	var overrides []models.EventOverride
+	tx.Where("event_id = ?", event.ID).Find(&overrides)
+	now := time.Now()
+	for i := range reminders {
+		reminders[i].NextAt = nextReminderAt(event, overrides, reminders[i].Minutes, now, loc)
+	}
+}
+
+// fillEventReminders 填充事件的 Reminders 字段
+func fillEventReminders(events []models.Event) {
+	var ids []uint
+	for _, e := range events {
+		if e.ID != 0 {
+			ids = append(ids, e.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	var rows []models.EventReminder
+	db.DB.Where("event_id IN ?", ids).Order("minutes desc").Find(&rows)
+	byEvent := map[uint][]int{}
+	for _, r := range rows {
+		byEvent[r.EventID] = append(byEvent[r.EventID], r.Minutes)
+	}
+	for i := range events {
+		events[i].Reminders = byEvent[events[i].ID]
+	}
+}
+
+// formatReminderOffset 如 "1 天"、"1 小时 30 分钟"
+func formatReminderOffset(minutes int) string {
+	var parts []string
+	if d := minutes / (24 * 60); d > 0 {
+		parts = append(parts, fmt.Sprintf("%d 天", d))
+	}
+	if h := minutes % (24 * 60) / 60; h > 0 {
+		parts = append(parts, fmt.Sprintf("%d 小时", h))
+	}
+	if m := minutes % 60; m > 0 {
+		parts = append(parts, fmt.Sprintf("%d 分钟", m))
+	}
+	return strings.Join(parts, " ")
+}
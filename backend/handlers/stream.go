@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"fmt"
+	"gonote/db"
+	"gonote/middleware"
+	"gonote/models"
+	"gonote/realtime"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// streamHub 推送给在线客户端的事件，保留最近 1000 条用于断线补发
+var streamHub = realtime.NewHub(1000)
+
+// streamHeartbeat 空闲时发送注释行，防止代理断开连接
+const streamHeartbeat = 25 * time.Second
+
+// 推送的事件类型
+const (
+	eventNoteCreated      = "note.created"
+	eventNoteUpdated      = "note.updated"
+	eventNoteDeleted      = "note.deleted"
+	eventNoteRemoved      = "note.removed" // 失去访问权限，只含笔记 ID
+	eventCommentCreated   = "comment.created"
+	eventFamilyJoined     = "family.member_joined"
+	eventFamilyLeft       = "family.member_left"
+	eventNotificationSent = "notification"
+)
+
+// CreateStreamTicket - POST /api/stream/ticket
+// 换取建立事件流用的短期票据：GET /api/stream?ticket=...
+func CreateStreamTicket(c *gin.Context) {
+	ticket, expires := middleware.SignStreamTicket(c.GetString("userId"), time.Now())
+	c.JSON(http.StatusOK, gin.H{"ticket": ticket, "expiresAt": expires})
+}
+
+// Stream - GET /api/stream
+// Server-Sent Events：推送当前用户能看到的笔记、评论、家庭成员变更和通知。
+// 断线重连时浏览器自动带上 Last-Event-ID，服务端补发之后的事件
+func Stream(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	lastEventId := c.GetHeader("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = c.Query("lastEventId")
+	}
+	sub, replay := streamHub.Subscribe(userId, lastEventId)
+	defer streamHub.Unsubscribe(sub)
+
+	h := c.Writer.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no") // nginx 不缓冲
+	c.Status(http.StatusOK)
+	fmt.Fprint(c.Writer, "retry: 3000\n\n")
+	for _, e := range replay {
+		writeStreamEvent(c.Writer, e)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				// 客户端接收过慢被断开，重连后补发
+				return
+			}
+			writeStreamEvent(c.Writer, e)
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+func writeStreamEvent(w gin.ResponseWriter, e realtime.Event) {
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+}
+
+// noteAudience 能看到笔记的用户：作者、所属家庭的成员和协作者
+func noteAudience(tx *gorm.DB, note *models.Note) []string {
+	users := []string{note.UserID}
+	if note.FamilyID != nil && *note.FamilyID != "" {
+		users = append(users, familyMemberIds(tx, *note.FamilyID)...)
+	}
+	var collaborators []string
+	if err := tx.Model(&models.Collaborator{}).Where("note_id = ?", note.ID).Pluck("user_id", &collaborators).Error; err != nil {
+		log.Printf("ERROR: failed to load collaborators of note %s: %v", note.ID, err)
+	}
+	return append(users, collaborators...)
+}
+
+func familyMemberIds(tx *gorm.DB, familyId string) []string {
+	var members []string
+	if err := tx.Model(&models.FamilyMember{}).Where("family_id = ?", familyId).Pluck("user_id", &members).Error; err != nil {
+		log.Printf("ERROR: failed to load members of family %s: %v", familyId, err)
+	}
+	return members
+}
+
+// publishNote 推送笔记变更。previous 为变更前能看到笔记的用户，其中移动、修改协作者后
+// 失去访问权限的用户只收到不含内容的 note.removed
+func publishNote(eventType string, note *models.Note, actorId string, previous ...string) {
+	audience := noteAudience(db.DB, note)
+	streamHub.Publish(eventType, gin.H{"actorId": actorId, "note": note}, audience...)
+
+	var removed []string
+	for _, id := range previous {
+		if !slices.Contains(audience, id) && !slices.Contains(removed, id) {
+			removed = append(removed, id)
+		}
+	}
+	if len(removed) > 0 {
+		streamHub.Publish(eventNoteRemoved, gin.H{
+			"actorId":  actorId,
+			"id":       note.ID,
+			"familyId": note.FamilyID,
+		}, removed...)
+	}
+}
+
+// publishNoteDeleted audience 在删除前取得
+func publishNoteDeleted(note *models.Note, actorId string, audience []string) {
+	streamHub.Publish(eventNoteDeleted, gin.H{
+		"actorId":  actorId,
+		"id":       note.ID,
+		"familyId": note.FamilyID,
+	}, audience...)
+}
+
+func publishComment(note *models.Note, comment *models.Comment) {
+	streamHub.Publish(eventCommentCreated, gin.H{
+		"actorId": comment.UserID,
+		"noteId":  note.ID,
+		"comment": comment,
+	}, noteAudience(db.DB, note)...)
+}
+
+// publishFamilyMember 推送给家庭的全部成员；退出时 previous 中包含退出的用户
+func publishFamilyMember(eventType, familyId, userId string, previous ...string) {
+	users := append(familyMemberIds(db.DB, familyId), previous...)
+	streamHub.Publish(eventType, gin.H{
+		"actorId":  userId,
+		"familyId": familyId,
+		"userId":   userId,
+		"username": usernameOf(db.DB, userId),
+	}, users...)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"fmt"
+	"gonote/holiday"
+	"gonote/models"
+	"gonote/solarterm"
+	"time"
+)
+
+// chinaLocation 节气和节假日按北京时间的日期计算
+var chinaLocation = func() *time.Location {
+	if loc, err := time.LoadLocation("Asia/Shanghai"); err == nil {
+		return loc
+	}
+	return time.FixedZone("CST", 8*3600)
+}()
+
+// systemEvents 范围内的二十四节气和法定节假日，生成为只读的系统事件，不落库。
+// 每天一条，Date 为该日期在 rng.Loc 时区的零点，ID 为 0，用 SystemKey 区分
+func systemEvents(rng *eventRange) []models.Event {
+	var events []models.Event
+
+	// 前后多取一天，交节时刻的北京日期可能与范围的边界不在同一天
+	for _, term := range solarterm.Between(rng.From.AddDate(0, 0, -1), rng.To.AddDate(0, 0, 1)) {
+		at := term.Time.In(chinaLocation)
+		events = append(events, systemEvent(rng.Loc, at, models.EventTypeTerm, term.Name,
+			fmt.Sprintf("交节时间 %s（北京时间）", at.Format("2006-01-02 15:04")),
+			fmt.Sprintf("term-%d-%02d", at.Year(), term.Index), false))
+	}
+
+	from := rng.From.In(rng.Loc)
+	to := rng.To.In(rng.Loc).AddDate(0, 0, 1)
+	for _, day := range holiday.Default().Between(from, to) {
+		title := day.Name
+		description := fmt.Sprintf("%s放假，共 %d 天", holidayRange(day), int(day.End.Sub(day.Start).Hours()/24)+1)
+		key := "holiday-" + day.Date.Format("2006-01-02")
+		if day.Workday {
+			title = day.Name + "调休上班"
+			description = fmt.Sprintf("%s调休，%s上班", day.Name, day.Date.Format("1月2日"))
+			key = "workday-" + day.Date.Format("2006-01-02")
+		}
+		events = append(events, systemEvent(rng.Loc, day.Date, models.EventTypeHoliday, title, description, key, day.Workday))
+	}
+
+	result := events[:0]
+	for _, e := range events {
+		if rng.contains(e.Date) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// systemEvent day 的日期在 loc 时区零点的系统事件
+func systemEvent(loc *time.Location, day time.Time, eventType models.EventType, title, description, key string, workday bool) models.Event {
+	y, m, d := day.Date()
+	return models.Event{
+		Title:       title,
+		Description: description,
+		Date:        time.Date(y, m, d, 0, 0, 0, 0, loc),
+		Type:        eventType,
+		Recurrence:  models.RecurrenceNone,
+		IsSystem:    true,
+		SystemKey:   key,
+		Workday:     workday,
+	}
+}
+
+// holidayRange 如 "2月15日至2月23日"，只有一天时为 "1月1日"
+func holidayRange(day holiday.Day) string {
+	if day.Start.Equal(day.End) {
+		return day.Start.Format("1月2日")
+	}
+	return day.Start.Format("1月2日") + "至" + day.End.Format("1月2日")
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"gonote/db"
+	"gonote/imaging"
+	"gonote/models"
+	"gonote/storage"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// thumbnailSizes 缩略图尺寸，值为长边像素
+var thumbnailSizes = map[string]int{
+	"small":  160,
+	"medium": 480,
+	"large":  1280,
+}
+
+var thumbnailSizeNames = []string{"small", "medium", "large"}
+
+// sanitizeImage 去除图片元数据并读取宽高；不支持的类型原样返回
+func sanitizeImage(contentType string, r io.Reader) (io.Reader, int, int, error) {
+	if !imaging.CanSanitize(contentType) {
+		return r, 0, 0, nil
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	result, err := imaging.Sanitize(data, contentType)
+	if errors.Is(err, imaging.ErrTooLarge) {
+		return nil, 0, 0, &uploadError{http.StatusRequestEntityTooLarge, "图片尺寸过大"}
+	}
+	if err != nil {
+		return nil, 0, 0, &uploadError{http.StatusUnsupportedMediaType, "无法解析图片"}
+	}
+	return bytes.NewReader(result.Data), result.Width, result.Height, nil
+}
+
+// thumbnailLocks 避免同一张图片被并发生成多次
+var thumbnailLocks sync.Map
+
+// ensureThumbnails 为图片附件生成全部尺寸的缩略图（WebP 和 JPEG/PNG 各一份），已存在时跳过
+func ensureThumbnails(ctx context.Context, a models.Attachment) error {
+	if a.Hash == "" || !imaging.Supported(a.Type) {
+		return nil
+	}
+	mu, _ := thumbnailLocks.LoadOrStore(a.Hash, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	defer func() {
+		mu.(*sync.Mutex).Unlock()
+		thumbnailLocks.Delete(a.Hash)
+	}()
+
+	var count int64
+	db.DB.Model(&models.Thumbnail{}).Where("source_hash = ?", a.Hash).Count(&count)
+	if count > 0 {
+		return nil
+	}
+
+	obj, err := storage.Blobs.Open(ctx, a.FilePath)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(obj)
+	obj.Close()
+	if err != nil {
+		return err
+	}
+
+	sizes := make([]int, len(thumbnailSizeNames))
+	for i, name := range thumbnailSizeNames {
+		sizes[i] = thumbnailSizes[name]
+	}
+	formats := []string{"webp", imaging.ThumbnailFormat(a.Type)}
+	results, err := imaging.Thumbnails(data, a.Type, sizes, formats)
+	if err != nil {
+		return err
+	}
+
+	var thumbs []models.Thumbnail
+	for i, name := range thumbnailSizeNames {
+		for j, format := range formats {
+			res := results[i][j]
+			blob, err := saveUpload(ctx, "image/"+format, bytes.NewReader(res.Data))
+			if err != nil {
+				return err
+			}
+			thumbs = append(thumbs, models.Thumbnail{
+				SourceHash: a.Hash,
+				Size:       name,
+				Format:     format,
+				Hash:       blob.Hash,
+				Width:      res.Width,
+				Height:     res.Height,
+			})
+		}
+	}
+	return db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&thumbs).Error
+}
+
+// generateThumbnailsAsync 上传后在后台生成缩略图，失败时请求缩略图时会再次尝试
+func generateThumbnailsAsync(a models.Attachment) {
+	if !imaging.Supported(a.Type) {
+		return
+	}
+	go func() {
+		if err := ensureThumbnails(context.Background(), a); err != nil {
+			log.Printf("ERROR: thumbnails for attachment %s: %v", a.ID, err)
+		}
+	}()
+}
+
+// serveThumbnail 返回指定尺寸的缩略图。format 参数可指定 webp / jpeg / png，
+// 未指定时浏览器支持 WebP 则返回 WebP
+func serveThumbnail(c *gin.Context, a models.Attachment, size string, inline bool) {
+	if _, ok := thumbnailSizes[size]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "size must be small, medium or large"})
+		return
+	}
+	if !imaging.Supported(a.Type) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Attachment is not an image"})
+		return
+	}
+
+	format := c.Query("format")
+	switch format {
+	case "", "webp", imaging.ThumbnailFormat(a.Type):
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be webp or " + imaging.ThumbnailFormat(a.Type)})
+		return
+	}
+	if format == "" {
+		format = imaging.ThumbnailFormat(a.Type)
+		if strings.Contains(c.GetHeader("Accept"), "image/webp") {
+			format = "webp"
+		}
+	}
+
+	// 旧附件或后台生成尚未完成时，在请求中生成
+	if err := ensureThumbnails(c.Request.Context(), a); err != nil {
+		log.Printf("ERROR: thumbnails for attachment %s: %v", a.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate thumbnail"})
+		return
+	}
+	var thumb models.Thumbnail
+	err := db.DB.Where("source_hash = ? AND size = ? AND format = ?", a.Hash, size, format).First(&thumb).Error
+	if err == gorm.ErrRecordNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thumbnail not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load thumbnail"})
+		return
+	}
+
+	name := strings.TrimSuffix(a.Name, path.Ext(a.Name)) + "_" + size + "." + format
+	c.Header("Vary", "Accept")
+	serveBlob(c, blobKey(thumb.Hash), "image/"+format, name, inline)
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"gonote/db"
+	"gonote/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// transferRequest 移动/复制的目标位置，familyId 为空表示个人空间
+type transferRequest struct {
+	FamilyID        string `json:"familyId"`
+	FolderID        string `json:"folderId"`
+	IncludeComments bool   `json:"includeComments"` // 仅复制时有效
+}
+
+// MoveNote - POST /api/notes/:id/move
+// 在个人空间、家庭和文件夹之间移动笔记，仅笔记作者可操作
+func MoveNote(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	var note models.Note
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Param("id"), userId).First(&note).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+
+	var req transferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkTargetSpace(c, userId, req.FamilyID, req.FolderID) {
+		return
+	}
+
+	from := gin.H{"familyId": note.FamilyID, "folderId": note.FolderID}
+	audience := noteAudience(db.DB, &note)
+	var target *string
+	if req.FamilyID != "" {
+		target = &req.FamilyID
+	}
+
+	// 移到其他空间时，附件计入目标空间的配额
+	current := ""
+	if note.FamilyID != nil {
+		current = *note.FamilyID
+	}
+	if req.FamilyID != current {
+		moved := note
+		moved.FamilyID = target
+		if err := checkQuota(userId, &moved, movedAttachmentSize(&note, userId, req.FamilyID)); err != nil {
+			writeUploadError(c, err)
+			return
+		}
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{
+			"family_id": target,
+			"folder_id": req.FolderID,
+		}
+		// 移到其他空间、且目标空间当天已有日记时，移入的笔记不再作为日记
+		if note.JournalDate != nil && req.FamilyID != current {
+			var count int64
+			journalScope(userId, req.FamilyID).Model(&models.Note{}).
+				Where("journal_date = ? AND id <> ?", *note.JournalDate, note.ID).Count(&count)
+			if count > 0 {
+				updates["journal_date"] = nil
+			}
+		}
+		if err := tx.Model(&note).Updates(updates).Error; err != nil {
+			return err
+		}
+		recordAudit(tx, userId, "note.move", "note", note.ID, gin.H{
+			"from": from,
+			"to":   gin.H{"familyId": target, "folderId": req.FolderID},
+		})
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move note"})
+		return
+	}
+
+	db.DB.Preload("Attachments").Preload("Comments").Preload("Collaborators").First(&note, "id = ?", note.ID)
+	// 移出家庭后原家庭成员也会收到，据此从列表中移除
+	publishNote(eventNoteUpdated, &note, userId, audience...)
+	c.JSON(http.StatusOK, note)
+}
+
+// CopyNote - POST /api/notes/:id/copy
+// 复制笔记到目标位置，副本归当前用户所有；附件与原笔记共用文件
+func CopyNote(c *gin.Context) {
+	userId := c.GetString("userId")
+
+	src, err := loadAccessibleNote(c.Param("id"), userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+	db.DB.Preload("Attachments").Preload("Comments").Preload("Collaborators").First(src, "id = ?", src.ID)
+
+	var req transferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkTargetSpace(c, userId, req.FamilyID, req.FolderID) {
+		return
+	}
+
+	copied := models.Note{
+		ID:               "n-" + uuid.New().String(),
+		UserID:           userId,
+		FolderID:         req.FolderID,
+		Title:            src.Title,
+		Content:          src.Content,
+		IsPublic:         src.IsPublic,
+		PublicPermission: src.PublicPermission,
+	}
+	if req.FamilyID != "" {
+		copied.FamilyID = &req.FamilyID
+	}
+
+	// 副本的附件计入目标空间的配额
+	var total int64
+	for _, a := range src.Attachments {
+		total += a.Size
+	}
+	if err := checkQuota(userId, &copied, total); err != nil {
+		writeUploadError(c, err)
+		return
+	}
+
+	// 附件内容按 SHA-256 存储，副本直接引用同一份文件
+	attachments := make([]models.Attachment, 0, len(src.Attachments))
+	for _, a := range src.Attachments {
+		dup := a
+		dup.ID = "a-" + uuid.New().String()
+		dup.NoteID = copied.ID
+		dup.UserID = userId
+		dup.CreatedAt = time.Time{}
+		// 副本正文中的附件地址指向新的附件
+		dup.URL = attachmentURL(dup.ID)
+		if a.URL != "" {
+			copied.Content = strings.ReplaceAll(copied.Content, a.URL, dup.URL)
+		}
+		attachments = append(attachments, dup)
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&copied).Error; err != nil {
+			return err
+		}
+		if len(attachments) > 0 {
+			if err := tx.Create(&attachments).Error; err != nil {
+				return err
+			}
+		}
+		for _, collab := range src.Collaborators {
+			if collab.UserID == userId {
+				continue
+			}
+			collab.NoteID = copied.ID
+			if err := tx.Create(&collab).Error; err != nil {
+				return err
+			}
+		}
+		if req.IncludeComments {
+			// 先分配新 ID，回复的 parentId 指向复制后的主题评论
+			ids := make(map[string]string, len(src.Comments))
+			for _, cm := range src.Comments {
+				ids[cm.ID] = "c-" + uuid.New().String()
+			}
+			for _, cm := range src.Comments {
+				cm.ID = ids[cm.ID]
+				cm.NoteID = copied.ID
+				if cm.ParentID != nil {
+					parentId := ids[*cm.ParentID]
+					cm.ParentID = &parentId
+				}
+				if err := tx.Create(&cm).Error; err != nil {
+					return err
+				}
+			}
+			// 正文中的附件地址已替换
+			if err := reanchorComments(tx, copied.ID, src.Content, copied.Content); err != nil {
+				return err
+			}
+		}
+		recordAudit(tx, userId, "note.copy", "note", copied.ID, gin.H{
+			"sourceId":        src.ID,
+			"to":              gin.H{"familyId": copied.FamilyID, "folderId": req.FolderID},
+			"includeComments": req.IncludeComments,
+		})
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to copy note"})
+		return
+	}
+
+	db.DB.Preload("Attachments").Preload("Comments").Preload("Collaborators").First(&copied, "id = ?", copied.ID)
+	publishNote(eventNoteCreated, &copied, userId)
+	c.JSON(http.StatusCreated, copied)
+}
+
+// movedAttachmentSize 笔记移到目标空间后计入配额的附件大小，统计口径同 storageUsage：
+// 家庭空间计入全部附件，个人空间只计入自己上传的
+func movedAttachmentSize(note *models.Note, userId, familyId string) int64 {
+	var size int64
+	q := db.DB.Model(&models.Attachment{}).Where("note_id = ?", note.ID).Select("COALESCE(SUM(size), 0)")
+	if familyId == "" {
+		q = q.Where("user_id = ?", userId)
+	}
+	q.Scan(&size)
+	return size
+}
+
+// checkTargetSpace 检查笔记的目标位置：目标为家庭时必须是该家庭成员；
+// 文件夹必须属于目标空间。个人空间的内置文件夹没有 Folder 记录，只能用于个人空间
+func checkTargetSpace(c *gin.Context, userId, familyId, folderId string) bool {
+	if familyId != "" {
+		if _, err := findFamilyMember(familyId, userId); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "您不是目标家庭的成员"})
+			return false
+		}
+	}
+	if folderId == "" {
+		return true
+	}
+
+	var folder models.Folder
+	if err := db.DB.First(&folder, "id = ?", folderId).Error; err != nil {
+		if familyId == "" {
+			return true
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "文件夹不属于目标空间"})
+		return false
+	}
+	inFamily := folder.FamilyID != nil && *folder.FamilyID != ""
+	ok := inFamily && *folder.FamilyID == familyId
+	if familyId == "" {
+		ok = !inFamily && folder.UserID == userId
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "文件夹不属于目标空间"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"gonote/db"
+	"gonote/models"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// 可续传上传，实现 tus 1.0.0 核心协议及 creation、expiration、termination 扩展
+// https://tus.io/protocols/resumable-upload
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,expiration,termination"
+	// uploadSessionTTL 上传会话在最后一次写入后保留的时间
+	uploadSessionTTL = 24 * time.Hour
+)
+
+// partialUploadDir 未完成的上传数据保存在本地，存储后端不支持追加写入
+var partialUploadDir = func() string {
+	if v := os.Getenv("GONOTE_PARTIAL_UPLOAD_DIR"); v != "" {
+		return v
+	}
+	return "./uploads-partial"
+}()
+
+func partialPath(id string) string {
+	return filepath.Join(partialUploadDir, id)
+}
+
+// uploadLocks 同一会话的 PATCH 串行执行
+var uploadLocks sync.Map
+
+func lockUpload(id string) func() {
+	mu, _ := uploadLocks.LoadOrStore(id, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// TusOptions 响应 OPTIONS 请求，告知客户端支持的协议版本和扩展
+func TusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	if uploadLimits.MaxSize > 0 {
+		c.Header("Tus-Max-Size", strconv.FormatInt(uploadLimits.MaxSize, 10))
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// checkTusVersion 除 OPTIONS 外的请求都必须带 Tus-Resumable 头
+func checkTusVersion(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Unsupported Tus-Resumable version"})
+		return false
+	}
+	return true
+}
+
+// parseUploadMetadata 解析 Upload-Metadata 头："key base64value,key2 base64value2"
+func parseUploadMetadata(header string) map[string]string {
+	meta := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			continue
+		}
+		meta[key] = string(decoded)
+	}
+	return meta
+}
+
+func setUploadHeaders(c *gin.Context, s models.UploadSession) {
+	c.Header("Upload-Offset", strconv.FormatInt(s.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(s.Length, 10))
+	c.Header("Upload-Expires", s.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Header("Cache-Control", "no-store")
+	if s.AttachmentID != "" {
+		c.Header("Upload-Attachment-Id", s.AttachmentID)
+	}
+}
+
+// findUploadSession 查找当前用户未过期的上传会话
+func findUploadSession(c *gin.Context) (models.UploadSession, bool) {
+	var s models.UploadSession
+	err := db.DB.Where("id = ? AND user_id = ? AND expires_at > ?", c.Param("id"), c.GetString("userId"), time.Now()).
+		First(&s).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return s, false
+	}
+	return s, true
+}
+
+// CreateUpload - POST /api/uploads
+// 请求头 Upload-Length 为文件大小，Upload-Metadata 需包含 noteId，可包含 filename
+func CreateUpload(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+	userId := c.GetString("userId")
+
+	if c.GetHeader("Upload-Defer-Length") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Defer-Length is not supported"})
+		return
+	}
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload-Length"})
+		return
+	}
+	if uploadLimits.MaxSize > 0 && length > uploadLimits.MaxSize {
+		writeUploadError(c, fileTooLarge(uploadLimits.MaxSize))
+		return
+	}
+
+	meta := parseUploadMetadata(c.GetHeader("Upload-Metadata"))
+	note, err := loadAccessibleNote(meta["noteId"], userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+	if !canEditNote(note, userId) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No permission to edit this note"})
+		return
+	}
+	if err := checkQuota(userId, note, length); err != nil {
+		writeUploadError(c, err)
+		return
+	}
+
+	session := models.UploadSession{
+		ID:        "up-" + uuid.New().String(),
+		UserID:    userId,
+		NoteID:    note.ID,
+		Filename:  sanitizeFilename(meta["filename"]),
+		Length:    length,
+		ExpiresAt: time.Now().Add(uploadSessionTTL),
+	}
+	if err := os.MkdirAll(partialUploadDir, 0755); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	}
+	f, err := os.OpenFile(partialPath(session.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	}
+	f.Close()
+	if err := db.DB.Create(&session).Error; err != nil {
+		os.Remove(partialPath(session.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	}
+
+	// 空文件直接完成
+	if length == 0 {
+		if !finishUpload(c, &session) {
+			return
+		}
+	}
+
+	c.Header("Location", "/api/uploads/"+session.ID)
+	setUploadHeaders(c, session)
+	c.Status(http.StatusCreated)
+}
+
+// GetUploadOffset - HEAD /api/uploads/:id
+// 客户端据 Upload-Offset 决定从哪里继续上传
+func GetUploadOffset(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+	session, ok := findUploadSession(c)
+	if !ok {
+		return
+	}
+	setUploadHeaders(c, session)
+	c.Status(http.StatusOK)
+}
+
+// GetUpload - GET /api/uploads/:id
+// 以 JSON 返回会话状态，上传完成后包含创建的附件
+func GetUpload(c *gin.Context) {
+	session, ok := findUploadSession(c)
+	if !ok {
+		return
+	}
+	if session.AttachmentID != "" {
+		var attachment models.Attachment
+		if err := db.DB.Where("id = ?", session.AttachmentID).First(&attachment).Error; err == nil {
+			session.Attachment = &attachment
+		}
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// PatchUpload - PATCH /api/uploads/:id
+// Content-Type 为 application/offset+octet-stream，Upload-Offset 必须等于已接收的字节数
+func PatchUpload(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+	if c.ContentType() != "application/offset+octet-stream" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be application/offset+octet-stream"})
+		return
+	}
+	unlock := lockUpload(c.Param("id"))
+	defer unlock()
+
+	session, ok := findUploadSession(c)
+	if !ok {
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset != session.Offset {
+		setUploadHeaders(c, session)
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload-Offset does not match"})
+		return
+	}
+	if session.AttachmentID != "" {
+		setUploadHeaders(c, session)
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	f, err := os.OpenFile(partialPath(session.ID), os.O_WRONLY, 0644)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return
+	}
+	// 上次写入中断时文件可能比记录的偏移长，先截断
+	if err := f.Truncate(session.Offset); err == nil {
+		_, err = f.Seek(session.Offset, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write upload"})
+		return
+	}
+	// 连接中断时已收到的数据仍然保留
+	n, copyErr := io.Copy(f, io.LimitReader(c.Request.Body, session.Length-session.Offset))
+	if err := f.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+
+	session.Offset += n
+	session.ExpiresAt = time.Now().Add(uploadSessionTTL)
+	db.DB.Model(&session).Updates(map[string]interface{}{"offset": session.Offset, "expires_at": session.ExpiresAt})
+	if copyErr != nil {
+		log.Printf("WARN: upload %s interrupted at %d/%d: %v", session.ID, session.Offset, session.Length, copyErr)
+		setUploadHeaders(c, session)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload interrupted"})
+		return
+	}
+
+	if session.Offset == session.Length {
+		if !finishUpload(c, &session) {
+			return
+		}
+	}
+	setUploadHeaders(c, session)
+	c.Status(http.StatusNoContent)
+}
+
+// finishUpload 数据接收完整后创建附件；校验失败时删除会话并返回错误
+func finishUpload(c *gin.Context, session *models.UploadSession) bool {
+	fail := func(err error) bool {
+		// 校验不通过（权限、类型、大小、配额）重试也没有用，删除会话；
+		// 存储或数据库错误保留会话和已接收的数据，客户端可以重新发送最后一次 PATCH
+		var rejected *uploadError
+		if errors.As(err, &rejected) || errors.Is(err, fs.ErrNotExist) {
+			db.DB.Delete(session)
+			os.Remove(partialPath(session.ID))
+		} else {
+			log.Printf("ERROR: finish upload %s: %v", session.ID, err)
+		}
+		writeUploadError(c, err)
+		return false
+	}
+
+	// 上传期间权限可能已变化
+	note, err := loadAccessibleNote(session.NoteID, session.UserID)
+	if err != nil || !canEditNote(note, session.UserID) {
+		return fail(&uploadError{http.StatusForbidden, "No permission to edit this note"})
+	}
+	// 创建会话时只按当时的用量检查，期间其他上传完成后可能已超出配额
+	if err := checkQuota(session.UserID, note, session.Length); err != nil {
+		return fail(err)
+	}
+
+	f, err := os.Open(partialPath(session.ID))
+	if err != nil {
+		return fail(err)
+	}
+	attachment, err := createAttachment(c.Request.Context(), session.UserID, session.NoteID, session.Filename, f)
+	f.Close()
+	if err != nil {
+		return fail(err)
+	}
+
+	os.Remove(partialPath(session.ID))
+	session.AttachmentID = attachment.ID
+	db.DB.Model(session).Update("attachment_id", attachment.ID)
+	return true
+}
+
+// DeleteUpload - DELETE /api/uploads/:id
+// 放弃上传，删除已接收的数据
+func DeleteUpload(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+	unlock := lockUpload(c.Param("id"))
+	defer unlock()
+
+	session, ok := findUploadSession(c)
+	if !ok {
+		return
+	}
+	db.DB.Delete(&session)
+	os.Remove(partialPath(session.ID))
+	c.Status(http.StatusNoContent)
+}
+
+// StartUploadExpiry 定期清理过期的上传会话和临时目录中没有会话的文件
+func StartUploadExpiry(interval time.Duration) {
+	go func() {
+		for {
+			expireUploads()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func expireUploads() {
+	var expired []models.UploadSession
+	db.DB.Where("expires_at <= ?", time.Now()).Find(&expired)
+	for _, s := range expired {
+		os.Remove(partialPath(s.ID))
+		db.DB.Delete(&s)
+	}
+
+	entries, _ := os.ReadDir(partialUploadDir)
+	stray := 0
+	for _, e := range entries {
+		var count int64
+		db.DB.Model(&models.UploadSession{}).Where("id = ?", e.Name()).Count(&count)
+		if count == 0 {
+			os.Remove(filepath.Join(partialUploadDir, e.Name()))
+			stray++
+		}
+	}
+	if len(expired)+stray > 0 {
+		log.Printf("Upload expiry: %d expired sessions and %d stray partial files removed", len(expired), stray)
+	}
+}
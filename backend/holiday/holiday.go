@@ -0,0 +1,202 @@
+// Package holiday 中国法定节假日和调休上班日。
+// 每年的安排来自国务院办公厅的通知，保存在 data/<年份>.json 并嵌入程序；
+// 新一年的安排公布后，可以不重新编译，把同样格式的文件放到 GONOTE_HOLIDAY_DIR 目录，
+// 同一年份以目录中的文件为准
+package holiday
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DataDirEnv 额外的节假日数据目录
+const DataDirEnv = "GONOTE_HOLIDAY_DIR"
+
+const dateLayout = "2006-01-02"
+
+//go:embed data/*.json
+var embedded embed.FS
+
+// Holiday 一个假期：Start 到 End 放假（包含两端），Workdays 为调休上班的日期
+type Holiday struct {
+	Name     string   `json:"name"`
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+	Workdays []string `json:"workdays,omitempty"`
+}
+
+// YearFile 一年的安排，对应一个数据文件
+type YearFile struct {
+	Year     int       `json:"year"`
+	Source   string    `json:"source"` // 通知名称
+	Holidays []Holiday `json:"holidays"`
+}
+
+// Day 放假或调休上班的一天
+type Day struct {
+	Date    time.Time // 当天（UTC 零点）
+	Name    string    // 假期名称
+	Workday bool      // 调休上班
+	Start   time.Time // 所属假期的第一天
+	End     time.Time // 所属假期的最后一天
+}
+
+// Set 已加载的节假日，按日期索引
+type Set struct {
+	days  map[time.Time]Day
+	years []int
+}
+
+// Parse 解析并检查一个数据文件
+func Parse(data []byte) (*YearFile, error) {
+	var file YearFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Year == 0 {
+		return nil, fmt.Errorf("missing year")
+	}
+	for _, h := range file.Holidays {
+		start, err := time.Parse(dateLayout, h.Start)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid start %q", h.Name, h.Start)
+		}
+		end, err := time.Parse(dateLayout, h.End)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid end %q", h.Name, h.End)
+		}
+		if h.Name == "" || end.Before(start) {
+			return nil, fmt.Errorf("invalid holiday %q %s - %s", h.Name, h.Start, h.End)
+		}
+		if end.Year() != file.Year {
+			// 元旦假期可能从上一年的 12 月 31 日开始
+			return nil, fmt.Errorf("%s: %s is not in %d", h.Name, h.End, file.Year)
+		}
+		for _, w := range h.Workdays {
+			day, err := time.Parse(dateLayout, w)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid workday %q", h.Name, w)
+			}
+			if !day.Before(start) && !day.After(end) {
+				return nil, fmt.Errorf("%s: workday %s is inside the holiday", h.Name, w)
+			}
+		}
+	}
+	return &file, nil
+}
+
+// NewSet 由多年的安排建立索引，同一年份后面的覆盖前面的
+func NewSet(files []*YearFile) *Set {
+	byYear := map[int]*YearFile{}
+	for _, f := range files {
+		byYear[f.Year] = f
+	}
+
+	s := &Set{days: map[time.Time]Day{}}
+	for year, f := range byYear {
+		s.years = append(s.years, year)
+		for _, h := range f.Holidays {
+			start, _ := time.Parse(dateLayout, h.Start)
+			end, _ := time.Parse(dateLayout, h.End)
+			for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+				s.days[d] = Day{Date: d, Name: h.Name, Start: start, End: end}
+			}
+			for _, w := range h.Workdays {
+				d, _ := time.Parse(dateLayout, w)
+				s.days[d] = Day{Date: d, Name: h.Name, Workday: true, Start: start, End: end}
+			}
+		}
+	}
+	slices.Sort(s.years)
+	return s
+}
+
+// Years 有数据的年份
+func (s *Set) Years() []int {
+	return s.years
+}
+
+// Between 返回 [from, to) 内放假和调休上班的日子，按日期排序。
+// from、to 按各自时区的日期比较
+func (s *Set) Between(from, to time.Time) []Day {
+	start := civilDay(from)
+	end := civilDay(to)
+	var days []Day
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		if day, ok := s.days[d]; ok {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+func civilDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+var (
+	defaultSet  *Set
+	defaultOnce sync.Once
+)
+
+// Default 内置数据加上 GONOTE_HOLIDAY_DIR 中的文件，第一次调用时加载。
+// 无法解析的文件记录日志后跳过
+func Default() *Set {
+	defaultOnce.Do(func() {
+		defaultSet = NewSet(loadFiles())
+		log.Printf("holiday: loaded %d years of statutory holidays", len(defaultSet.years))
+	})
+	return defaultSet
+}
+
+func loadFiles() []*YearFile {
+	var files []*YearFile
+	entries, _ := fs.ReadDir(embedded, "data")
+	for _, e := range entries {
+		data, err := embedded.ReadFile("data/" + e.Name())
+		if err != nil {
+			continue
+		}
+		f, err := Parse(data)
+		if err != nil {
+			log.Printf("holiday: embedded %s: %v", e.Name(), err)
+			continue
+		}
+		files = append(files, f)
+	}
+
+	dir := os.Getenv(DataDirEnv)
+	if dir == "" {
+		return files
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("holiday: read %s: %v", DataDirEnv, err)
+		return files
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err == nil {
+			var f *YearFile
+			if f, err = Parse(data); err == nil {
+				files = append(files, f)
+				continue
+			}
+		}
+		log.Printf("holiday: %s: %v", e.Name(), err)
+	}
+	return files
+}
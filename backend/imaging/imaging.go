@@ -0,0 +1,250 @@
+// Package imaging 处理上传的图片：去除 Exif / GPS 等元数据，生成缩略图
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"slices"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// MaxPixels 解码前检查像素数，防止解压炸弹
+const MaxPixels = 50_000_000
+
+var ErrTooLarge = errors.New("imaging: image dimensions too large")
+
+// Supported 是否可以处理该类型
+func Supported(mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// heifTypes 使用 HEIF 容器的类型，只能去除元数据，无法解码生成缩略图
+var heifTypes = []string{"image/heic", "image/heif", "image/heic-sequence", "image/heif-sequence", "image/avif"}
+
+// CanSanitize 是否可以去除该类型的元数据：Supported 的类型和 HEIF 容器
+func CanSanitize(mimeType string) bool {
+	return Supported(mimeType) || slices.Contains(heifTypes, mimeType)
+}
+
+// Result 处理后的图片
+type Result struct {
+	Data   []byte
+	Width  int
+	Height int
+}
+
+// Sanitize 去除图片中的 Exif、XMP、IPTC 等元数据（包括 GPS 位置），支持 CanSanitize 的类型。
+// 一般不重新编码；JPEG 带有旋转方向时按方向转正后重新编码，否则删除 Exif 后方向会丢失
+func Sanitize(data []byte, mimeType string) (Result, error) {
+	var (
+		out         []byte
+		orientation = 1
+		err         error
+	)
+	if slices.Contains(heifTypes, mimeType) {
+		// 无法解码，不返回宽高
+		out, err := stripHEIF(data)
+		if err != nil {
+			return Result{}, err
+		}
+		return Result{Data: out}, nil
+	}
+	switch mimeType {
+	case "image/jpeg":
+		out, orientation, err = stripJPEG(data)
+	case "image/png":
+		out, err = stripPNG(data)
+	case "image/webp":
+		out, err = stripWebP(data)
+	default:
+		out = data
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	cfg, err := decodeConfig(out, mimeType)
+	if err != nil {
+		return Result{}, err
+	}
+	if orientation == 1 {
+		return Result{Data: out, Width: cfg.Width, Height: cfg.Height}, nil
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(out))
+	if err != nil {
+		return Result{}, err
+	}
+	img = orient(img, orientation)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 92}); err != nil {
+		return Result{}, err
+	}
+	b := img.Bounds()
+	return Result{Data: buf.Bytes(), Width: b.Dx(), Height: b.Dy()}, nil
+}
+
+func decodeConfig(data []byte, mimeType string) (image.Config, error) {
+	var (
+		cfg image.Config
+		err error
+	)
+	r := bytes.NewReader(data)
+	switch mimeType {
+	case "image/jpeg":
+		cfg, err = jpeg.DecodeConfig(r)
+	case "image/png":
+		cfg, err = png.DecodeConfig(r)
+	case "image/gif":
+		cfg, err = gif.DecodeConfig(r)
+	case "image/webp":
+		cfg, err = webp.DecodeConfig(r)
+	default:
+		return cfg, errBadImage
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return cfg, errBadImage
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return cfg, ErrTooLarge
+	}
+	return cfg, nil
+}
+
+func decode(data []byte, mimeType string) (image.Image, error) {
+	if _, err := decodeConfig(data, mimeType); err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(data)
+	switch mimeType {
+	case "image/jpeg":
+		img, err := jpeg.Decode(r)
+		if err != nil {
+			return nil, err
+		}
+		// 旧数据可能仍带有 Exif 方向
+		if _, o, err := stripJPEG(data); err == nil && o != 1 {
+			img = orient(img, o)
+		}
+		return img, nil
+	case "image/png":
+		return png.Decode(r)
+	case "image/gif":
+		return gif.Decode(r) // 动图只取第一帧
+	case "image/webp":
+		return webp.Decode(r)
+	}
+	return nil, errBadImage
+}
+
+// ThumbnailFormat 缩略图的备选格式：可能透明的图片用 PNG，其他用 JPEG
+func ThumbnailFormat(mimeType string) string {
+	if mimeType == "image/png" || mimeType == "image/gif" || mimeType == "image/webp" {
+		return "png"
+	}
+	return "jpeg"
+}
+
+// Thumbnails 按 maxSizes 中的每个尺寸（长边像素）生成缩略图，小图不放大。
+// formats 可包含 webp、jpeg、png；results[i][j] 对应 maxSizes[i]、formats[j]
+func Thumbnails(data []byte, mimeType string, maxSizes []int, formats []string) ([][]Result, error) {
+	img, err := decode(data, mimeType)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([][]Result, len(maxSizes))
+	for i, size := range maxSizes {
+		scaled := resize(img, size)
+		for _, format := range formats {
+			var buf bytes.Buffer
+			if err := encode(&buf, scaled, format); err != nil {
+				return nil, err
+			}
+			b := scaled.Bounds()
+			results[i] = append(results[i], Result{Data: buf.Bytes(), Width: b.Dx(), Height: b.Dy()})
+		}
+	}
+	return results, nil
+}
+
+func resize(img image.Image, maxSize int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSize && h <= maxSize {
+		return img
+	}
+	if w >= h {
+		h = max(1, h*maxSize/w)
+		w = maxSize
+	} else {
+		w = max(1, w*maxSize/h)
+		h = maxSize
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+func encode(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case "webp":
+		return nativewebp.Encode(w, img, nil)
+	case "jpeg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 82})
+	case "png":
+		return png.Encode(w, img)
+	}
+	return errors.New("imaging: unknown format " + format)
+}
+
+// orient 按 Exif Orientation (2-8) 旋转 / 翻转图片
+func orient(img image.Image, orientation int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			default:
+				sx, sy = x, y
+			}
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
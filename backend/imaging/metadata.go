@@ -0,0 +1,411 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var errBadImage = errors.New("imaging: malformed image")
+
+// stripJPEG 删除 APP1（Exif / XMP）和 APP13（IPTC）段，返回去掉元数据的 JPEG 和 Exif 方向
+func stripJPEG(data []byte) ([]byte, int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, 0, errBadImage
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	orientation := 1
+
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return nil, 0, errBadImage
+		}
+		marker := data[i+1]
+		// 填充字节
+		if marker == 0xFF {
+			i++
+			continue
+		}
+		// 没有长度字段的标记
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			out.Write(data[i : i+2])
+			i += 2
+			continue
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, 0, errBadImage
+		}
+		segment := data[i:end]
+		payload := data[i+4 : end]
+
+		switch {
+		case marker == 0xDA:
+			// SOS 之后是压缩数据，原样保留
+			out.Write(data[i:])
+			return out.Bytes(), orientation, nil
+		case marker == 0xE1:
+			// APP1 中的 Exif 和 XMP 都丢弃，只保留方向信息
+			if bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+				if o := exifOrientation(payload[6:]); o != 0 {
+					orientation = o
+				}
+			}
+		case marker == 0xED:
+			// APP13 (IPTC) 丢弃
+		default:
+			out.Write(segment)
+		}
+		i = end
+	}
+	return nil, 0, errBadImage
+}
+
+// exifOrientation 读取 TIFF 结构 IFD0 中的 Orientation (0x0112)，没有时返回 0
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	offset := int(order.Uint32(tiff[4:]))
+	if offset+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for n := 0; n < count; n++ {
+		entry := offset + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			o := int(order.Uint16(tiff[entry+8:]))
+			if o >= 1 && o <= 8 {
+				return o
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// stripPNG 删除 eXIf 和文本块（tEXt / zTXt / iTXt，XMP 保存在 iTXt 中）
+func stripPNG(data []byte) ([]byte, error) {
+	const sig = "\x89PNG\r\n\x1a\n"
+	if len(data) < len(sig) || string(data[:len(sig)]) != sig {
+		return nil, errBadImage
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.WriteString(sig)
+
+	i := len(sig)
+	for i+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + length
+		if end > len(data) {
+			return nil, errBadImage
+		}
+		switch string(data[i+4 : i+8]) {
+		case "eXIf", "tEXt", "zTXt", "iTXt":
+		default:
+			out.Write(data[i:end])
+		}
+		if string(data[i+4:i+8]) == "IEND" {
+			return out.Bytes(), nil
+		}
+		i = end
+	}
+	return nil, errBadImage
+}
+
+// stripWebP 删除 EXIF 和 XMP 块，并清除 VP8X 头中对应的标志位
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errBadImage
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+
+	i := 12
+	for i+8 <= len(data) {
+		fourcc := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2
+		if end > len(data) {
+			if i+8+size == len(data) {
+				end = len(data) // 末尾缺少填充字节
+			} else {
+				return nil, errBadImage
+			}
+		}
+		switch fourcc {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), data[i:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04
+			}
+			out.Write(chunk)
+		default:
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	result := out.Bytes()
+	binary.LittleEndian.PutUint32(result[4:], uint32(len(result)-8))
+	return result, nil
+}
+
+// stripHEIF 把 HEIF / HEIC / AVIF 中 Exif 和 XMP 元数据项的内容清零。
+// 元数据是 meta 盒中的独立项，数据位置记录在 iloc 中；原地清零不改变其他数据的偏移，
+// 不需要重写 iloc。结构无法解析时返回错误，不保存未清理的图片
+func stripHEIF(data []byte) ([]byte, error) {
+	ms, me, ok := findBox(data, "meta")
+	if !ok || me-ms < 4 {
+		return nil, errBadImage
+	}
+	// meta 是 FullBox，子盒从版本和标志之后开始
+	childStart := ms + 4
+	children := data[childStart:me]
+	is, ie, ok := findBox(children, "iinf")
+	if !ok {
+		return nil, errBadImage
+	}
+	items, err := heifMetadataItems(children[is:ie])
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return data, nil
+	}
+	ls, le, ok := findBox(children, "iloc")
+	if !ok {
+		return nil, errBadImage
+	}
+	extents, err := heifItemExtents(children[ls:le], items)
+	if err != nil {
+		return nil, err
+	}
+
+	out := append([]byte(nil), data...)
+	for _, ext := range extents {
+		var start uint64
+		switch ext.method {
+		case 0: // 文件偏移
+			start = ext.offset
+		case 1: // idat 中的偏移
+			ds, _, ok := findBox(children, "idat")
+			if !ok {
+				return nil, errBadImage
+			}
+			start = uint64(childStart+ds) + ext.offset
+		default:
+			return nil, errBadImage
+		}
+		if ext.length == 0 || start > uint64(len(out)) || ext.length > uint64(len(out))-start {
+			return nil, errBadImage
+		}
+		clear(out[start : start+ext.length])
+	}
+	return out, nil
+}
+
+// findBox 按顺序查找 buf 中第一个类型为 typ 的 ISOBMFF 盒，返回内容（不含盒头）的起止位置
+func findBox(buf []byte, typ string) (int, int, bool) {
+	for i := 0; i+8 <= len(buf); {
+		size := uint64(binary.BigEndian.Uint32(buf[i:]))
+		header := 8
+		switch size {
+		case 0: // 到末尾
+			size = uint64(len(buf) - i)
+		case 1: // 64 位长度
+			if i+16 > len(buf) {
+				return 0, 0, false
+			}
+			size = binary.BigEndian.Uint64(buf[i+8:])
+			header = 16
+		}
+		if size < uint64(header) || size > uint64(len(buf)-i) {
+			return 0, 0, false
+		}
+		if string(buf[i+4:i+8]) == typ {
+			return i + header, i + int(size), true
+		}
+		i += int(size)
+	}
+	return 0, 0, false
+}
+
+// heifMetadataItems iinf 中 Exif 项和 XMP 项（mime 类型 application/rdf+xml）的 ID
+func heifMetadataItems(iinf []byte) (map[uint32]bool, error) {
+	r := &boxReader{buf: iinf}
+	if r.u8() == 0 {
+		r.skip(3)
+		r.u16() // entry_count
+	} else {
+		r.skip(3)
+		r.u32()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	items := map[uint32]bool{}
+	entries := iinf[r.pos:]
+	for {
+		s, e, ok := findBox(entries, "infe")
+		if !ok {
+			break
+		}
+		infe := &boxReader{buf: entries[s:e]}
+		entries = entries[e:]
+
+		version := infe.u8()
+		infe.skip(3)
+		if version < 2 {
+			continue // 早期版本没有 item_type，不会是 Exif 项
+		}
+		var id uint32
+		if version == 2 {
+			id = uint32(infe.u16())
+		} else {
+			id = infe.u32()
+		}
+		infe.skip(2) // item_protection_index
+		itemType := string(infe.bytes(4))
+		if infe.err != nil {
+			return nil, infe.err
+		}
+		switch itemType {
+		case "Exif":
+			items[id] = true
+		case "mime":
+			// item_name 和 content_type 都以 0 结尾
+			fields := bytes.SplitN(infe.buf[infe.pos:], []byte{0}, 3)
+			if len(fields) >= 2 && bytes.HasPrefix(fields[1], []byte("application/rdf+xml")) {
+				items[id] = true
+			}
+		}
+	}
+	return items, nil
+}
+
+type heifExtent struct {
+	method         int // iloc construction_method
+	offset, length uint64
+}
+
+// heifItemExtents 从 iloc 中读取指定项的全部数据区段
+func heifItemExtents(iloc []byte, items map[uint32]bool) ([]heifExtent, error) {
+	r := &boxReader{buf: iloc}
+	version := r.u8()
+	r.skip(3)
+	sizes := r.u16()
+	offsetSize, lengthSize, baseSize := int(sizes>>12), int(sizes>>8&0xf), int(sizes>>4&0xf)
+	indexSize := 0
+	if version == 1 || version == 2 {
+		indexSize = int(sizes & 0xf)
+	}
+	var count uint32
+	if version < 2 {
+		count = uint32(r.u16())
+	} else {
+		count = r.u32()
+	}
+
+	var extents []heifExtent
+	for n := uint32(0); n < count && r.err == nil; n++ {
+		var id uint32
+		if version < 2 {
+			id = uint32(r.u16())
+		} else {
+			id = r.u32()
+		}
+		method := 0
+		if version == 1 || version == 2 {
+			method = int(r.u16() & 0xf)
+		}
+		r.skip(2) // data_reference_index
+		base := r.uint(baseSize)
+		extentCount := int(r.u16())
+		for k := 0; k < extentCount && r.err == nil; k++ {
+			r.uint(indexSize)
+			offset := r.uint(offsetSize)
+			length := r.uint(lengthSize)
+			if items[id] {
+				extents = append(extents, heifExtent{method: method, offset: base + offset, length: length})
+			}
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return extents, nil
+}
+
+// boxReader 顺序读取大端整数，越界后 err 为 errBadImage，之后的读取都返回 0
+type boxReader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *boxReader) bytes(n int) []byte {
+	if r.err != nil || n > len(r.buf)-r.pos {
+		r.err = errBadImage
+		return nil
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *boxReader) skip(n int) { r.bytes(n) }
+
+func (r *boxReader) u8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *boxReader) u16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *boxReader) u32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+// uint 读取 n 字节（0、4 或 8）的整数
+func (r *boxReader) uint(n int) uint64 {
+	switch n {
+	case 0:
+		return 0
+	case 4:
+		return uint64(r.u32())
+	case 8:
+		if b := r.bytes(8); b != nil {
+			return binary.BigEndian.Uint64(b)
+		}
+		return 0
+	}
+	r.err = errBadImage
+	return 0
+}
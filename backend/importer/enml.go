@@ -0,0 +1,244 @@
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// mediaLookup 根据 en-media 的 hash 查找附件，返回文件名和占位地址
+type mediaLookup func(hash, mimeType string) (name, url string, ok bool)
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// enmlToMarkdown 将 Evernote 的 ENML（XHTML 子集）转为 Markdown
+func enmlToMarkdown(enml string, media mediaLookup) string {
+	doc, err := html.Parse(strings.NewReader(enml))
+	if err != nil {
+		return enml
+	}
+	c := &enmlConverter{media: media}
+	c.walk(doc)
+	out := blankLines.ReplaceAllString(c.b.String(), "\n\n")
+	return strings.TrimSpace(out) + "\n"
+}
+
+type enmlConverter struct {
+	b      strings.Builder
+	media  mediaLookup
+	lists  []listState
+	inPre  bool
+	quoted int
+}
+
+type listState struct {
+	ordered bool
+	n       int
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// newline 开始新的一行，引用块内加上 "> " 前缀
+func (c *enmlConverter) newline() {
+	c.b.WriteString("\n" + strings.Repeat("> ", c.quoted))
+}
+
+func (c *enmlConverter) block(n *html.Node) {
+	c.newline()
+	c.children(n)
+	c.newline()
+}
+
+func (c *enmlConverter) children(n *html.Node) {
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		c.walk(ch)
+	}
+}
+
+func (c *enmlConverter) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		text := n.Data
+		if !c.inPre {
+			text = strings.Join(strings.Fields(text), " ")
+			if strings.TrimSpace(n.Data) != n.Data && text != "" {
+				// 保留与相邻行内元素之间的空格
+				if strings.HasPrefix(n.Data, " ") || strings.HasPrefix(n.Data, "\n") {
+					text = " " + text
+				}
+				if strings.HasSuffix(n.Data, " ") || strings.HasSuffix(n.Data, "\n") {
+					text += " "
+				}
+			}
+		}
+		c.b.WriteString(text)
+		return
+	case html.ElementNode:
+	default:
+		c.children(n)
+		return
+	}
+
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		c.newline()
+		c.b.WriteString(strings.Repeat("#", int(n.Data[1]-'0')) + " ")
+		c.children(n)
+		c.newline()
+	case "p", "div":
+		c.block(n)
+	case "br":
+		c.newline()
+	case "hr":
+		c.newline()
+		c.b.WriteString("---")
+		c.newline()
+	case "b", "strong":
+		c.b.WriteString("**")
+		c.children(n)
+		c.b.WriteString("**")
+	case "i", "em":
+		c.b.WriteString("*")
+		c.children(n)
+		c.b.WriteString("*")
+	case "s", "strike", "del":
+		c.b.WriteString("~~")
+		c.children(n)
+		c.b.WriteString("~~")
+	case "code":
+		if c.inPre {
+			c.children(n)
+		} else {
+			c.b.WriteString("`")
+			c.children(n)
+			c.b.WriteString("`")
+		}
+	case "pre":
+		c.newline()
+		c.b.WriteString("```")
+		c.newline()
+		c.inPre = true
+		c.children(n)
+		c.inPre = false
+		c.newline()
+		c.b.WriteString("```")
+		c.newline()
+	case "blockquote":
+		c.quoted++
+		c.block(n)
+		c.quoted--
+		c.newline()
+	case "a":
+		href := attr(n, "href")
+		if href == "" {
+			c.children(n)
+			return
+		}
+		c.b.WriteString("[")
+		c.children(n)
+		c.b.WriteString("](" + href + ")")
+	case "img":
+		c.b.WriteString(fmt.Sprintf("![%s](%s)", attr(n, "alt"), attr(n, "src")))
+	case "ul", "ol":
+		c.lists = append(c.lists, listState{ordered: n.Data == "ol"})
+		c.newline()
+		c.children(n)
+		c.lists = c.lists[:len(c.lists)-1]
+		c.newline()
+	case "li":
+		depth := len(c.lists)
+		marker := "- "
+		if depth > 0 && c.lists[depth-1].ordered {
+			c.lists[depth-1].n++
+			marker = fmt.Sprintf("%d. ", c.lists[depth-1].n)
+		}
+		c.newline()
+		if depth > 1 {
+			c.b.WriteString(strings.Repeat("  ", depth-1))
+		}
+		c.b.WriteString(marker)
+		c.children(n)
+	// en-todo / en-media 在 ENML 中是自闭合标签，HTML 解析器会把后面的内容
+	// 当作它们的子节点，所以输出标记后要继续处理子节点
+	case "en-todo":
+		if attr(n, "checked") == "true" {
+			c.b.WriteString("- [x] ")
+		} else {
+			c.b.WriteString("- [ ] ")
+		}
+		c.children(n)
+	case "en-media":
+		if name, url, ok := c.media(attr(n, "hash"), attr(n, "type")); ok {
+			if strings.HasPrefix(attr(n, "type"), "image/") {
+				c.b.WriteString(fmt.Sprintf("![%s](%s)", name, url))
+			} else {
+				c.b.WriteString(fmt.Sprintf("[%s](%s)", name, url))
+			}
+		}
+		c.children(n)
+	case "table":
+		c.table(n)
+	case "en-crypt":
+		c.b.WriteString("[加密内容未导入]")
+	default:
+		c.children(n)
+	}
+}
+
+// table 简单表格转为 GFM 表格，第一行作为表头
+func (c *enmlConverter) table(n *html.Node) {
+	var rows [][]string
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			if ch.Type == html.ElementNode && ch.Data == "tr" {
+				var row []string
+				for cell := ch.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+						sub := &enmlConverter{media: c.media}
+						sub.children(cell)
+						text := strings.Join(strings.Fields(sub.b.String()), " ")
+						row = append(row, strings.ReplaceAll(text, "|", `\|`))
+					}
+				}
+				rows = append(rows, row)
+				continue
+			}
+			collect(ch)
+		}
+	}
+	collect(n)
+	if len(rows) == 0 {
+		return
+	}
+
+	cols := 0
+	for _, r := range rows {
+		if len(r) > cols {
+			cols = len(r)
+		}
+	}
+	// 表格前需要空行，否则会被并入上一段
+	c.newline()
+	c.newline()
+	for i, r := range rows {
+		for len(r) < cols {
+			r = append(r, "")
+		}
+		c.b.WriteString("| " + strings.Join(r, " | ") + " |")
+		c.newline()
+		if i == 0 {
+			c.b.WriteString("|" + strings.Repeat(" --- |", cols))
+			c.newline()
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package importer
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+type enexNote struct {
+	Title     string         `xml:"title"`
+	Content   string         `xml:"content"`
+	Created   string         `xml:"created"`
+	Updated   string         `xml:"updated"`
+	Tags      []string       `xml:"tag"`
+	Resources []enexResource `xml:"resource"`
+}
+
+type enexResource struct {
+	Data struct {
+		Encoding string `xml:"encoding,attr"`
+		Value    string `xml:",chardata"`
+	} `xml:"data"`
+	Mime     string `xml:"mime"`
+	FileName string `xml:"resource-attributes>file-name"`
+}
+
+// enexTime ENEX 的时间格式，例如 20200101T120000Z
+const enexTime = "20060102T150405Z"
+
+// parseENEXFiles 压缩包中的每个 .enex 文件对应一个笔记本（文件夹）
+func parseENEXFiles(files map[string]vfile) ([]Note, error) {
+	notes := make([]Note, 0)
+	for _, name := range sortedNames(files) {
+		if !strings.EqualFold(path.Ext(name), ".enex") {
+			continue
+		}
+		f := files[name]
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parseENEX(f.budget.reader(r), strings.TrimSuffix(path.Base(name), path.Ext(name)))
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, parsed...)
+	}
+	return notes, nil
+}
+
+// parseENEX 逐条解码 <note>，ENML 转为 Markdown，资源转为附件
+func parseENEX(r io.Reader, notebook string) ([]Note, error) {
+	dec := xml.NewDecoder(r)
+	dec.Strict = false
+	notes := make([]Note, 0)
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "note" {
+			continue
+		}
+
+		var en enexNote
+		if err := dec.DecodeElement(&en, &start); err != nil {
+			return nil, err
+		}
+		notes = append(notes, convertENEXNote(en, notebook))
+	}
+	return notes, nil
+}
+
+func convertENEXNote(en enexNote, notebook string) Note {
+	var atts []Attachment
+	byHash := map[string]int{}
+
+	for _, res := range en.Resources {
+		data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(res.Data.Value), ""))
+		if err != nil {
+			continue
+		}
+		sum := md5.Sum(data)
+		name := res.FileName
+		if name == "" {
+			name = "resource-" + hex.EncodeToString(sum[:4])
+		}
+		byHash[hex.EncodeToString(sum[:])] = len(atts)
+		atts = append(atts, Attachment{
+			Name: name,
+			Type: res.Mime,
+			Open: func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil },
+		})
+	}
+
+	content := enmlToMarkdown(en.Content, func(hash, mimeType string) (string, string, bool) {
+		i, ok := byHash[strings.ToLower(hash)]
+		if !ok {
+			return "", "", false
+		}
+		return atts[i].Name, Placeholder(i), true
+	})
+
+	if len(en.Tags) > 0 {
+		tags := make([]string, len(en.Tags))
+		for i, t := range en.Tags {
+			tags[i] = "#" + strings.ReplaceAll(strings.TrimSpace(t), " ", "_")
+		}
+		content = strings.TrimRight(content, "\n") + "\n\n" + strings.Join(tags, " ") + "\n"
+	}
+
+	note := Note{
+		Title:       en.Title,
+		Content:     content,
+		Folder:      notebook,
+		Attachments: atts,
+	}
+	if t, err := time.Parse(enexTime, en.Created); err == nil {
+		note.Created = t
+	}
+	if t, err := time.Parse(enexTime, en.Updated); err == nil {
+		note.Updated = t
+	}
+	return note
+}
@@ -0,0 +1,331 @@
+// Package importer 解析 Obsidian 仓库、Notion 导出和 Evernote ENEX 文件，
+// 输出与存储无关的笔记列表，由调用方写入数据库
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Source 导入来源
+type Source string
+
+const (
+	SourceAuto     Source = "auto"
+	SourceObsidian Source = "obsidian"
+	SourceNotion   Source = "notion"
+	SourceEvernote Source = "evernote"
+)
+
+// Note 待导入的笔记
+type Note struct {
+	Title       string
+	Content     string // 附件引用已替换为 Placeholder(i)
+	Folder      string // 相对目录，如 "工作/项目"，为空表示根目录
+	Created     time.Time
+	Updated     time.Time
+	Attachments []Attachment
+}
+
+// Attachment 待导入的附件，内容按需读取
+type Attachment struct {
+	Name string
+	Type string
+	Open func() (io.ReadCloser, error)
+}
+
+// Placeholder 正文中第 i 个附件的占位地址，保存附件后由 ReplacePlaceholders 替换为真实 URL。
+// 以 "/" 结尾，第 1 个附件的占位地址不是第 10 个的前缀
+func Placeholder(i int) string {
+	return fmt.Sprintf("gonote-attachment://%d/", i)
+}
+
+var placeholderPattern = regexp.MustCompile(`gonote-attachment://(\d+)/`)
+
+// ReplacePlaceholders 一次性替换正文中的所有占位地址，urls[i] 为第 i 个附件的替换内容
+func ReplacePlaceholders(content string, urls []string) string {
+	return placeholderPattern.ReplaceAllStringFunc(content, func(m string) string {
+		i, err := strconv.Atoi(placeholderPattern.FindStringSubmatch(m)[1])
+		if err != nil || i >= len(urls) {
+			return m
+		}
+		return urls[i]
+	})
+}
+
+// Result 解析结果，附件读取完成后需要 Close
+type Result struct {
+	Source Source
+	Notes  []Note
+	closer io.Closer
+}
+
+// Close 释放底层文件
+func (r *Result) Close() error {
+	if r.closer != nil {
+		return r.closer.Close()
+	}
+	return nil
+}
+
+// ErrTooLarge 解析时读入内存的内容超过限制，通常是解压后体积异常的压缩包
+var ErrTooLarge = errors.New("import content exceeds size limit after decompression")
+
+// Open 解析上传的文件。filename 为用户上传时的文件名，用于判断格式；
+// maxSize 为解析时读入内存的总字节数上限（笔记正文、.enex、嵌套的 zip），0 表示不限
+func Open(filePath, filename string, source Source, maxSize int64) (*Result, error) {
+	var limit *budget
+	if maxSize > 0 {
+		limit = &budget{remaining: maxSize}
+	}
+
+	if strings.EqualFold(path.Ext(filename), ".enex") {
+		f, err := os.Open(filePath)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		notes, err := parseENEX(limit.reader(f), strings.TrimSuffix(path.Base(filename), path.Ext(filename)))
+		if err != nil {
+			return nil, err
+		}
+		return &Result{Source: SourceEvernote, Notes: notes}, nil
+	}
+
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("not a zip or .enex file: %w", err)
+	}
+	files, err := collectFiles(&zr.Reader, limit)
+	if err != nil {
+		zr.Close()
+		return nil, err
+	}
+
+	if source == SourceAuto || source == "" {
+		source = detect(files)
+	}
+
+	var notes []Note
+	switch source {
+	case SourceEvernote:
+		notes, err = parseENEXFiles(files)
+	case SourceNotion:
+		notes, err = parseNotion(files)
+	case SourceObsidian:
+		notes, err = parseObsidian(files)
+	default:
+		err = fmt.Errorf("unknown import source %q", source)
+	}
+	if err != nil {
+		zr.Close()
+		return nil, err
+	}
+	return &Result{Source: source, Notes: notes, closer: zr}, nil
+}
+
+// vfile 压缩包内的文件，嵌套的 zip 会被展开
+type vfile struct {
+	Name   string
+	Size   uint64 // 解压后的大小，archive/zip 读取时保证实际内容不超过该值
+	Open   func() (io.ReadCloser, error)
+	budget *budget
+}
+
+// budget 解析时读入内存的剩余字节数，压缩包中的所有文件共用；nil 表示不限。
+// 附件内容按需流式读取，不计入
+type budget struct {
+	remaining int64
+}
+
+// read 读取 r 的全部内容，size 为声明的大小，超过剩余额度时不读取直接返回 ErrTooLarge
+func (b *budget) read(r io.Reader, size uint64) ([]byte, error) {
+	if b == nil {
+		return io.ReadAll(r)
+	}
+	if size > uint64(b.remaining) {
+		return nil, ErrTooLarge
+	}
+	return io.ReadAll(b.reader(r))
+}
+
+// reader 供流式解析使用，读取的字节数超过剩余额度时返回 ErrTooLarge
+func (b *budget) reader(r io.Reader) io.Reader {
+	if b == nil {
+		return r
+	}
+	return &budgetReader{r: r, b: b}
+}
+
+type budgetReader struct {
+	r io.Reader
+	b *budget
+}
+
+func (br *budgetReader) Read(p []byte) (int, error) {
+	// 多读一个字节，内容恰好用完额度时仍能正常读到 EOF
+	if int64(len(p)) > br.b.remaining+1 {
+		p = p[:br.b.remaining+1]
+	}
+	n, err := br.r.Read(p)
+	br.b.remaining -= int64(n)
+	if br.b.remaining < 0 {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
+
+// collectFiles 列出压缩包中的文件，跳过系统文件；Notion 导出常见的
+// "zip 套 zip" 会展开一层
+func collectFiles(zr *zip.Reader, limit *budget) (map[string]vfile, error) {
+	files := map[string]vfile{}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || skipFile(f.Name) {
+			continue
+		}
+		if strings.EqualFold(path.Ext(f.Name), ".zip") {
+			inner, err := openInnerZip(f, limit)
+			if err != nil {
+				return nil, err
+			}
+			for _, g := range inner.File {
+				if g.FileInfo().IsDir() || skipFile(g.Name) {
+					continue
+				}
+				files[g.Name] = vfile{Name: g.Name, Size: g.UncompressedSize64, Open: g.Open, budget: limit}
+			}
+			continue
+		}
+		files[f.Name] = vfile{Name: f.Name, Size: f.UncompressedSize64, Open: f.Open, budget: limit}
+	}
+	return stripCommonRoot(files), nil
+}
+
+// openInnerZip 嵌套的 zip 整个读入内存，计入 limit
+func openInnerZip(f *zip.File, limit *budget) (*zip.Reader, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := limit.read(r, f.UncompressedSize64)
+	if err != nil {
+		return nil, err
+	}
+	return zip.NewReader(bytes.NewReader(data), int64(len(data)))
+}
+
+func skipFile(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
+
+// stripCommonRoot 所有文件都在同一个顶层目录下时（如仓库目录），去掉该目录
+func stripCommonRoot(files map[string]vfile) map[string]vfile {
+	root := ""
+	for name := range files {
+		i := strings.Index(name, "/")
+		if i < 0 {
+			return files
+		}
+		if root == "" {
+			root = name[:i+1]
+		} else if !strings.HasPrefix(name, root) {
+			return files
+		}
+	}
+	if root == "" {
+		return files
+	}
+	stripped := make(map[string]vfile, len(files))
+	for name, f := range files {
+		f.Name = strings.TrimPrefix(name, root)
+		stripped[f.Name] = f
+	}
+	return stripped
+}
+
+var notionHash = regexp.MustCompile(` [0-9a-f]{32}$`)
+
+func detect(files map[string]vfile) Source {
+	notion := 0
+	for name := range files {
+		ext := strings.ToLower(path.Ext(name))
+		if ext == ".enex" {
+			return SourceEvernote
+		}
+		if (ext == ".md" || ext == ".csv") && notionHash.MatchString(strings.TrimSuffix(path.Base(name), path.Ext(name))) {
+			notion++
+		}
+	}
+	if notion > 0 {
+		return SourceNotion
+	}
+	return SourceObsidian
+}
+
+// sortedNames 保证导入顺序稳定
+func sortedNames(files map[string]vfile) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func readAll(f vfile) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return f.budget.read(r, f.Size)
+}
+
+func mimeType(name string) string {
+	if t := mime.TypeByExtension(strings.ToLower(path.Ext(name))); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+func isImage(name string) bool {
+	return strings.HasPrefix(mimeType(name), "image/")
+}
+
+var frontMatter = regexp.MustCompile(`(?s)\A---\r?\n.*?\r?\n---\r?\n`)
+
+// attachmentSet 收集一篇笔记引用的附件，同一文件只导入一次
+type attachmentSet struct {
+	list  []Attachment
+	index map[string]int
+}
+
+func (s *attachmentSet) add(f vfile) string {
+	if s.index == nil {
+		s.index = map[string]int{}
+	}
+	if i, ok := s.index[f.Name]; ok {
+		return Placeholder(i)
+	}
+	i := len(s.list)
+	s.index[f.Name] = i
+	s.list = append(s.list, Attachment{Name: path.Base(f.Name), Type: mimeType(f.Name), Open: f.Open})
+	return Placeholder(i)
+}
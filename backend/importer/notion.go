@@ -0,0 +1,110 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"path"
+	"strings"
+)
+
+// parseNotion Notion 的 Markdown/CSV 导出：文件和目录名带 32 位哈希后缀，
+// 子页面放在与父页面同名的目录下，数据库导出为 CSV
+func parseNotion(files map[string]vfile) ([]Note, error) {
+	r := newResolver(files)
+	notes := make([]Note, 0)
+
+	for _, name := range sortedNames(files) {
+		ext := strings.ToLower(path.Ext(name))
+		if ext != ".md" && ext != ".csv" {
+			continue
+		}
+		// 同一个数据库还会导出一份 "_all.csv"，内容重复
+		if strings.HasSuffix(name, "_all.csv") {
+			continue
+		}
+
+		data, err := readAll(files[name])
+		if err != nil {
+			return nil, err
+		}
+		dir := path.Dir(name)
+		if dir == "." {
+			dir = ""
+		}
+
+		var (
+			atts    attachmentSet
+			content string
+		)
+		if ext == ".csv" {
+			content, err = csvToMarkdown(data)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			content = rewriteMarkdownLinks(string(data), dir, r, &atts, true)
+		}
+
+		notes = append(notes, Note{
+			Title:       noteTitle(name),
+			Content:     content,
+			Folder:      notionFolder(dir),
+			Attachments: atts.list,
+		})
+	}
+	return notes, nil
+}
+
+// notionFolder 去掉目录名中的哈希后缀
+func notionFolder(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	parts := strings.Split(dir, "/")
+	for i, p := range parts {
+		parts[i] = notionHash.ReplaceAllString(p, "")
+	}
+	return strings.Join(parts, "/")
+}
+
+// csvToMarkdown 将 Notion 数据库导出的 CSV 转为 Markdown 表格
+func csvToMarkdown(data []byte) (string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // Notion 的 CSV 带 BOM
+	rd := csv.NewReader(bytes.NewReader(data))
+	rd.FieldsPerRecord = -1
+	rows, err := rd.ReadAll()
+	if err != nil {
+		return "", err
+	}
+	if len(rows) == 0 {
+		return "", nil
+	}
+
+	cols := 0
+	for _, row := range rows {
+		if len(row) > cols {
+			cols = len(row)
+		}
+	}
+
+	var b strings.Builder
+	writeRow := func(row []string) {
+		b.WriteString("|")
+		for i := 0; i < cols; i++ {
+			cell := ""
+			if i < len(row) {
+				cell = strings.ReplaceAll(row[i], "|", `\|`)
+				cell = strings.ReplaceAll(cell, "\n", "<br>")
+			}
+			b.WriteString(" " + cell + " |")
+		}
+		b.WriteString("\n")
+	}
+
+	writeRow(rows[0])
+	b.WriteString("|" + strings.Repeat(" --- |", cols) + "\n")
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	return b.String(), nil
+}
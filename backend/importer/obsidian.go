@@ -0,0 +1,134 @@
+package importer
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ![[文件]] 或 ![[文件|尺寸]]
+	wikiEmbed = regexp.MustCompile(`!\[\[([^\]|#]+)(?:#[^\]|]*)?(?:\|[^\]]*)?\]\]`)
+	// [[笔记]]、[[笔记|别名]]、[[笔记#标题]]
+	wikiLink = regexp.MustCompile(`\[\[([^\]|#]+)(?:#[^\]|]*)?(?:\|[^\]]*)?\]\]`)
+	// Markdown 链接和图片的目标地址
+	mdLink = regexp.MustCompile(`(!?)\[([^\]]*)\]\(([^)\s]+)(\s+"[^"]*")?\)`)
+)
+
+// parseObsidian 目录即文件夹，.md 为笔记，其余文件按引用作为附件导入
+func parseObsidian(files map[string]vfile) ([]Note, error) {
+	r := newResolver(files)
+	notes := make([]Note, 0)
+
+	for _, name := range sortedNames(files) {
+		if !strings.EqualFold(path.Ext(name), ".md") {
+			continue
+		}
+		data, err := readAll(files[name])
+		if err != nil {
+			return nil, err
+		}
+		dir := path.Dir(name)
+		if dir == "." {
+			dir = ""
+		}
+
+		var atts attachmentSet
+		content := frontMatter.ReplaceAllString(string(data), "")
+
+		content = wikiEmbed.ReplaceAllStringFunc(content, func(m string) string {
+			target := strings.TrimSpace(wikiEmbed.FindStringSubmatch(m)[1])
+			f, ok := r.resolve(dir, target)
+			if !ok {
+				return m
+			}
+			if strings.EqualFold(path.Ext(f.Name), ".md") {
+				return "[[" + noteTitle(f.Name) + "]]"
+			}
+			if isImage(f.Name) {
+				return "![" + path.Base(f.Name) + "](" + atts.add(f) + ")"
+			}
+			return "[" + path.Base(f.Name) + "](" + atts.add(f) + ")"
+		})
+		content = wikiLink.ReplaceAllStringFunc(content, func(m string) string {
+			target := strings.TrimSpace(wikiLink.FindStringSubmatch(m)[1])
+			return "[[" + noteTitle(target) + "]]"
+		})
+		content = rewriteMarkdownLinks(content, dir, r, &atts, false)
+
+		notes = append(notes, Note{
+			Title:       noteTitle(name),
+			Content:     content,
+			Folder:      dir,
+			Attachments: atts.list,
+		})
+	}
+	return notes, nil
+}
+
+// rewriteMarkdownLinks 将指向压缩包内文件的相对链接改为附件占位地址；
+// 指向其他笔记的链接改为 [[标题]]
+func rewriteMarkdownLinks(content, dir string, r *resolver, atts *attachmentSet, notion bool) string {
+	return mdLink.ReplaceAllStringFunc(content, func(m string) string {
+		sub := mdLink.FindStringSubmatch(m)
+		bang, text, target := sub[1], sub[2], sub[3]
+		if strings.Contains(target, "://") || strings.HasPrefix(target, "#") || strings.HasPrefix(target, "mailto:") {
+			return m
+		}
+		decoded, err := url.PathUnescape(target)
+		if err != nil {
+			decoded = target
+		}
+		f, ok := r.resolve(dir, decoded)
+		if !ok {
+			return m
+		}
+		if strings.EqualFold(path.Ext(f.Name), ".md") || (notion && strings.EqualFold(path.Ext(f.Name), ".csv")) {
+			return "[[" + noteTitle(f.Name) + "]]"
+		}
+		return bang + "[" + text + "](" + atts.add(f) + ")"
+	})
+}
+
+// noteTitle 文件名去掉扩展名和 Notion 的 32 位哈希后缀
+func noteTitle(name string) string {
+	base := path.Base(name)
+	if ext := path.Ext(base); strings.EqualFold(ext, ".md") || strings.EqualFold(ext, ".csv") {
+		base = strings.TrimSuffix(base, ext)
+	}
+	return notionHash.ReplaceAllString(base, "")
+}
+
+// resolver 按 Obsidian 的规则查找引用的文件：先相对当前笔记，再相对仓库根目录，
+// 最后按文件名在整个仓库中查找
+type resolver struct {
+	files  map[string]vfile
+	byBase map[string]vfile
+}
+
+func newResolver(files map[string]vfile) *resolver {
+	r := &resolver{files: files, byBase: map[string]vfile{}}
+	for _, name := range sortedNames(files) {
+		base := strings.ToLower(path.Base(name))
+		if _, ok := r.byBase[base]; !ok {
+			r.byBase[base] = files[name]
+		}
+		noExt := strings.TrimSuffix(base, path.Ext(base))
+		if _, ok := r.byBase[noExt]; !ok && strings.EqualFold(path.Ext(name), ".md") {
+			r.byBase[noExt] = files[name]
+		}
+	}
+	return r
+}
+
+func (r *resolver) resolve(dir, target string) (vfile, bool) {
+	target = strings.TrimPrefix(target, "./")
+	for _, candidate := range []string{path.Join(dir, target), path.Clean(target)} {
+		if f, ok := r.files[candidate]; ok {
+			return f, true
+		}
+	}
+	f, ok := r.byBase[strings.ToLower(path.Base(target))]
+	return f, ok
+}
@@ -0,0 +1,222 @@
+// Package lunar 农历（中国阴阳历）与公历互相转换，支持 1900 - 2100 年，含闰月。
+// 每年的月份大小和闰月来自紫金山天文台历表的压缩数据
+package lunar
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	MinYear = 1900
+	MaxYear = 2100
+)
+
+// ErrOutOfRange 日期超出支持的范围
+var ErrOutOfRange = errors.New("lunar: date out of supported range (1900-2100)")
+
+// yearInfo 每个农历年一项：
+// bit 0-3 闰月月份（0 表示没有闰月），bit 4-15 正月到十二月是否为大月（30 天，正月在 bit 15），
+// bit 16 闰月是否为大月
+var yearInfo = [MaxYear - MinYear + 1]uint32{
+	0x04bd8, 0x04ae0, 0x0a570, 0x054d5, 0x0d260, 0x0d950, 0x16554, 0x056a0, 0x09ad0, 0x055d2, // 1900
+	0x04ae0, 0x0a5b6, 0x0a4d0, 0x0d250, 0x1d255, 0x0b540, 0x0d6a0, 0x0ada2, 0x095b0, 0x14977, // 1910
+	0x04970, 0x0a4b0, 0x0b4b5, 0x06a50, 0x06d40, 0x1ab54, 0x02b60, 0x09570, 0x052f2, 0x04970, // 1920
+	0x06566, 0x0d4a0, 0x0ea50, 0x16a95, 0x05ad0, 0x02b60, 0x186e3, 0x092e0, 0x1c8d7, 0x0c950, // 1930
+	0x0d4a0, 0x1d8a6, 0x0b550, 0x056a0, 0x1a5b4, 0x025d0, 0x092d0, 0x0d2b2, 0x0a950, 0x0b557, // 1940
+	0x06ca0, 0x0b550, 0x15355, 0x04da0, 0x0a5b0, 0x14573, 0x052b0, 0x0a9a8, 0x0e950, 0x06aa0, // 1950
+	0x0aea6, 0x0ab50, 0x04b60, 0x0aae4, 0x0a570, 0x05260, 0x0f263, 0x0d950, 0x05b57, 0x056a0, // 1960
+	0x096d0, 0x04dd5, 0x04ad0, 0x0a4d0, 0x0d4d4, 0x0d250, 0x0d558, 0x0b540, 0x0b6a0, 0x195a6, // 1970
+	0x095b0, 0x049b0, 0x0a974, 0x0a4b0, 0x0b27a, 0x06a50, 0x06d40, 0x0af46, 0x0ab60, 0x09570, // 1980
+	0x04af5, 0x04970, 0x064b0, 0x074a3, 0x0ea50, 0x06b58, 0x05ac0, 0x0ab60, 0x096d5, 0x092e0, // 1990
+	0x0c960, 0x0d954, 0x0d4a0, 0x0da50, 0x07552, 0x056a0, 0x0abb7, 0x025d0, 0x092d0, 0x0cab5, // 2000
+	0x0a950, 0x0b4a0, 0x0baa4, 0x0ad50, 0x055d9, 0x04ba0, 0x0a5b0, 0x15176, 0x052b0, 0x0a930, // 2010
+	0x07954, 0x06aa0, 0x0ad50, 0x05b52, 0x04b60, 0x0a6e6, 0x0a4e0, 0x0d260, 0x0ea65, 0x0d530, // 2020
+	0x05aa0, 0x076a3, 0x096d0, 0x04afb, 0x04ad0, 0x0a4d0, 0x1d0b6, 0x0d250, 0x0d520, 0x0dd45, // 2030
+	0x0b5a0, 0x056d0, 0x055b2, 0x049b0, 0x0a577, 0x0a4b0, 0x0aa50, 0x1b255, 0x06d20, 0x0ada0, // 2040
+	0x14b63, 0x09370, 0x049f8, 0x04970, 0x064b0, 0x168a6, 0x0ea50, 0x06b20, 0x1a6c4, 0x0aae0, // 2050
+	0x092e0, 0x0d2e3, 0x0c960, 0x0d557, 0x0d4a0, 0x0da50, 0x05d55, 0x056a0, 0x0a6d0, 0x055d4, // 2060
+	0x052d0, 0x0a9b8, 0x0a950, 0x0b4a0, 0x0b6a6, 0x0ad50, 0x055a0, 0x0aba4, 0x0a5b0, 0x052b0, // 2070
+	0x0b273, 0x06930, 0x07337, 0x06aa0, 0x0ad50, 0x14b55, 0x04b60, 0x0a570, 0x054e4, 0x0d160, // 2080
+	0x0e968, 0x0d520, 0x0daa0, 0x16aa6, 0x056d0, 0x04ae0, 0x0a9d4, 0x0a2d0, 0x0d150, 0x0f252, // 2090
+	0x0d520, // 2100
+}
+
+// epoch 农历 1900 年正月初一对应的公历日期
+var epoch = time.Date(1900, time.January, 31, 0, 0, 0, 0, time.UTC)
+
+// Date 农历日期
+type Date struct {
+	Year  int  `json:"year"`
+	Month int  `json:"month"` // 1-12
+	Day   int  `json:"day"`   // 1-30
+	Leap  bool `json:"leap"`  // 闰月
+}
+
+// LeapMonth 该年闰几月，没有闰月返回 0
+func LeapMonth(year int) int {
+	if year < MinYear || year > MaxYear {
+		return 0
+	}
+	return int(yearInfo[year-MinYear] & 0xf)
+}
+
+// MonthDays 该月的天数（29 或 30），月份不存在时返回 0
+func MonthDays(year, month int, leap bool) int {
+	if year < MinYear || year > MaxYear || month < 1 || month > 12 {
+		return 0
+	}
+	info := yearInfo[year-MinYear]
+	if leap {
+		if LeapMonth(year) != month {
+			return 0
+		}
+		if info&0x10000 != 0 {
+			return 30
+		}
+		return 29
+	}
+	if info&(0x10000>>month) != 0 {
+		return 30
+	}
+	return 29
+}
+
+// YearDays 该农历年的总天数
+func YearDays(year int) int {
+	days := 0
+	for m := 1; m <= 12; m++ {
+		days += MonthDays(year, m, false)
+	}
+	if leap := LeapMonth(year); leap != 0 {
+		days += MonthDays(year, leap, true)
+	}
+	return days
+}
+
+// Valid 日期在该年是否存在
+func (d Date) Valid() bool {
+	return d.Day >= 1 && d.Day <= MonthDays(d.Year, d.Month, d.Leap)
+}
+
+// FromSolar 公历日期转农历，按 t 所在时区的日期计算
+func FromSolar(t time.Time) (Date, error) {
+	y, m, d := t.Date()
+	offset := int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Sub(epoch).Hours() / 24)
+	if offset < 0 {
+		return Date{}, ErrOutOfRange
+	}
+
+	year := MinYear
+	for ; year <= MaxYear; year++ {
+		days := YearDays(year)
+		if offset < days {
+			break
+		}
+		offset -= days
+	}
+	if year > MaxYear {
+		return Date{}, ErrOutOfRange
+	}
+
+	leap := LeapMonth(year)
+	for month := 1; month <= 12; month++ {
+		days := MonthDays(year, month, false)
+		if offset < days {
+			return Date{Year: year, Month: month, Day: offset + 1}, nil
+		}
+		offset -= days
+		if month == leap {
+			days = MonthDays(year, month, true)
+			if offset < days {
+				return Date{Year: year, Month: month, Day: offset + 1, Leap: true}, nil
+			}
+			offset -= days
+		}
+	}
+	return Date{}, ErrOutOfRange // 不会发生：offset 小于全年天数
+}
+
+// Solar 农历转公历，返回 loc 时区当天零点
+func (d Date) Solar(loc *time.Location) (time.Time, error) {
+	if d.Year < MinYear || d.Year > MaxYear {
+		return time.Time{}, ErrOutOfRange
+	}
+	if !d.Valid() {
+		return time.Time{}, fmt.Errorf("lunar: %s does not exist", d)
+	}
+
+	offset := 0
+	for y := MinYear; y < d.Year; y++ {
+		offset += YearDays(y)
+	}
+	leap := LeapMonth(d.Year)
+	for m := 1; m < d.Month; m++ {
+		offset += MonthDays(d.Year, m, false)
+		if m == leap {
+			offset += MonthDays(d.Year, m, true)
+		}
+	}
+	if d.Leap {
+		offset += MonthDays(d.Year, d.Month, false)
+	}
+	offset += d.Day - 1
+
+	t := epoch.AddDate(0, 0, offset)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc), nil
+}
+
+// InYear 某个农历月日在 year 年对应的日期，用于每年重复的农历事件：
+// 该年没有对应的闰月时使用同名的普通月；三十日在小月时使用二十九日
+func InYear(year, month, day int, leap bool) (Date, error) {
+	if year < MinYear || year > MaxYear {
+		return Date{}, ErrOutOfRange
+	}
+	if month < 1 || month > 12 || day < 1 || day > 30 {
+		return Date{}, fmt.Errorf("lunar: invalid month %d day %d", month, day)
+	}
+	d := Date{Year: year, Month: month, Day: day, Leap: leap && LeapMonth(year) == month}
+	if days := MonthDays(year, month, d.Leap); d.Day > days {
+		d.Day = days
+	}
+	return d, nil
+}
+
+var (
+	monthNames = [...]string{"正", "二", "三", "四", "五", "六", "七", "八", "九", "十", "冬", "腊"}
+	dayTens    = [...]string{"初", "十", "廿", "三"}
+	dayOnes    = [...]string{"一", "二", "三", "四", "五", "六", "七", "八", "九", "十"}
+)
+
+// MonthName 月份的中文名，如 "正月"、"闰四月"、"腊月"
+func (d Date) MonthName() string {
+	if d.Month < 1 || d.Month > 12 {
+		return ""
+	}
+	name := monthNames[d.Month-1] + "月"
+	if d.Leap {
+		name = "闰" + name
+	}
+	return name
+}
+
+// DayName 日期的中文名，如 "初一"、"十五"、"廿三"、"三十"
+func (d Date) DayName() string {
+	switch {
+	case d.Day < 1 || d.Day > 30:
+		return ""
+	case d.Day == 10:
+		return "初十"
+	case d.Day == 20:
+		return "二十"
+	case d.Day == 30:
+		return "三十"
+	}
+	return dayTens[d.Day/10] + dayOnes[(d.Day-1)%10]
+}
+
+// String 如 "2026年闰四月初八"
+func (d Date) String() string {
+	return fmt.Sprintf("%d年%s%s", d.Year, d.MonthName(), d.DayName())
+}
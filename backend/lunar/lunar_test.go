@@ -0,0 +1,158 @@
+package lunar
+
+import (
+	"testing"
+	"time"
+)
+
+func day(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// 已知的农历日期（香港天文台 / 紫金山天文台历表）
+var knownDates = []struct {
+	solar time.Time
+	lunar Date
+	label string
+}{
+	{day(1900, 1, 31), Date{1900, 1, 1, false}, "1900年正月初一"},
+	{day(2017, 7, 23), Date{2017, 6, 1, true}, "2017年闰六月初一"},
+	{day(2020, 5, 23), Date{2020, 4, 1, true}, "2020年闰四月初一"},
+	{day(2023, 3, 22), Date{2023, 2, 1, true}, "2023年闰二月初一"},
+	{day(2024, 2, 10), Date{2024, 1, 1, false}, "2024年正月初一"},
+	{day(2025, 1, 29), Date{2025, 1, 1, false}, "2025年正月初一"},
+	{day(2025, 6, 25), Date{2025, 6, 1, false}, "2025年六月初一"},
+	{day(2025, 7, 25), Date{2025, 6, 1, true}, "2025年闰六月初一"},
+	{day(2026, 2, 16), Date{2025, 12, 29, false}, "2025年腊月廿九"},
+	{day(2026, 2, 17), Date{2026, 1, 1, false}, "2026年正月初一"},
+	{day(2026, 9, 25), Date{2026, 8, 15, false}, "2026年八月十五"},
+	{day(2033, 11, 22), Date{2033, 11, 1, false}, "2033年冬月初一"},
+	{day(2033, 12, 22), Date{2033, 11, 1, true}, "2033年闰冬月初一"},
+	{day(2034, 2, 19), Date{2034, 1, 1, false}, "2034年正月初一"},
+}
+
+func TestFromSolar(t *testing.T) {
+	for _, tt := range knownDates {
+		got, err := FromSolar(tt.solar)
+		if err != nil {
+			t.Errorf("FromSolar(%s): %v", tt.solar.Format("2006-01-02"), err)
+			continue
+		}
+		if got != tt.lunar || got.String() != tt.label {
+			t.Errorf("FromSolar(%s) = %+v %s, want %+v %s", tt.solar.Format("2006-01-02"), got, got, tt.lunar, tt.label)
+		}
+	}
+
+	for _, tm := range []time.Time{day(1900, 1, 30), day(2101, 12, 31)} {
+		if _, err := FromSolar(tm); err != ErrOutOfRange {
+			t.Errorf("FromSolar(%s) err = %v, want ErrOutOfRange", tm.Format("2006-01-02"), err)
+		}
+	}
+}
+
+func TestSolar(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	for _, tt := range knownDates {
+		got, err := tt.lunar.Solar(shanghai)
+		if err != nil {
+			t.Errorf("%s.Solar: %v", tt.label, err)
+			continue
+		}
+		y, m, d := got.Date()
+		if got.Location() != shanghai || got.Hour() != 0 || !day(y, m, d).Equal(tt.solar) {
+			t.Errorf("%s.Solar = %v, want %s", tt.label, got, tt.solar.Format("2006-01-02"))
+		}
+	}
+
+	// 不存在的日期
+	for _, d := range []Date{
+		{2026, 6, 1, true},   // 2026 年没有闰六月
+		{2025, 5, 1, true},   // 2025 年闰六月，不是闰五月
+		{2026, 1, 31, false}, // 没有三十一日
+		{2101, 1, 1, false},
+	} {
+		if _, err := d.Solar(time.UTC); err == nil {
+			t.Errorf("%+v.Solar succeeded, want error", d)
+		}
+	}
+}
+
+// 农历与公历互相转换一致
+func TestRoundTrip(t *testing.T) {
+	for tm := day(2020, 1, 1); tm.Before(day(2036, 1, 1)); tm = tm.AddDate(0, 0, 1) {
+		d, err := FromSolar(tm)
+		if err != nil {
+			t.Fatalf("FromSolar(%s): %v", tm.Format("2006-01-02"), err)
+		}
+		back, err := d.Solar(time.UTC)
+		if err != nil || !back.Equal(tm) {
+			t.Fatalf("%s -> %+v -> %v, %v", tm.Format("2006-01-02"), d, back, err)
+		}
+	}
+}
+
+func TestLeapMonth(t *testing.T) {
+	tests := []struct{ year, leap int }{
+		{2017, 6}, {2020, 4}, {2023, 2}, {2024, 0}, {2025, 6}, {2026, 0}, {2028, 5}, {2033, 11}, {1899, 0}, {2101, 0},
+	}
+	for _, tt := range tests {
+		if got := LeapMonth(tt.year); got != tt.leap {
+			t.Errorf("LeapMonth(%d) = %d, want %d", tt.year, got, tt.leap)
+		}
+	}
+	// 闰月年 13 个月，383 - 385 天；平年 353 - 355 天
+	for year := MinYear; year <= MaxYear; year++ {
+		days := YearDays(year)
+		if LeapMonth(year) != 0 && (days < 383 || days > 385) || LeapMonth(year) == 0 && (days < 353 || days > 355) {
+			t.Errorf("YearDays(%d) = %d with leap month %d", year, days, LeapMonth(year))
+		}
+	}
+}
+
+func TestInYear(t *testing.T) {
+	tests := []struct {
+		year, month, day int
+		leap             bool
+		want             Date
+	}{
+		{2025, 6, 1, true, Date{2025, 6, 1, true}},
+		{2026, 6, 1, true, Date{2026, 6, 1, false}},      // 没有闰六月时用六月
+		{2033, 11, 15, true, Date{2033, 11, 15, true}},   // 闰冬月
+		{2034, 11, 15, true, Date{2034, 11, 15, false}},  // 次年没有闰冬月
+		{2025, 12, 30, false, Date{2025, 12, 29, false}}, // 2025 年腊月小，除夕为廿九
+	}
+	for _, tt := range tests {
+		got, err := InYear(tt.year, tt.month, tt.day, tt.leap)
+		if err != nil || got != tt.want {
+			t.Errorf("InYear(%d, %d, %d, %v) = %+v, %v; want %+v", tt.year, tt.month, tt.day, tt.leap, got, err, tt.want)
+		}
+	}
+	if _, err := InYear(2026, 13, 1, false); err == nil {
+		t.Error("InYear accepted month 13")
+	}
+	if _, err := InYear(2102, 1, 1, false); err != ErrOutOfRange {
+		t.Errorf("InYear(2102) err = %v", err)
+	}
+}
+
+func TestNames(t *testing.T) {
+	tests := []struct {
+		d           Date
+		month, name string
+	}{
+		{Date{2026, 1, 1, false}, "正月", "初一"},
+		{Date{2026, 4, 10, true}, "闰四月", "初十"},
+		{Date{2026, 11, 20, false}, "冬月", "二十"},
+		{Date{2033, 11, 23, true}, "闰冬月", "廿三"},
+		{Date{2026, 12, 30, false}, "腊月", "三十"},
+		{Date{2026, 8, 15, false}, "八月", "十五"},
+	}
+	for _, tt := range tests {
+		if got := tt.d.MonthName(); got != tt.month {
+			t.Errorf("%+v.MonthName() = %q, want %q", tt.d, got, tt.month)
+		}
+		if got := tt.d.DayName(); got != tt.name {
+			t.Errorf("%+v.DayName() = %q, want %q", tt.d, got, tt.name)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package lunar
+
+import "time"
+
+// YearlyRule 按农历每年（或每隔 Interval 年）重复，如农历生日。
+// 从 dtstart 所在的农历年开始，每年取 InYear(year, Month, Day, Leap) 对应的公历日期，时间与 dtstart 相同
+type YearlyRule struct {
+	Month    int
+	Day      int
+	Leap     bool
+	Interval int       // 0 视为 1
+	Count    int       // 0 表示不限
+	Until    time.Time // 零值表示不限，包含该时刻
+}
+
+// Between 返回 [from, to) 内的重复时间，按时间排序
+func (r *YearlyRule) Between(dtstart, from, to time.Time) []time.Time {
+	var result []time.Time
+	r.each(dtstart, func(t time.Time) bool {
+		if !t.Before(to) {
+			return false
+		}
+		if !t.Before(from) {
+			result = append(result, t)
+		}
+		return true
+	})
+	return result
+}
+
+// Next 返回 after 之后（不含）的第一次重复
+func (r *YearlyRule) Next(dtstart, after time.Time) (time.Time, bool) {
+	var next time.Time
+	found := false
+	r.each(dtstart, func(t time.Time) bool {
+		if t.After(after) {
+			next, found = t, true
+			return false
+		}
+		return true
+	})
+	return next, found
+}
+
+// each 按时间顺序产生重复时间，直到 fn 返回 false、达到 COUNT / UNTIL 或超出支持的年份
+func (r *YearlyRule) each(dtstart time.Time, fn func(time.Time) bool) {
+	start, err := FromSolar(dtstart)
+	if err != nil {
+		return
+	}
+	interval := max(r.Interval, 1)
+	loc := dtstart.Location()
+	hour, min, sec := dtstart.Clock()
+
+	count := 0
+	for year := start.Year; year <= MaxYear; year += interval {
+		d, err := InYear(year, r.Month, r.Day, r.Leap)
+		if err != nil {
+			return
+		}
+		day, err := d.Solar(loc)
+		if err != nil {
+			return
+		}
+		t := time.Date(day.Year(), day.Month(), day.Day(), hour, min, sec, 0, loc)
+		if t.Before(dtstart) {
+			continue
+		}
+		if !r.Until.IsZero() && t.After(r.Until) {
+			return
+		}
+		count++
+		if r.Count > 0 && count > r.Count {
+			return
+		}
+		if !fn(t) {
+			return
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package lunar
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestYearlyRule(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	at := func(y int, m time.Month, d, hour int) time.Time {
+		return time.Date(y, m, d, hour, 0, 0, 0, shanghai)
+	}
+	tests := []struct {
+		name     string
+		rule     YearlyRule
+		dtstart  time.Time
+		from, to time.Time
+		want     []string
+	}{
+		{
+			// 2025 - 2029 年腊月都是小月，没有三十
+			name:    "除夕",
+			rule:    YearlyRule{Month: 12, Day: 30},
+			dtstart: at(2024, 2, 9, 20),
+			from:    at(2024, 1, 1, 0),
+			to:      at(2031, 1, 1, 0),
+			want:    []string{"2024-02-09 20:00", "2025-01-28 20:00", "2026-02-16 20:00", "2027-02-05 20:00", "2028-01-25 20:00", "2029-02-12 20:00", "2030-02-02 20:00"},
+		},
+		{
+			name:    "中秋",
+			rule:    YearlyRule{Month: 8, Day: 15},
+			dtstart: at(2026, 9, 25, 19),
+			from:    at(2026, 1, 1, 0),
+			to:      at(2029, 1, 1, 0),
+			want:    []string{"2026-09-25 19:00", "2027-09-15 19:00", "2028-10-03 19:00"},
+		},
+		{
+			name:    "from 之前的不返回",
+			rule:    YearlyRule{Month: 8, Day: 15},
+			dtstart: at(2026, 9, 25, 19),
+			from:    at(2027, 9, 15, 19),
+			to:      at(2028, 10, 3, 19),
+			want:    []string{"2027-09-15 19:00"},
+		},
+		{
+			name:    "interval",
+			rule:    YearlyRule{Month: 1, Day: 1, Interval: 2},
+			dtstart: at(2024, 2, 10, 0),
+			from:    at(2024, 1, 1, 0),
+			to:      at(2031, 1, 1, 0),
+			want:    []string{"2024-02-10 00:00", "2026-02-17 00:00", "2028-01-26 00:00", "2030-02-03 00:00"},
+		},
+		{
+			name:    "count",
+			rule:    YearlyRule{Month: 1, Day: 1, Count: 2},
+			dtstart: at(2025, 1, 29, 0),
+			from:    at(2025, 1, 1, 0),
+			to:      at(2031, 1, 1, 0),
+			want:    []string{"2025-01-29 00:00", "2026-02-17 00:00"},
+		},
+		{
+			name:    "until",
+			rule:    YearlyRule{Month: 1, Day: 1, Until: at(2027, 2, 6, 0)},
+			dtstart: at(2025, 1, 29, 0),
+			from:    at(2025, 1, 1, 0),
+			to:      at(2031, 1, 1, 0),
+			want:    []string{"2025-01-29 00:00", "2026-02-17 00:00", "2027-02-06 00:00"},
+		},
+		{
+			// dtstart 在农历年中间时从当年开始，早于 dtstart 的跳过
+			name:    "dtstart 晚于当年的日期",
+			rule:    YearlyRule{Month: 1, Day: 1},
+			dtstart: at(2026, 3, 1, 9),
+			from:    at(2026, 1, 1, 0),
+			to:      at(2028, 1, 1, 0),
+			want:    []string{"2027-02-06 09:00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, tm := range tt.rule.Between(tt.dtstart, tt.from, tt.to) {
+				got = append(got, tm.In(shanghai).Format("2006-01-02 15:04"))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Between\n got  %q\n want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// 闰月生日：有该闰月的年份在闰月，其他年份在同名的普通月
+func TestYearlyRuleLeapMonth(t *testing.T) {
+	rule := YearlyRule{Month: 6, Day: 1, Leap: true}
+	dtstart := time.Date(2025, 7, 25, 8, 0, 0, 0, time.UTC)
+	times := rule.Between(dtstart, dtstart, time.Date(2037, 1, 1, 0, 0, 0, 0, time.UTC))
+	if len(times) != 12 {
+		t.Fatalf("got %d occurrences, want 12", len(times))
+	}
+	for i, tm := range times {
+		d, err := FromSolar(tm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Year != 2025+i || d.Month != 6 || d.Day != 1 || d.Leap != (LeapMonth(d.Year) == 6) || tm.Hour() != 8 {
+			t.Errorf("occurrence %d: %v is %s", i, tm, d)
+		}
+	}
+	if !times[0].Equal(dtstart) {
+		t.Errorf("first occurrence %v, want %v", times[0], dtstart)
+	}
+
+	next, ok := rule.Next(dtstart, dtstart)
+	if !ok || !next.Equal(times[1]) {
+		t.Errorf("Next = %v, %v; want %v", next, ok, times[1])
+	}
+	once := &YearlyRule{Month: 1, Day: 1, Count: 1}
+	first, ok := once.Next(dtstart, dtstart)
+	if want := time.Date(2026, 2, 17, 8, 0, 0, 0, time.UTC); !ok || !first.Equal(want) {
+		t.Fatalf("Next = %v, %v; want %v", first, ok, want)
+	}
+	if _, ok := once.Next(dtstart, first); ok {
+		t.Error("Next after the only occurrence returned ok")
+	}
+}
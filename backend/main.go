@@ -1,10 +1,16 @@
 package main
 
 import (
+	"errors"
 	"gonote/db"
+	"gonote/export"
 	"gonote/handlers"
+	"gonote/holiday"
 	"gonote/middleware"
+	"gonote/storage"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,27 +18,43 @@ import (
 func main() {
 	// Initialize DB
 	db.Connect()
+	storage.Init()
+	holiday.Default() // 启动时加载节假日数据，数据文件有误时尽早记录日志
+	loadPDFFont()
+	handlers.MigrateLegacyAttachmentPaths()
+	handlers.MigrateLegacyUploads() // 正文中的 /uploads/ 链接改为附件地址，需在 GC 之前
+	handlers.FailInterruptedImports()
+	handlers.StartBlobGC(6 * time.Hour)
+	handlers.StartUploadExpiry(time.Hour)
+	handlers.StartTextExtraction(10 * time.Minute)
+	handlers.StartEventReminders(time.Minute)
 
-	r := gin.Default()
+	r := gin.New()
+	r.Use(middleware.Logger(), gin.Recovery()) // 访问日志隐藏 URL 中的票据和签名
 
 	// CORS Middleware
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, HEAD, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Tus-Resumable, Upload-Length, Upload-Offset, Upload-Metadata, Last-Event-ID")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Location, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, Upload-Offset, Upload-Length, Upload-Expires, Upload-Attachment-Id")
 		if c.Request.Method == "OPTIONS" {
+			if strings.HasPrefix(c.Request.URL.Path, "/api/uploads") {
+				handlers.TusOptions(c)
+				return
+			}
 			c.AbortWithStatus(204)
 			return
 		}
 		c.Next()
 	})
 
-	// Static Files (Uploads)
-	r.Static("/uploads", "./uploads")
-
 	// JWT 认证中间件
 	r.Use(middleware.JWTAuthMiddleware())
 
+	// 旧版本上传文件的链接，需要登录且有所属笔记的访问权限
+	r.GET("/uploads/:name", handlers.ServeLegacyUpload)
+
 	api := r.Group("/api")
 	{
 		// 认证相关（无需 Token）
@@ -54,20 +76,97 @@ func main() {
 		// 事件相关
 		api.GET("/events", handlers.GetEvents)
 		api.POST("/events", handlers.CreateEvent)
+		api.PUT("/events/:id", handlers.UpdateEvent)
+		api.PATCH("/events/:id", handlers.UpdateEvent)
 		api.DELETE("/events/:id", handlers.DeleteEvent)
+		api.PUT("/events/:id/occurrences/:date", handlers.UpdateEventOccurrence)    // 单独修改重复事件的一次
+		api.DELETE("/events/:id/occurrences/:date", handlers.CancelEventOccurrence) // 取消重复事件的一次
+
+		// 农历
+		api.GET("/lunar/from-solar", handlers.SolarToLunar) // 公历转农历
+		api.GET("/lunar/to-solar", handlers.LunarToSolar)   // 农历转公历
 
 		// 笔记相关
 		api.GET("/notes", handlers.GetNotes)
 		api.POST("/notes", handlers.CreateNote)
 		api.PUT("/notes/:id", handlers.UpdateNote)
 		api.DELETE("/notes/:id", handlers.DeleteNote)
+		api.PUT("/notes/:id/pin", handlers.SetNotePinned)
+		api.PUT("/notes/:id/favorite", handlers.SetNoteFavorite)
+		api.PUT("/notes/:id/archive", handlers.ArchiveNote)
+		api.POST("/notes/:id/move", handlers.MoveNote)
+		api.POST("/notes/:id/copy", handlers.CopyNote)
+		api.GET("/notes/:id/render", handlers.RenderNote)
+
+		// 导出
+		api.GET("/notes/:id/export", handlers.ExportNote)
+		api.GET("/export/folders/:id", handlers.ExportFolder)
+		api.GET("/export/families/:id", handlers.ExportFamily)
+		api.GET("/export/account", handlers.ExportAccount)
+
+		// 导入
+		api.POST("/import", handlers.ImportNotes)
+		api.GET("/import/:id", handlers.GetImportJob)
+
+		// 日记相关
+		api.GET("/journal/today", handlers.GetTodayJournal)       // 查找或创建今天的日记
+		api.GET("/journal/calendar", handlers.GetJournalCalendar) // 某月有日记的日期
+		api.GET("/journal/day/:date", handlers.GetJournalDay)     // 日视图：日记 + 事件
+		api.PUT("/journal/settings", handlers.UpdateJournalSettings)
 
 		// 扩展功能
-		api.POST("/upload", handlers.UploadFile)
+		api.POST("/notes/:id/attachments", handlers.UploadAttachment)
+		api.GET("/notes/:id/attachments", handlers.GetAttachments)
+		api.DELETE("/notes/:id/attachments/:attachmentId", handlers.DeleteAttachment)
+		api.GET("/attachments/:id/download", handlers.DownloadAttachment)
+		api.GET("/attachments/:id/signed-url", handlers.GetAttachmentSignedURL)
+		api.GET("/files/:id", handlers.ServeSignedFile) // 签名链接，无需 Token
+		api.GET("/storage/usage", handlers.GetStorageUsage)
+
+		// 可续传上传（tus 协议）
+		api.POST("/uploads", handlers.CreateUpload)
+		api.HEAD("/uploads/:id", handlers.GetUploadOffset)
+		api.GET("/uploads/:id", handlers.GetUpload)
+		api.PATCH("/uploads/:id", handlers.PatchUpload)
+		api.DELETE("/uploads/:id", handlers.DeleteUpload)
 		api.POST("/notes/:id/comments", handlers.AddComment)
+		api.GET("/notes/:id/comments", handlers.GetComments)
+		api.PUT("/notes/:id/comments/:commentId", handlers.UpdateComment)
+		api.DELETE("/notes/:id/comments/:commentId", handlers.DeleteComment)
+		api.PUT("/notes/:id/comments/:commentId/resolve", handlers.ResolveComment)
+
+		// 表情回应
+		api.POST("/notes/:id/reactions", handlers.AddNoteReaction)
+		api.DELETE("/notes/:id/reactions/:emoji", handlers.RemoveNoteReaction)
+		api.POST("/notes/:id/comments/:commentId/reactions", handlers.AddCommentReaction)
+		api.DELETE("/notes/:id/comments/:commentId/reactions/:emoji", handlers.RemoveCommentReaction)
 		api.GET("/users/search", handlers.SearchUsers)
+
+		// 通知
+		api.GET("/notifications", handlers.GetNotifications)
+		api.GET("/notifications/unread-count", handlers.GetUnreadNotificationCount)
+		api.PUT("/notifications/read-all", handlers.MarkAllNotificationsRead)
+		api.PUT("/notifications/:id/read", handlers.MarkNotificationRead)
+
+		// 实时推送（SSE）
+		api.POST("/stream/ticket", handlers.CreateStreamTicket) // 换取事件流票据
+		api.GET("/stream", handlers.Stream)                     // ?ticket=...
 	}
 
 	log.Println("Server starting on :8080")
 	r.Run(":8080")
 }
+
+// loadPDFFont 内置字体没有中文字形，PDF 导出中文需要 TTF 字体（见 README）。
+// GONOTE_PDF_FONT 指定的字体无法使用时拒绝启动；没有可用的字体时只记录警告，含中文的内容导出 PDF 返回 501
+func loadPDFFont() {
+	path, err := export.LoadPDFFont()
+	switch {
+	case errors.Is(err, export.ErrNoCJKFont):
+		log.Printf("WARNING: no CJK font for PDF export, notes with Chinese text cannot be exported as PDF; install fonts-droid-fallback or set %s", export.PDFFontEnv)
+	case err != nil:
+		log.Fatalf("Failed to load PDF font: %v", err)
+	default:
+		log.Printf("PDF export font: %s", path)
+	}
+}
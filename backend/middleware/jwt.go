@@ -64,13 +64,26 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 跳过认证的路由
 		path := c.Request.URL.Path
-		if strings.HasPrefix(path, "/api/auth/") {
+		// 签名链接自带鉴权，见 VerifyAttachmentSignature
+		if strings.HasPrefix(path, "/api/auth/") || strings.HasPrefix(path, "/api/files/") {
 			c.Next()
 			return
 		}
 
 		// 从 Header 获取 Token
 		authHeader := c.GetHeader("Authorization")
+		// EventSource 无法设置请求头，事件流使用短期票据，见 SignStreamTicket
+		if authHeader == "" && path == "/api/stream" && c.Query("ticket") != "" {
+			userId, ok := VerifyStreamTicket(c.Query("ticket"))
+			if !ok {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "票据无效或已过期"})
+				c.Abort()
+				return
+			}
+			c.Set("userId", userId)
+			c.Next()
+			return
+		}
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "请先登录"})
 			c.Abort()
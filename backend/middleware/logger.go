@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redactedParams 访问日志中隐藏的查询参数
+var redactedParams = []string{"ticket", "access_token", "sig"}
+
+// Logger 与 gin 默认的访问日志格式相同，但隐藏 URL 中的票据和签名
+func Logger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		if param.Latency > time.Minute {
+			param.Latency = param.Latency.Truncate(time.Second)
+		}
+		var statusColor, methodColor, resetColor string
+		if param.IsOutputColor() {
+			statusColor, methodColor, resetColor = param.StatusCodeColor(), param.MethodColor(), param.ResetColor()
+		}
+		return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			statusColor, param.StatusCode, resetColor,
+			param.Latency,
+			param.ClientIP,
+			methodColor, param.Method, resetColor,
+			redactPath(param.Path),
+			param.ErrorMessage,
+		)
+	})
+}
+
+func redactPath(path string) string {
+	i := strings.IndexByte(path, '?')
+	if i < 0 {
+		return path
+	}
+	query, err := url.ParseQuery(path[i+1:])
+	if err != nil {
+		return path[:i] + "?REDACTED"
+	}
+	changed := false
+	for _, key := range redactedParams {
+		if query.Has(key) {
+			query.Set(key, "REDACTED")
+			changed = true
+		}
+	}
+	if !changed {
+		return path
+	}
+	return path[:i] + "?" + query.Encode()
+}
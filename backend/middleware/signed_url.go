@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// SignedURLTTL 签名链接的最短有效期
+const SignedURLTTL = time.Hour
+
+// SignedURLExpiry 返回签名的过期时间，按 SignedURLTTL 对齐，
+// 同一时间段内生成的链接相同，便于浏览器缓存
+func SignedURLExpiry(now time.Time) time.Time {
+	bucket := now.Unix() / int64(SignedURLTTL.Seconds())
+	return time.Unix((bucket+2)*int64(SignedURLTTL.Seconds()), 0)
+}
+
+// SignAttachment 生成附件下载签名，用于 <img> 等无法携带 Authorization 的场景
+func SignAttachment(attachmentId string, expires time.Time) string {
+	mac := hmac.New(sha256.New, jwtSecret)
+	mac.Write([]byte(attachmentId + ":" + strconv.FormatInt(expires.Unix(), 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyAttachmentSignature 校验签名和过期时间
+func VerifyAttachmentSignature(attachmentId, expires, sig string) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	expected := SignAttachment(attachmentId, time.Unix(exp, 0))
+	return hmac.Equal([]byte(expected), []byte(sig))
+}
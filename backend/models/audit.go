@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditLog 操作审计记录
+type AuditLog struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     string    `gorm:"index" json:"userId"`
+	Action     string    `gorm:"index" json:"action"` // e.g. "note.move", "note.copy"
+	TargetType string    `json:"targetType"`
+	TargetID   string    `gorm:"index" json:"targetId"`
+	Detail     string    `gorm:"type:text" json:"detail"` // JSON
+	CreatedAt  time.Time `json:"createdAt"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+)
+
+// Blob 按内容 SHA-256 存储的文件，相同内容只保存一份。
+// 引用计数即 Hash 相同的 Attachment 数量，无引用的 Blob 由 GC 删除
+type Blob struct {
+	Hash      string    `gorm:"primaryKey" json:"hash"`
+	Key       string    `gorm:"uniqueIndex" json:"key"` // 存储后端中的 key
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"` // 最近一次被引用的时间
+}
+
+// Thumbnail 图片附件的缩略图，按原图内容生成，相同内容的附件共用
+type Thumbnail struct {
+	SourceHash string    `gorm:"primaryKey" json:"sourceHash"` // 原图 Blob
+	Size       string    `gorm:"primaryKey" json:"size"`       // small, medium, large
+	Format     string    `gorm:"primaryKey" json:"format"`     // webp, jpeg, png
+	Hash       string    `gorm:"index" json:"hash"`            // 缩略图 Blob
+	Width      int       `json:"width"`
+	Height     int       `json:"height"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// BlobText 从附件内容中提取的文本，用于搜索，相同内容的附件共用
+type BlobText struct {
+	Hash          string     `gorm:"primaryKey" json:"hash"`
+	Status        string     `json:"status"` // done, failed（内容无法解析，不再重试）, retry（读取失败，稍后重试）
+	Text          string     `gorm:"type:text" json:"-"`
+	Error         string     `json:"error,omitempty"`
+	Attempts      int        `json:"attempts"`                             // 读取失败的次数
+	NextAttemptAt *time.Time `gorm:"index" json:"nextAttemptAt,omitempty"` // status 为 retry 时下次重试的时间
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// LegacyBlob 旧版本留下的、不在 sha256/ 下的文件（旧版上传目录中的文件、按内容存储之前的附件），
+// 迁移为附件后记录在此，没有引用时由 GC 删除。存储中其他不认识的对象一律不动，S3 桶可能与其他程序共用
+type LegacyBlob struct {
+	Key       string    `gorm:"primaryKey" json:"key"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Migration 已完成的一次性数据迁移
+type Migration struct {
+	Name      string    `gorm:"primaryKey" json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
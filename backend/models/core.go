@@ -14,6 +14,10 @@ type User struct {
 	// FamilyID 已移除，改用 FamilyMember 多对多关联
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
+
+	// 日记设置
+	Timezone        string `gorm:"default:'Asia/Shanghai'" json:"timezone"` // IANA 时区名
+	JournalTemplate string `gorm:"type:text" json:"journalTemplate"`        // 为空时使用默认模板
 }
 
 type Note struct {
@@ -27,11 +31,29 @@ type Note struct {
 	FolderID string  `gorm:"index" json:"folderId"`
 	Title    string  `json:"title"`
 	Content  string  `gorm:"type:text" json:"content"`
+	Version  int64   `gorm:"default:1" json:"version"` // 每次更新内容递增，用于渲染缓存
+
+	// 日记笔记的日期 (YYYY-MM-DD)，普通笔记为空
+	JournalDate *string `gorm:"index" json:"journalDate,omitempty"`
 
 	// Sharing Configuration
 	IsPublic         bool   `gorm:"default:false" json:"isPublic"`
 	PublicPermission string `gorm:"default:'read'" json:"publicPermission"`
 
+	// 归档是笔记级别的状态，对所有可见成员生效
+	Archived   bool       `gorm:"default:false;index" json:"archived"`
+	ArchivedAt *time.Time `json:"archivedAt,omitempty"`
+
+	// 当前用户的置顶/收藏状态，来自 NoteUserState，不落库
+	Pinned   bool `gorm:"-" json:"pinned"`
+	Favorite bool `gorm:"-" json:"favorite"`
+
+	// 搜索时内容匹配关键词的附件，不落库
+	MatchedAttachments []AttachmentMatch `gorm:"-" json:"matchedAttachments,omitempty"`
+
+	// 表情回应统计，按第一次回应的时间排序，不落库
+	Reactions []ReactionCount `gorm:"-" json:"reactions,omitempty"`
+
 	// Relations
 	Attachments   []Attachment   `gorm:"foreignKey:NoteID" json:"attachments"`
 	Comments      []Comment      `gorm:"foreignKey:NoteID" json:"comments"`
@@ -55,3 +77,13 @@ type Collaborator struct {
 	AvatarColor string `json:"avatarColor"`
 	Permission  string `json:"permission"`
 }
+
+// NoteUserState 笔记的个人状态（置顶、收藏）
+// 家庭共享笔记可以被一个成员置顶而其他成员不受影响
+type NoteUserState struct {
+	NoteID    string    `gorm:"primaryKey" json:"noteId"`
+	UserID    string    `gorm:"primaryKey" json:"userId"`
+	Pinned    bool      `gorm:"default:false" json:"pinned"`
+	Favorite  bool      `gorm:"default:false" json:"favorite"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
@@ -35,15 +35,69 @@ type Event struct {
 
 	// Date stored as standard UTC time.
 	Date time.Time `gorm:"not null" json:"date"`
+	// 事件的时区（IANA 时区名），创建时确定。重复和农历日期按该时区的日期和钟点计算，
+	// 与查看者的时区无关；查询时只在输出时换算为查看者的时区
+	TZID string `gorm:"column:tzid" json:"tzid"`
 
 	Type       EventType      `gorm:"type:string;default:'solar'" json:"type"`
 	Recurrence RecurrenceType `gorm:"type:string;default:'none'" json:"recurrence"`
 
+	// RFC 5545 重复规则，如 FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE。
+	// 为空时由 Recurrence 推出（yearly -> FREQ=YEARLY）；不为空时 Recurrence 为其 FREQ
+	RRule string `gorm:"column:rrule;type:text" json:"rrule"`
+	// 取消的重复，逗号分隔的 UTC 时间（RFC 5545 EXDATE），如 20260101T010000Z
+	ExDate string `gorm:"column:exdate;type:text" json:"exdate"`
+
+	// 农历事件的农历月日，Date 为对应的公历时间。每年重复时按农历月日计算每年的公历日期
+	LunarMonth int  `gorm:"default:0" json:"lunarMonth,omitempty"` // 1-12
+	LunarDay   int  `gorm:"default:0" json:"lunarDay,omitempty"`   // 1-30
+	LunarLeap  bool `gorm:"default:false" json:"lunarLeap,omitempty"`
+
 	// JSON array of User IDs to notify
 	NotifyUsers string `gorm:"type:text" json:"notifyUsers"`
 
 	ShowCountdown bool `gorm:"default:false" json:"showCountdown"`
 
+	// 提前多少分钟提醒，如 [1440, 60] 为提前 1 天和 1 小时，保存在 EventReminder
+	Reminders []int `gorm:"-" json:"reminders"`
+
 	// System events are read-only for users
 	IsSystem bool `gorm:"default:false" json:"isSystem"`
+
+	// 按时间范围查询时每次重复展开为一条，OccurrenceDate 为该次重复原本的时间，不落库
+	OccurrenceDate *time.Time `gorm:"-" json:"occurrenceDate,omitempty"`
+	Overridden     bool       `gorm:"-" json:"overridden,omitempty"` // 该次重复被单独修改过
+
+	// 内置的节气和节假日按需生成，不落库（ID 为 0），SystemKey 用于区分，如 term-2026-02、holiday-2026-10-01
+	SystemKey string `gorm:"-" json:"systemKey,omitempty"`
+	Workday   bool   `gorm:"-" json:"workday,omitempty"` // 调休上班日
+}
+
+// EventOverride 重复事件中单独修改的一次，按原本的时间对应
+type EventOverride struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	EventID        uint      `gorm:"uniqueIndex:idx_event_occurrence;not null" json:"eventId"`
+	OccurrenceDate time.Time `gorm:"uniqueIndex:idx_event_occurrence;not null" json:"occurrenceDate"` // UTC
+
+	// 为空的字段沿用事件本身
+	Title       *string    `json:"title,omitempty"`
+	Description *string    `json:"description,omitempty"`
+	Date        *time.Time `json:"date,omitempty"` // 改到的时间
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// EventReminder 事件的一个提醒：每次重复开始前 Minutes 分钟提醒 NotifyUsers。
+// NextAt 为下一次提醒的时间，发送后推进到再下一次；没有下一次时为空
+type EventReminder struct {
+	ID      uint `gorm:"primaryKey" json:"id"`
+	EventID uint `gorm:"uniqueIndex:idx_event_reminder;not null" json:"eventId"`
+	Minutes int  `gorm:"uniqueIndex:idx_event_reminder;not null" json:"minutes"`
+
+	NextAt     *time.Time `gorm:"index" json:"nextAt"`
+	LastSentAt *time.Time `json:"lastSentAt"`
+
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
 }
@@ -7,12 +7,26 @@ import (
 type Attachment struct {
 	ID        string    `gorm:"primaryKey" json:"id"`
 	NoteID    string    `gorm:"index" json:"noteId"`
+	UserID    string    `gorm:"index" json:"userId"` // 上传者
 	Name      string    `json:"name"`
-	Type      string    `json:"type"` // MIME type
-	Size      int64     `json:"size"` // Bytes
-	FilePath  string    `json:"-"`    // Local path (server side only)
-	URL       string    `json:"url"`  // Public URL /uploads/xxx
+	Type      string    `json:"type"`            // MIME type
+	Size      int64     `json:"size"`            // Bytes
+	FilePath  string    `json:"-"`               // 存储后端中的 key (server side only)
+	Hash      string    `gorm:"index" json:"-"`  // 内容 SHA-256，引用 Blob；旧数据为空
+	Width     int       `json:"width,omitempty"` // 图片宽高，非图片为 0
+	Height    int       `json:"height,omitempty"`
+	URL       string    `json:"url"` // /api/attachments/:id/download，需要登录
 	CreatedAt time.Time `json:"createdAt"`
+
+	// 从旧版 /uploads/ 文件迁移而来时的原文件名，旧链接据此找到附件
+	LegacyPath string `gorm:"index" json:"-"`
+}
+
+// AttachmentMatch 搜索时内容匹配的附件，Snippet 为匹配位置附近的文本
+type AttachmentMatch struct {
+	AttachmentID string `json:"attachmentId"`
+	Name         string `json:"name"`
+	Snippet      string `json:"snippet"`
 }
 
 type Comment struct {
@@ -23,4 +37,42 @@ type Comment struct {
 	Content    string    `json:"content"`
 	QuotedText string    `json:"quotedText"` // Selection context
 	CreatedAt  time.Time `json:"createdAt"`
+
+	// 讨论串：回复指向主题评论，主题评论为空。回复的回复也挂在同一主题下
+	ParentID *string    `gorm:"index" json:"parentId,omitempty"`
+	EditedAt *time.Time `json:"editedAt,omitempty"`
+
+	// 引用文本在笔记内容中的位置（按 Unicode 字符计数，左闭右开）和前后文，笔记修改后重新定位
+	AnchorStart  *int   `json:"anchorStart,omitempty"`
+	AnchorEnd    *int   `json:"anchorEnd,omitempty"`
+	AnchorPrefix string `json:"-"`
+	AnchorSuffix string `json:"-"`
+	Orphaned     bool   `gorm:"default:false" json:"orphaned"` // 引用的文本已从笔记中删除
+
+	// 解决状态只记录在主题评论上，对整个讨论串生效
+	Resolved   bool       `gorm:"default:false" json:"resolved"`
+	ResolvedBy string     `json:"resolvedBy,omitempty"`
+	ResolvedAt *time.Time `json:"resolvedAt,omitempty"`
+
+	// 列表接口中主题评论的回复，不落库
+	Replies []Comment `gorm:"-" json:"replies,omitempty"`
+
+	// 列表接口中的表情回应统计，不落库
+	Reactions []ReactionCount `gorm:"-" json:"reactions,omitempty"`
+}
+
+// Reaction 笔记或评论上的表情回应，同一用户对同一对象的同一表情只记一次
+type Reaction struct {
+	TargetType string    `gorm:"primaryKey" json:"targetType"` // note, comment
+	TargetID   string    `gorm:"primaryKey" json:"targetId"`
+	UserID     string    `gorm:"primaryKey" json:"userId"`
+	Emoji      string    `gorm:"primaryKey" json:"emoji"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// ReactionCount 某个表情的回应人数，Reacted 表示当前用户是否回应过
+type ReactionCount struct {
+	Emoji   string `json:"emoji"`
+	Count   int    `json:"count"`
+	Reacted bool   `json:"reacted"`
 }
@@ -10,6 +10,9 @@ type Family struct {
 	Name      string    `gorm:"not null" json:"name"`
 	CreatorID string    `gorm:"index" json:"creatorId"`
 	CreatedAt time.Time `json:"createdAt"`
+
+	// 家庭日记模板，为空时使用默认模板
+	JournalTemplate string `gorm:"type:text" json:"journalTemplate"`
 }
 
 // FamilyMember 家庭成员关联表（多对多）
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+type ImportStatus string
+
+const (
+	ImportPending ImportStatus = "pending"
+	ImportRunning ImportStatus = "running"
+	ImportDone    ImportStatus = "done"
+	ImportFailed  ImportStatus = "failed"
+)
+
+// ImportJob 后台导入任务，客户端轮询进度
+type ImportJob struct {
+	ID         string       `gorm:"primaryKey" json:"id"`
+	UserID     string       `gorm:"index" json:"userId"`
+	FamilyID   *string      `json:"familyId"`
+	Filename   string       `json:"filename"`
+	Source     string       `json:"source"` // obsidian, notion, evernote
+	Status     ImportStatus `gorm:"type:string;default:'pending'" json:"status"`
+	Total      int          `json:"total"`     // 待导入笔记数
+	Processed  int          `json:"processed"` // 已处理笔记数（含失败）
+	Imported   int          `json:"imported"`  // 成功导入笔记数
+	Error      string       `json:"error"`
+	CreatedAt  time.Time    `json:"createdAt"`
+	UpdatedAt  time.Time    `json:"updatedAt"`
+	FinishedAt *time.Time   `json:"finishedAt"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+)
+
+type NotificationType string
+
+const (
+	NotificationComment      NotificationType = "comment"      // 笔记或讨论有新评论
+	NotificationCollaborator NotificationType = "collaborator" // 被添加为笔记协作者
+	NotificationFamily       NotificationType = "family"       // 有人加入了你的家庭
+	NotificationReminder     NotificationType = "reminder"     // 事件提醒
+	NotificationMention      NotificationType = "mention"      // 在笔记或评论中被 @
+	NotificationReaction     NotificationType = "reaction"     // 笔记或评论收到表情回应
+	NotificationSystem       NotificationType = "system"
+)
+
+// Notification 发给某个用户的站内通知
+type Notification struct {
+	ID      string           `gorm:"primaryKey" json:"id"`
+	UserID  string           `gorm:"index:idx_notification_user_read" json:"userId"` // 接收者
+	Type    NotificationType `gorm:"type:string" json:"type"`
+	Title   string           `json:"title"`
+	Message string           `json:"message"`
+
+	// 触发通知的用户，系统通知为空
+	ActorID string `json:"actorId,omitempty"`
+	// 相关对象，前端据此跳转：note、event、family
+	TargetType string `json:"targetType,omitempty"`
+	TargetID   string `json:"targetId,omitempty"`
+
+	IsRead    bool       `gorm:"default:false;index:idx_notification_user_read" json:"isRead"`
+	ReadAt    *time.Time `json:"readAt,omitempty"`
+	CreatedAt time.Time  `gorm:"index" json:"createdAt"`
+}
+
+// NoteMention 笔记正文中已通知过的 @，同一用户在同一笔记中只通知一次，
+// 避免自动保存过程中反复修改 @ 时重复通知
+type NoteMention struct {
+	NoteID    string    `gorm:"primaryKey" json:"noteId"`
+	UserID    string    `gorm:"primaryKey" json:"userId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
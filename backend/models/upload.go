@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// UploadSession 可续传上传（tus 协议）的会话，未完成的数据保存在本地临时目录
+type UploadSession struct {
+	ID           string      `gorm:"primaryKey" json:"id"`
+	UserID       string      `gorm:"index" json:"userId"`
+	NoteID       string      `gorm:"index" json:"noteId"`
+	Filename     string      `json:"filename"`
+	Length       int64       `json:"length"`                 // 文件总字节数
+	Offset       int64       `json:"offset"`                 // 已接收字节数
+	AttachmentID string      `json:"attachmentId,omitempty"` // 上传完成后创建的附件
+	Attachment   *Attachment `gorm:"-" json:"attachment,omitempty"`
+	ExpiresAt    time.Time   `gorm:"index" json:"expiresAt"` // 超过该时间未完成的上传会被清理
+	CreatedAt    time.Time   `json:"createdAt"`
+	UpdatedAt    time.Time   `json:"updatedAt"`
+}
@@ -0,0 +1,157 @@
+// Package realtime 进程内的事件发布/订阅，用于向在线客户端推送变更（SSE）。
+// 每个事件发给指定的用户，最近的事件保存在有限的缓冲区中，客户端断线重连时据此补发
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// subscriberBuffer 每个连接待发送事件的上限，写满说明客户端处理不过来，断开连接让其重连补发
+const subscriberBuffer = 64
+
+// ResetEvent 缓冲区中已没有客户端错过的全部事件（或服务已重启），客户端应重新加载数据
+const ResetEvent = "reset"
+
+// Event 一条推送事件。ID 形如 "<epoch>-<seq>"，epoch 区分进程，seq 在进程内递增
+type Event struct {
+	ID   string          `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+
+	seq        uint64
+	recipients map[string]bool
+}
+
+// Subscription 一个连接的订阅，C 关闭表示订阅已被取消（或客户端过慢被断开）
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	userId string
+	closed bool
+}
+
+// Hub 事件中心，可以并发使用
+type Hub struct {
+	mu     sync.Mutex
+	epoch  string
+	seq    uint64
+	size   int
+	buffer []Event
+	subs   map[string]map[*Subscription]bool
+}
+
+// NewHub size 为缓冲区保存的最近事件数量
+func NewHub(size int) *Hub {
+	return &Hub{
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+		size:  size,
+		subs:  map[string]map[*Subscription]bool{},
+	}
+}
+
+// Publish 向 userIds 发布事件，data 序列化为 JSON。没有接收者时不产生事件
+func (h *Hub) Publish(eventType string, data interface{}, userIds ...string) {
+	recipients := map[string]bool{}
+	for _, id := range userIds {
+		if id != "" {
+			recipients[id] = true
+		}
+	}
+	if len(recipients) == 0 {
+		return
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("ERROR: realtime: failed to encode %s event: %v", eventType, err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	e := Event{
+		ID:         h.id(h.seq),
+		Type:       eventType,
+		Data:       payload,
+		seq:        h.seq,
+		recipients: recipients,
+	}
+	h.buffer = append(h.buffer, e)
+	if len(h.buffer) > h.size {
+		h.buffer = append(h.buffer[:0:0], h.buffer[len(h.buffer)-h.size:]...)
+	}
+	for userId := range recipients {
+		for sub := range h.subs[userId] {
+			select {
+			case sub.ch <- e:
+			default:
+				h.remove(sub)
+			}
+		}
+	}
+}
+
+// Subscribe 订阅 userId 的事件。lastEventId 为客户端收到的最后一个事件，不为空时返回之后需要补发的事件；
+// 无法完整补发时返回一个 ResetEvent。补发和订阅在同一把锁内完成，中间不会漏掉事件
+func (h *Hub) Subscribe(userId, lastEventId string) (*Subscription, []Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var replay []Event
+	if lastEventId != "" {
+		replay = h.since(userId, lastEventId)
+	}
+	ch := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch, userId: userId}
+	if h.subs[userId] == nil {
+		h.subs[userId] = map[*Subscription]bool{}
+	}
+	h.subs[userId][sub] = true
+	return sub, replay
+}
+
+// Unsubscribe 取消订阅，可以重复调用
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(sub)
+}
+
+func (h *Hub) since(userId, lastEventId string) []Event {
+	epoch, s, _ := strings.Cut(lastEventId, "-")
+	last, err := strconv.ParseUint(s, 10, 64)
+	// 来自之前的进程、格式不对，或比缓冲区中最早的事件还早
+	if epoch != h.epoch || err != nil || last > h.seq ||
+		(last < h.seq && (len(h.buffer) == 0 || last+1 < h.buffer[0].seq)) {
+		return []Event{{ID: h.id(h.seq), Type: ResetEvent, Data: json.RawMessage("{}")}}
+	}
+	var replay []Event
+	for _, e := range h.buffer {
+		if e.seq > last && e.recipients[userId] {
+			replay = append(replay, e)
+		}
+	}
+	return replay
+}
+
+func (h *Hub) remove(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	close(sub.ch)
+	delete(h.subs[sub.userId], sub)
+	if len(h.subs[sub.userId]) == 0 {
+		delete(h.subs, sub.userId)
+	}
+}
+
+func (h *Hub) id(seq uint64) string {
+	return fmt.Sprintf("%s-%d", h.epoch, seq)
+}
@@ -0,0 +1,58 @@
+package recur
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// dateTimeLayout RFC 5545 的 UTC 日期时间格式
+const dateTimeLayout = "20060102T150405Z"
+
+// ParseDates 解析逗号分隔的 EXDATE 列表，如 "20260101T010000Z,20260108T010000Z"，可以带 "EXDATE:" 前缀。
+// 也接受 RFC 3339 格式
+func ParseDates(s string) ([]time.Time, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "EXDATE:")
+	if s == "" {
+		return nil, nil
+	}
+	var dates []time.Time
+	for _, v := range strings.Split(s, ",") {
+		t, err := ParseDate(v)
+		if err != nil {
+			return nil, err
+		}
+		dates = append(dates, t)
+	}
+	return dates, nil
+}
+
+// ParseDate 解析单个日期时间：RFC 5545 UTC 格式或 RFC 3339
+func ParseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(dateTimeLayout, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("rrule: invalid date-time %q", s)
+}
+
+// FormatDate 格式化为 RFC 5545 UTC 格式
+func FormatDate(t time.Time) string {
+	return t.UTC().Format(dateTimeLayout)
+}
+
+// FormatDates 按时间排序并去重后格式化为逗号分隔的列表
+func FormatDates(dates []time.Time) string {
+	sorted := slices.Clone(dates)
+	slices.SortFunc(sorted, func(a, b time.Time) int { return a.Compare(b) })
+	sorted = slices.CompactFunc(sorted, func(a, b time.Time) bool { return a.Equal(b) })
+	parts := make([]string, len(sorted))
+	for i, t := range sorted {
+		parts[i] = FormatDate(t)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,93 @@
+package recur
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseDates(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []time.Time
+	}{
+		{"", nil},
+		{"20260101T010000Z", []time.Time{time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC)}},
+		{"EXDATE:20260101T010000Z, 20260108T010000Z", []time.Time{
+			time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC),
+			time.Date(2026, 1, 8, 1, 0, 0, 0, time.UTC),
+		}},
+		{"2026-01-01T09:00:00+08:00", []time.Time{time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC)}},
+	}
+	for _, tt := range tests {
+		got, err := ParseDates(tt.in)
+		if err != nil {
+			t.Errorf("ParseDates(%q): %v", tt.in, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseDates(%q) = %v, want %v", tt.in, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if !got[i].Equal(tt.want[i]) || got[i].Location() != time.UTC {
+				t.Errorf("ParseDates(%q)[%d] = %v, want %v", tt.in, i, got[i], tt.want[i])
+			}
+		}
+	}
+
+	for _, s := range []string{"20260101", "20260101T010000", "tomorrow", "20260101T010000Z,"} {
+		if _, err := ParseDates(s); err == nil {
+			t.Errorf("ParseDates(%q) succeeded, want error", s)
+		}
+	}
+}
+
+// FormatDates 排序、去重，同一时刻的不同时区表示视为相同
+func TestFormatDates(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	dates := []time.Time{
+		time.Date(2026, 1, 8, 1, 0, 0, 0, time.UTC),
+		time.Date(2026, 1, 1, 9, 0, 0, 0, shanghai),
+		time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC),
+	}
+	want := "20260101T010000Z,20260108T010000Z"
+	if got := FormatDates(dates); got != want {
+		t.Fatalf("FormatDates = %q, want %q", got, want)
+	}
+	if got := FormatDates(nil); got != "" {
+		t.Fatalf("FormatDates(nil) = %q", got)
+	}
+
+	parsed, err := ParseDates(want)
+	if err != nil || FormatDates(parsed) != want {
+		t.Fatalf("round trip: %v, %v", parsed, err)
+	}
+}
+
+// 展开时去掉 EXDATE 中的时刻，按 Unix 时间比较，与 handlers 中的用法相同
+func TestExDateExpansion(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	rule := mustParse(t, "FREQ=DAILY;COUNT=5")
+	dtstart := time.Date(2026, 3, 6, 9, 0, 0, 0, ny)
+	exdates, err := ParseDates("20260307T140000Z,20260309T130000Z,20260309T140000Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancelled := map[int64]bool{}
+	for _, d := range exdates {
+		cancelled[d.Unix()] = true
+	}
+
+	var kept []time.Time
+	for _, tm := range rule.Between(dtstart, dtstart, dtstart.AddDate(1, 0, 0)) {
+		if !cancelled[tm.Unix()] {
+			kept = append(kept, tm)
+		}
+	}
+	// 3 月 9 日已是夏令时，09:00 为 13:00Z；14:00Z 不是任何一次
+	want := []string{"2026-03-06 14:00", "2026-03-08 13:00", "2026-03-10 13:00"}
+	if got := dates(kept, time.UTC); !slices.Equal(got, want) {
+		t.Fatalf("kept %q, want %q", got, want)
+	}
+}
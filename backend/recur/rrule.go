@@ -0,0 +1,451 @@
+// Package recur 解析和展开 RFC 5545 重复规则（RRULE）。
+// 支持 FREQ（DAILY / WEEKLY / MONTHLY / YEARLY）、INTERVAL、COUNT、UNTIL、BYDAY、BYMONTHDAY、BYMONTH 和 WKST，
+// 不支持按小时以下的频率和 BYSETPOS 等较少使用的部分。
+// 展开按 dtstart 所在时区的日期和钟点计算，跨夏令时保持钟点不变
+package recur
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Freq string
+
+const (
+	Daily   Freq = "DAILY"
+	Weekly  Freq = "WEEKLY"
+	Monthly Freq = "MONTHLY"
+	Yearly  Freq = "YEARLY"
+)
+
+// WeekdayNum BYDAY 中的一项，N 为月内（或年内）第几个，负数从末尾数，0 表示每一个
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int
+}
+
+// Rule 解析后的重复规则
+type Rule struct {
+	Freq       Freq
+	Interval   int
+	Count      int       // 0 表示不限
+	Until      time.Time // 零值表示不限，包含该时刻
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	WeekStart  time.Weekday
+}
+
+// searchYears Next 向后查找的最大范围，防止永远不会出现的规则（如 2 月 30 日）无限循环
+const searchYears = 100
+
+var weekdayNames = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// Parse 解析 RRULE，可以带 "RRULE:" 前缀
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, errors.New("rrule: empty rule")
+	}
+	r := &Rule{Interval: 1, WeekStart: time.Monday}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !ok || value == "" {
+			return nil, fmt.Errorf("rrule: invalid part %q", part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("rrule: duplicate %s", name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			r.Freq = Freq(value)
+			if !slices.Contains([]Freq{Daily, Weekly, Monthly, Yearly}, r.Freq) {
+				err = fmt.Errorf("unsupported FREQ %s", value)
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err == nil && r.Interval < 1 {
+				err = errors.New("INTERVAL must be positive")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err == nil && r.Count < 1 {
+				err = errors.New("COUNT must be positive")
+			}
+		case "UNTIL":
+			r.Until, err = parseUntil(value)
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				var wd WeekdayNum
+				if wd, err = parseWeekdayNum(v); err != nil {
+					break
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			for _, v := range strings.Split(value, ",") {
+				var d int
+				d, err = strconv.Atoi(v)
+				if err != nil || d == 0 || d < -31 || d > 31 {
+					err = fmt.Errorf("invalid BYMONTHDAY %s", v)
+					break
+				}
+				r.ByMonthDay = append(r.ByMonthDay, d)
+			}
+		case "BYMONTH":
+			for _, v := range strings.Split(value, ",") {
+				var m int
+				m, err = strconv.Atoi(v)
+				if err != nil || m < 1 || m > 12 {
+					err = fmt.Errorf("invalid BYMONTH %s", v)
+					break
+				}
+				r.ByMonth = append(r.ByMonth, time.Month(m))
+			}
+		case "WKST":
+			wd, ok := weekdayNames[value]
+			if !ok {
+				err = fmt.Errorf("invalid WKST %s", value)
+			}
+			r.WeekStart = wd
+		default:
+			err = fmt.Errorf("unsupported %s", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rrule: %w", err)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, errors.New("rrule: FREQ is required")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, errors.New("rrule: COUNT and UNTIL cannot both be set")
+	}
+	for _, wd := range r.ByDay {
+		if wd.N != 0 && r.Freq != Monthly && r.Freq != Yearly {
+			return nil, errors.New("rrule: BYDAY with a number requires FREQ=MONTHLY or YEARLY")
+		}
+	}
+	if r.Freq == Weekly && len(r.ByMonthDay) > 0 {
+		return nil, errors.New("rrule: BYMONTHDAY is not allowed with FREQ=WEEKLY")
+	}
+	return r, nil
+}
+
+func parseWeekdayNum(s string) (WeekdayNum, error) {
+	if len(s) < 2 {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY %s", s)
+	}
+	wd, ok := weekdayNames[s[len(s)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY %s", s)
+	}
+	n := 0
+	if prefix := s[:len(s)-2]; prefix != "" {
+		var err error
+		n, err = strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -53 || n > 53 {
+			return WeekdayNum{}, fmt.Errorf("invalid BYDAY %s", s)
+		}
+	}
+	return WeekdayNum{Weekday: wd, N: n}, nil
+}
+
+// parseUntil 日期时间必须是 UTC（带 Z）；只有日期时包含当天（按 UTC）
+func parseUntil(s string) (time.Time, error) {
+	if t, err := time.Parse(dateTimeLayout, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("20060102", s); err == nil {
+		return t.Add(24*time.Hour - time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL %s", s)
+}
+
+// String 规范化的规则文本，不带 "RRULE:" 前缀
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(dateTimeLayout))
+	}
+	if len(r.ByMonth) > 0 {
+		var v []string
+		for _, m := range r.ByMonth {
+			v = append(v, strconv.Itoa(int(m)))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(v, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		var v []string
+		for _, d := range r.ByMonthDay {
+			v = append(v, strconv.Itoa(d))
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(v, ","))
+	}
+	if len(r.ByDay) > 0 {
+		var v []string
+		for _, wd := range r.ByDay {
+			s := weekdayCode(wd.Weekday)
+			if wd.N != 0 {
+				s = strconv.Itoa(wd.N) + s
+			}
+			v = append(v, s)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(v, ","))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayCode(r.WeekStart))
+	}
+	return strings.Join(parts, ";")
+}
+
+func weekdayCode(wd time.Weekday) string {
+	for code, d := range weekdayNames {
+		if d == wd {
+			return code
+		}
+	}
+	return ""
+}
+
+// Between 返回 [from, to) 内的重复时间，按时间排序
+func (r *Rule) Between(dtstart, from, to time.Time) []time.Time {
+	var result []time.Time
+	r.each(dtstart, to, func(t time.Time) bool {
+		if !t.Before(to) {
+			return false
+		}
+		if !t.Before(from) {
+			result = append(result, t)
+		}
+		return true
+	})
+	return result
+}
+
+// Next 返回 after 之后（不含）的第一次重复，最多向后查找 100 年
+func (r *Rule) Next(dtstart, after time.Time) (time.Time, bool) {
+	var next time.Time
+	found := false
+	r.each(dtstart, after.AddDate(searchYears, 0, 0), func(t time.Time) bool {
+		if t.After(after) {
+			next, found = t, true
+			return false
+		}
+		return true
+	})
+	return next, found
+}
+
+// each 按时间顺序产生重复时间，直到 fn 返回 false、达到 COUNT / UNTIL，或周期的开始晚于 end
+func (r *Rule) each(dtstart, end time.Time, fn func(time.Time) bool) {
+	loc := dtstart.Location()
+	hour, min, sec := dtstart.Clock()
+	y, m, d := dtstart.Date()
+	start := civil(y, m, d)
+	count := 0
+
+	for period := 0; ; period++ {
+		var first time.Time
+		var days []time.Time
+		switch r.Freq {
+		case Daily:
+			first = start.AddDate(0, 0, period*r.Interval)
+			if r.matchesDay(first) {
+				days = []time.Time{first}
+			}
+		case Weekly:
+			offset := (int(start.Weekday()) - int(r.WeekStart) + 7) % 7
+			first = start.AddDate(0, 0, -offset+7*period*r.Interval)
+			days = r.weekDays(first, start)
+		case Monthly:
+			first = civil(y, m+time.Month(period*r.Interval), 1)
+			if len(r.ByMonth) == 0 || slices.Contains(r.ByMonth, first.Month()) {
+				days = r.monthDays(first.Year(), first.Month(), d, true)
+			}
+		case Yearly:
+			first = civil(y+period*r.Interval, time.January, 1)
+			days = r.yearDays(first.Year(), m, d)
+		}
+		if first.After(end) {
+			return
+		}
+
+		for _, day := range days {
+			t := time.Date(day.Year(), day.Month(), day.Day(), hour, min, sec, 0, loc)
+			if t.Before(dtstart) {
+				continue
+			}
+			if !r.Until.IsZero() && t.After(r.Until) {
+				return
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return
+			}
+			if !fn(t) {
+				return
+			}
+		}
+	}
+}
+
+// matchesDay DAILY 时 BYxxx 作为过滤条件
+func (r *Rule) matchesDay(day time.Time) bool {
+	if len(r.ByMonth) > 0 && !slices.Contains(r.ByMonth, day.Month()) {
+		return false
+	}
+	if len(r.ByMonthDay) > 0 && !slices.Contains(r.monthDaySet(day.Year(), day.Month()), day.Day()) {
+		return false
+	}
+	if len(r.ByDay) > 0 && !slices.ContainsFunc(r.ByDay, func(wd WeekdayNum) bool { return wd.Weekday == day.Weekday() }) {
+		return false
+	}
+	return true
+}
+
+// weekDays WEEKLY：weekStart 所在周中 BYDAY 指定的日期，未指定时为 dtstart 的星期
+func (r *Rule) weekDays(weekStart, dtstart time.Time) []time.Time {
+	var days []time.Time
+	for i := 0; i < 7; i++ {
+		day := weekStart.AddDate(0, 0, i)
+		if len(r.ByDay) > 0 {
+			if !slices.ContainsFunc(r.ByDay, func(wd WeekdayNum) bool { return wd.Weekday == day.Weekday() }) {
+				continue
+			}
+		} else if day.Weekday() != dtstart.Weekday() {
+			continue
+		}
+		if len(r.ByMonth) > 0 && !slices.Contains(r.ByMonth, day.Month()) {
+			continue
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+// monthDays 某月中符合规则的日期。BYMONTHDAY 和 BYDAY 同时指定时取交集；
+// 都未指定时为 dtstart 的日，该月没有这一天（如 31 日）时跳过
+func (r *Rule) monthDays(year int, month time.Month, defaultDay int, useDefault bool) []time.Time {
+	dim := daysIn(year, month)
+	var set []int
+	switch {
+	case len(r.ByMonthDay) > 0 && len(r.ByDay) > 0:
+		byDay := r.weekdaysInRange(civil(year, month, 1), dim)
+		for _, d := range r.monthDaySet(year, month) {
+			if slices.Contains(byDay, d) {
+				set = append(set, d)
+			}
+		}
+	case len(r.ByMonthDay) > 0:
+		set = r.monthDaySet(year, month)
+	case len(r.ByDay) > 0:
+		set = r.weekdaysInRange(civil(year, month, 1), dim)
+	case useDefault && defaultDay <= dim:
+		set = []int{defaultDay}
+	}
+	slices.Sort(set)
+	set = slices.Compact(set)
+	days := make([]time.Time, len(set))
+	for i, d := range set {
+		days[i] = civil(year, month, d)
+	}
+	return days
+}
+
+// yearDays YEARLY：有 BYMONTH 时在这些月中按月内规则取日期；
+// 只有 BYDAY 时序号按全年计算（如 20MO 为当年第 20 个星期一）；只有 BYMONTHDAY 时为每个月的这些日
+func (r *Rule) yearDays(year int, startMonth time.Month, startDay int) []time.Time {
+	switch {
+	case len(r.ByMonth) > 0:
+		var days []time.Time
+		months := slices.Clone(r.ByMonth)
+		slices.Sort(months)
+		for _, m := range slices.Compact(months) {
+			days = append(days, r.monthDays(year, m, startDay, true)...)
+		}
+		return days
+	case len(r.ByDay) > 0 && len(r.ByMonthDay) == 0:
+		first := civil(year, time.January, 1)
+		n := daysIn(year, time.February) + 337
+		var days []time.Time
+		for _, offset := range r.weekdaysInRange(first, n) {
+			days = append(days, first.AddDate(0, 0, offset-1))
+		}
+		return days
+	case len(r.ByMonthDay) > 0:
+		var days []time.Time
+		for m := time.January; m <= time.December; m++ {
+			days = append(days, r.monthDays(year, m, startDay, false)...)
+		}
+		return days
+	default:
+		return r.monthDays(year, startMonth, startDay, true)
+	}
+}
+
+// weekdaysInRange 从 first 开始的 n 天中符合 BYDAY 的日期（1 起的序号）
+func (r *Rule) weekdaysInRange(first time.Time, n int) []int {
+	var result []int
+	for _, wd := range r.ByDay {
+		offset := (int(wd.Weekday) - int(first.Weekday()) + 7) % 7
+		var matches []int
+		for d := offset + 1; d <= n; d += 7 {
+			matches = append(matches, d)
+		}
+		switch {
+		case wd.N == 0:
+			result = append(result, matches...)
+		case wd.N > 0 && wd.N <= len(matches):
+			result = append(result, matches[wd.N-1])
+		case wd.N < 0 && -wd.N <= len(matches):
+			result = append(result, matches[len(matches)+wd.N])
+		}
+	}
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+// monthDaySet BYMONTHDAY 换算为该月的日，负数从月末数，超出该月天数的忽略
+func (r *Rule) monthDaySet(year int, month time.Month) []int {
+	dim := daysIn(year, month)
+	var set []int
+	for _, d := range r.ByMonthDay {
+		if d < 0 {
+			d = dim + 1 + d
+		}
+		if d >= 1 && d <= dim {
+			set = append(set, d)
+		}
+	}
+	return set
+}
+
+// civil 只表示日期，用 UTC 计算避免时区影响加减
+func civil(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func daysIn(year int, month time.Month) int {
+	return civil(year, month+1, 0).Day()
+}
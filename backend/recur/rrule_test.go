@@ -0,0 +1,304 @@
+package recur
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s not available: %v", name, err)
+	}
+	return loc
+}
+
+// dates 按 loc 的 "2006-01-02 15:04" 格式化，便于比较
+func dates(times []time.Time, loc *time.Location) []string {
+	result := make([]string, len(times))
+	for i, t := range times {
+		result[i] = t.In(loc).Format("2006-01-02 15:04")
+	}
+	return result
+}
+
+func TestBetween(t *testing.T) {
+	utc := time.UTC
+	tests := []struct {
+		name     string
+		rule     string
+		dtstart  time.Time
+		from, to time.Time
+		want     []string
+	}{
+		{
+			name:    "daily",
+			rule:    "FREQ=DAILY",
+			dtstart: time.Date(2026, 1, 1, 9, 0, 0, 0, utc),
+			from:    time.Date(2026, 1, 1, 0, 0, 0, 0, utc),
+			to:      time.Date(2026, 1, 4, 0, 0, 0, 0, utc),
+			want:    []string{"2026-01-01 09:00", "2026-01-02 09:00", "2026-01-03 09:00"},
+		},
+		{
+			name:    "from 晚于 dtstart",
+			rule:    "FREQ=DAILY;INTERVAL=3",
+			dtstart: time.Date(2026, 1, 1, 9, 0, 0, 0, utc),
+			from:    time.Date(2026, 1, 5, 0, 0, 0, 0, utc),
+			to:      time.Date(2026, 1, 14, 0, 0, 0, 0, utc),
+			want:    []string{"2026-01-07 09:00", "2026-01-10 09:00", "2026-01-13 09:00"},
+		},
+		{
+			name:    "to 不含",
+			rule:    "FREQ=DAILY",
+			dtstart: time.Date(2026, 1, 1, 9, 0, 0, 0, utc),
+			from:    time.Date(2026, 1, 1, 9, 0, 0, 0, utc),
+			to:      time.Date(2026, 1, 2, 9, 0, 0, 0, utc),
+			want:    []string{"2026-01-01 09:00"},
+		},
+		{
+			name:    "weekly interval",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE",
+			dtstart: time.Date(2026, 1, 5, 8, 30, 0, 0, utc), // 周一
+			from:    time.Date(2026, 1, 1, 0, 0, 0, 0, utc),
+			to:      time.Date(2026, 2, 1, 0, 0, 0, 0, utc),
+			want:    []string{"2026-01-05 08:30", "2026-01-07 08:30", "2026-01-19 08:30", "2026-01-21 08:30"},
+		},
+		{
+			name:    "weekly 从周中开始",
+			rule:    "FREQ=WEEKLY;BYDAY=MO,FR",
+			dtstart: time.Date(2026, 1, 7, 10, 0, 0, 0, utc), // 周三
+			from:    time.Date(2026, 1, 1, 0, 0, 0, 0, utc),
+			to:      time.Date(2026, 1, 17, 0, 0, 0, 0, utc),
+			want:    []string{"2026-01-09 10:00", "2026-01-12 10:00", "2026-01-16 10:00"},
+		},
+		{
+			name:    "每月第二个周日",
+			rule:    "FREQ=MONTHLY;BYDAY=2SU",
+			dtstart: time.Date(2026, 1, 1, 10, 0, 0, 0, utc),
+			from:    time.Date(2026, 1, 1, 0, 0, 0, 0, utc),
+			to:      time.Date(2026, 5, 1, 0, 0, 0, 0, utc),
+			want:    []string{"2026-01-11 10:00", "2026-02-08 10:00", "2026-03-08 10:00", "2026-04-12 10:00"},
+		},
+		{
+			name:    "每月最后一个周五",
+			rule:    "FREQ=MONTHLY;BYDAY=-1FR",
+			dtstart: time.Date(2026, 1, 1, 18, 0, 0, 0, utc),
+			from:    time.Date(2026, 1, 1, 0, 0, 0, 0, utc),
+			to:      time.Date(2026, 4, 1, 0, 0, 0, 0, utc),
+			want:    []string{"2026-01-30 18:00", "2026-02-27 18:00", "2026-03-27 18:00"},
+		},
+		{
+			name:    "每年 5 月第二个周日",
+			rule:    "FREQ=YEARLY;BYMONTH=5;BYDAY=2SU",
+			dtstart: time.Date(2026, 1, 1, 9, 0, 0, 0, utc),
+			from:    time.Date(2026, 1, 1, 0, 0, 0, 0, utc),
+			to:      time.Date(2029, 1, 1, 0, 0, 0, 0, utc),
+			want:    []string{"2026-05-10 09:00", "2027-05-09 09:00", "2028-05-14 09:00"},
+		},
+		{
+			name:    "每月最后一天",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=-1",
+			dtstart: time.Date(2027, 12, 1, 12, 0, 0, 0, utc),
+			from:    time.Date(2027, 12, 1, 0, 0, 0, 0, utc),
+			to:      time.Date(2028, 5, 1, 0, 0, 0, 0, utc),
+			want:    []string{"2027-12-31 12:00", "2028-01-31 12:00", "2028-02-29 12:00", "2028-03-31 12:00", "2028-04-30 12:00"},
+		},
+		{
+			name:    "每月 31 日在小月跳过",
+			rule:    "FREQ=MONTHLY",
+			dtstart: time.Date(2026, 1, 31, 9, 0, 0, 0, utc),
+			from:    time.Date(2026, 1, 1, 0, 0, 0, 0, utc),
+			to:      time.Date(2026, 6, 1, 0, 0, 0, 0, utc),
+			want:    []string{"2026-01-31 09:00", "2026-03-31 09:00", "2026-05-31 09:00"},
+		},
+		{
+			name:    "每年 2 月 29 日",
+			rule:    "FREQ=YEARLY",
+			dtstart: time.Date(2024, 2, 29, 9, 0, 0, 0, utc),
+			from:    time.Date(2024, 1, 1, 0, 0, 0, 0, utc),
+			to:      time.Date(2033, 1, 1, 0, 0, 0, 0, utc),
+			want:    []string{"2024-02-29 09:00", "2028-02-29 09:00", "2032-02-29 09:00"},
+		},
+		{
+			name:    "count",
+			rule:    "FREQ=WEEKLY;COUNT=3",
+			dtstart: time.Date(2026, 1, 5, 9, 0, 0, 0, utc),
+			from:    time.Date(2026, 1, 1, 0, 0, 0, 0, utc),
+			to:      time.Date(2027, 1, 1, 0, 0, 0, 0, utc),
+			want:    []string{"2026-01-05 09:00", "2026-01-12 09:00", "2026-01-19 09:00"},
+		},
+		{
+			name:    "count 从 dtstart 数起，不受 from 影响",
+			rule:    "FREQ=DAILY;COUNT=5",
+			dtstart: time.Date(2026, 1, 1, 9, 0, 0, 0, utc),
+			from:    time.Date(2026, 1, 4, 0, 0, 0, 0, utc),
+			to:      time.Date(2027, 1, 1, 0, 0, 0, 0, utc),
+			want:    []string{"2026-01-04 09:00", "2026-01-05 09:00"},
+		},
+		{
+			name:    "until 包含该时刻",
+			rule:    "FREQ=DAILY;UNTIL=20260103T090000Z",
+			dtstart: time.Date(2026, 1, 1, 9, 0, 0, 0, utc),
+			from:    time.Date(2026, 1, 1, 0, 0, 0, 0, utc),
+			to:      time.Date(2027, 1, 1, 0, 0, 0, 0, utc),
+			want:    []string{"2026-01-01 09:00", "2026-01-02 09:00", "2026-01-03 09:00"},
+		},
+		{
+			name:    "until 只有日期时包含当天",
+			rule:    "FREQ=DAILY;UNTIL=20260102",
+			dtstart: time.Date(2026, 1, 1, 23, 0, 0, 0, utc),
+			from:    time.Date(2026, 1, 1, 0, 0, 0, 0, utc),
+			to:      time.Date(2027, 1, 1, 0, 0, 0, 0, utc),
+			want:    []string{"2026-01-01 23:00", "2026-01-02 23:00"},
+		},
+		{
+			name:    "daily 按 BYMONTH 过滤",
+			rule:    "FREQ=DAILY;INTERVAL=10;BYMONTH=2",
+			dtstart: time.Date(2026, 1, 1, 9, 0, 0, 0, utc),
+			from:    time.Date(2026, 1, 1, 0, 0, 0, 0, utc),
+			to:      time.Date(2026, 4, 1, 0, 0, 0, 0, utc),
+			want:    []string{"2026-02-10 09:00", "2026-02-20 09:00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := dates(rule.Between(tt.dtstart, tt.from, tt.to), utc)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Between\n got  %q\n want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// 跨夏令时按 dtstart 时区的钟点展开，UTC 时间随之变化
+func TestBetweenDST(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	berlin := mustLoad(t, "Europe/Berlin")
+	tests := []struct {
+		name     string
+		rule     string
+		dtstart  time.Time
+		from, to time.Time
+		want     []string // UTC
+	}{
+		{
+			name:    "纽约开始夏令时",
+			rule:    "FREQ=DAILY",
+			dtstart: time.Date(2026, 3, 7, 9, 0, 0, 0, ny),
+			from:    time.Date(2026, 3, 7, 0, 0, 0, 0, ny),
+			to:      time.Date(2026, 3, 10, 0, 0, 0, 0, ny),
+			want:    []string{"2026-03-07 14:00", "2026-03-08 13:00", "2026-03-09 13:00"},
+		},
+		{
+			name:    "纽约结束夏令时",
+			rule:    "FREQ=WEEKLY;BYDAY=SA,SU",
+			dtstart: time.Date(2026, 10, 31, 9, 0, 0, 0, ny),
+			from:    time.Date(2026, 10, 31, 0, 0, 0, 0, ny),
+			to:      time.Date(2026, 11, 2, 0, 0, 0, 0, ny),
+			want:    []string{"2026-10-31 13:00", "2026-11-01 14:00"},
+		},
+		{
+			name:    "柏林每月最后一个周日",
+			rule:    "FREQ=MONTHLY;BYDAY=-1SU",
+			dtstart: time.Date(2026, 2, 1, 12, 0, 0, 0, berlin),
+			from:    time.Date(2026, 2, 1, 0, 0, 0, 0, berlin),
+			to:      time.Date(2026, 4, 1, 0, 0, 0, 0, berlin),
+			want:    []string{"2026-02-22 11:00", "2026-03-29 10:00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			times := rule.Between(tt.dtstart, tt.from, tt.to)
+			if got := dates(times, time.UTC); !slices.Equal(got, tt.want) {
+				t.Fatalf("Between\n got  %q\n want %q", got, tt.want)
+			}
+			for _, tm := range times {
+				if tm.In(tt.dtstart.Location()).Hour() != tt.dtstart.Hour() {
+					t.Fatalf("%v: local hour changed from %d", tm, tt.dtstart.Hour())
+				}
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	dtstart := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		rule   string
+		after  time.Time
+		want   time.Time
+		wantOK bool
+	}{
+		{"FREQ=DAILY", dtstart, dtstart.AddDate(0, 0, 1), true},
+		{"FREQ=DAILY", dtstart.Add(-time.Second), dtstart, true},
+		{"FREQ=MONTHLY;BYMONTHDAY=15", dtstart, time.Date(2026, 1, 15, 9, 0, 0, 0, time.UTC), true},
+		{"FREQ=DAILY;COUNT=2", dtstart.AddDate(0, 0, 1), time.Time{}, false},
+		{"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", dtstart, time.Time{}, false}, // 永远不会出现
+	}
+	for _, tt := range tests {
+		got, ok := mustParse(t, tt.rule).Next(dtstart, tt.after)
+		if ok != tt.wantOK || !got.Equal(tt.want) {
+			t.Errorf("%s Next(%v) = %v, %v; want %v, %v", tt.rule, tt.after, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func mustParse(t *testing.T, s string) *Rule {
+	t.Helper()
+	rule, err := Parse(s)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", s, err)
+	}
+	return rule
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"RRULE:FREQ=WEEKLY;BYDAY=MO,WE", "FREQ=WEEKLY;BYDAY=MO,WE"},
+		{"freq=monthly;byday=-1fr;interval=1", "FREQ=MONTHLY;BYDAY=-1FR"},
+		{"FREQ=YEARLY;BYMONTH=5;BYDAY=2SU;WKST=SU", "FREQ=YEARLY;BYMONTH=5;BYDAY=2SU;WKST=SU"},
+		{"FREQ=DAILY;UNTIL=20261231", "FREQ=DAILY;UNTIL=20261231T235959Z"},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,-1;COUNT=10", "FREQ=MONTHLY;COUNT=10;BYMONTHDAY=1,-1"},
+	}
+	for _, tt := range tests {
+		rule, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got := rule.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	for _, s := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;COUNT=3;UNTIL=20261231",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=WEEKLY;BYDAY=2MO",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=YEARLY;BYMONTH=13",
+		"FREQ=DAILY;UNTIL=2026-12-31",
+		"FREQ=DAILY;FREQ=WEEKLY",
+	} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", s)
+		}
+	}
+}
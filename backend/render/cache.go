@@ -0,0 +1,73 @@
+package render
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// Cache 按 (笔记 ID, 版本, 能被提及的用户名) 缓存渲染结果，超出容量时淘汰最早写入的条目
+type Cache struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]string
+	order   []string
+}
+
+// NewCache 创建最多保存 max 条结果的缓存
+func NewCache(max int) *Cache {
+	return &Cache{max: max, entries: make(map[string]string)}
+}
+
+func cacheKey(id string, version int64, usernames []string) string {
+	return fmt.Sprintf("%s@%d#%08x", id, version, UsernamesHash(usernames))
+}
+
+// UsernamesHash 用户名列表的摘要。家庭成员、协作者变化后提及的渲染结果也会变化，
+// 用于缓存键和 ETag
+func UsernamesHash(usernames []string) uint32 {
+	h := fnv.New32a()
+	for _, name := range usernames {
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+	}
+	return h.Sum32()
+}
+
+// Get 读取缓存的 HTML
+func (c *Cache) Get(id string, version int64, usernames []string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	html, ok := c.entries[cacheKey(id, version, usernames)]
+	return html, ok
+}
+
+// Put 写入渲染结果
+func (c *Cache) Put(id string, version int64, usernames []string, html string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := cacheKey(id, version, usernames)
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	for len(c.order) >= c.max && len(c.order) > 0 {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.entries[key] = html
+	c.order = append(c.order, key)
+}
+
+// Markdown 渲染并缓存，版本号或用户名变化后自然失效
+func (c *Cache) Markdown(id string, version int64, source string, usernames []string) (string, error) {
+	if html, ok := c.Get(id, version, usernames); ok {
+		return html, nil
+	}
+	html, err := Markdown(source, usernames)
+	if err != nil {
+		return "", err
+	}
+	c.Put(id, version, usernames, html)
+	return html, nil
+}
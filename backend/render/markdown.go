@@ -0,0 +1,63 @@
+// Package render 将笔记的 Markdown 内容渲染为安全的 HTML
+package render
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+var markdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM, // 表格、任务列表、删除线、自动链接
+		highlighting.NewHighlighting(
+			highlighting.WithStyle("github"),
+			highlighting.WithFormatOptions(html.WithClasses(false)), // 内联样式，导出的 HTML 不依赖外部 CSS
+		),
+		&mentionExtension{},
+	),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	// Editor 用原始 HTML 实现文字颜色和高亮，这里先保留，再交给 sanitizer 过滤
+	goldmark.WithRendererOptions(gmhtml.WithUnsafe()),
+)
+
+// colorValue 颜色值：#hex、颜色名或 rgb()/rgba()
+var colorValue = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+|rgba?\(\s*[\d.]+%?\s*(,\s*[\d.]+%?\s*){2,3}\))$`)
+
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+
+	// Editor 产生的 <span style="color: ..."> 和 <span style="background:...">
+	p.AllowStyles("color", "background", "background-color").Matching(colorValue).OnElements("span", "pre", "code")
+	// 代码高亮的内联样式
+	p.AllowStyles("font-weight", "font-style", "text-decoration").OnElements("span")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[\w\- ]+$`)).OnElements("span", "code", "pre", "div")
+	p.AllowAttrs("data-username").Matching(regexp.MustCompile(`^[^\x00-\x1f<>"']+$`)).OnElements("span")
+
+	// GFM 任务列表
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+
+	return p
+}
+
+// Markdown 渲染 Markdown 并过滤不安全的 HTML。usernames 为能被 @ 提及的用户名
+// （按 SortUsernames 排列），不在其中的 @ 按普通文字输出
+func Markdown(source string, usernames []string) (string, error) {
+	var buf bytes.Buffer
+	pc := parser.NewContext()
+	pc.Set(usernamesKey, usernames)
+	if err := markdown.Convert([]byte(source), &buf, parser.WithContext(pc)); err != nil {
+		return "", err
+	}
+	return policy.Sanitize(buf.String()), nil
+}
@@ -0,0 +1,110 @@
+package render
+
+import (
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindMention @用户名 节点类型
+var KindMention = ast.NewNodeKind("Mention")
+
+// Mention 表示正文中的 @用户名
+type Mention struct {
+	ast.BaseInline
+	Username string
+}
+
+func (n *Mention) Kind() ast.NodeKind { return KindMention }
+
+func (n *Mention) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Username": n.Username}, nil)
+}
+
+// MentionBoundary @ 前面的字符 r 是否允许出现提及：英文字母、数字和 "_.@" 之后不算（排除邮箱地址 a@b.com），
+// 中文没有空格分隔，"请@张三" 可以
+func MentionBoundary(r rune) bool {
+	if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+		return true
+	}
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '.' && r != '@'
+}
+
+// MatchMention text 为 @ 之后的文字，返回开头匹配的最长用户名，没有时返回空字符串。
+// usernames 须按长度从长到短排列（SortUsernames）。中文用户名之后可以直接接文字，
+// 以英文字母数字结尾的用户名之后不能紧跟英文字母数字（用户名 bob 不匹配 "@bobby"）
+func MatchMention(text string, usernames []string) string {
+	for _, name := range usernames {
+		if name == "" || !strings.HasPrefix(text, name) {
+			continue
+		}
+		last, _ := utf8.DecodeLastRuneInString(name)
+		next, _ := utf8.DecodeRuneInString(text[len(name):])
+		if isWordRune(last) && isWordRune(next) {
+			continue
+		}
+		return name
+	}
+	return ""
+}
+
+// SortUsernames 按长度从长到短排列，供 MatchMention 使用
+func SortUsernames(usernames []string) {
+	slices.SortFunc(usernames, func(a, b string) int { return len(b) - len(a) })
+}
+
+func isWordRune(r rune) bool {
+	return r < utf8.RuneSelf && (r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// usernamesKey 渲染时能被提及的用户名，通过 parser.Context 传入
+var usernamesKey = parser.NewContextKey()
+
+type mentionParser struct{}
+
+func (p *mentionParser) Trigger() []byte { return []byte{'@'} }
+
+func (p *mentionParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	if !MentionBoundary(block.PrecendingCharacter()) {
+		return nil
+	}
+	usernames, _ := pc.Get(usernamesKey).([]string)
+	line, _ := block.PeekLine()
+	name := MatchMention(string(line[1:]), usernames)
+	if name == "" {
+		return nil
+	}
+	block.Advance(1 + len(name))
+	return &Mention{Username: name}
+}
+
+type mentionRenderer struct{}
+
+func (r *mentionRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindMention, r.render)
+}
+
+func (r *mentionRenderer) render(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		name := html.EscapeString(n.(*Mention).Username)
+		fmt.Fprintf(w, `<span class="mention" data-username="%s">@%s</span>`, name, name)
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+type mentionExtension struct{}
+
+func (e *mentionExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(&mentionParser{}, 999)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(&mentionRenderer{}, 500)))
+}
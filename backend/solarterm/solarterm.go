@@ -0,0 +1,137 @@
+// Package solarterm 按太阳视黄经计算二十四节气的时刻，适用于任意年份。
+// 太阳位置使用截断的 VSOP87 级数，加上章动和光行差修正，节气时刻误差在一分钟左右；
+// 1900 年以前和 2150 年以后 ΔT 只能估计，误差会变大
+package solarterm
+
+import (
+	"math"
+	"time"
+)
+
+// Names 二十四节气，按在公历年中的顺序，从小寒（太阳视黄经 285°）开始
+var Names = [24]string{
+	"小寒", "大寒", "立春", "雨水", "惊蛰", "春分",
+	"清明", "谷雨", "立夏", "小满", "芒种", "夏至",
+	"小暑", "大暑", "立秋", "处暑", "白露", "秋分",
+	"寒露", "霜降", "立冬", "小雪", "大雪", "冬至",
+}
+
+// Term 一个节气
+type Term struct {
+	Index int       // Names 中的序号
+	Name  string    // 名称
+	Time  time.Time // 交节时刻（UTC）
+}
+
+// Longitude 该节气的太阳视黄经（度）
+func (t Term) Longitude() float64 {
+	return math.Mod(285+15*float64(t.Index), 360)
+}
+
+// Year 该公历年的二十四节气，按时间排序
+func Year(year int) []Term {
+	terms := make([]Term, 24)
+	for i := range terms {
+		terms[i] = Term{Index: i, Name: Names[i]}
+		terms[i].Time = termTime(year, i)
+	}
+	return terms
+}
+
+// Between 返回 [from, to) 内的节气
+func Between(from, to time.Time) []Term {
+	var result []Term
+	for year := from.UTC().Year(); year <= to.UTC().Year(); year++ {
+		for _, t := range Year(year) {
+			if !t.Time.Before(from) && t.Time.Before(to) {
+				result = append(result, t)
+			}
+		}
+	}
+	return result
+}
+
+// termTime year 年第 index 个节气的时刻（UTC）
+func termTime(year, index int) time.Time {
+	// 初始估计：小寒约在 1 月 6 日，之后每个节气约 15.22 天
+	jde := julianDay(time.Date(year, time.January, 6, 0, 0, 0, 0, time.UTC)) + float64(index)*365.2422/24
+	longitude := math.Mod(285+15*float64(index), 360)
+
+	// 太阳每天约走 360/365.2422 度，迭代修正
+	for i := 0; i < 10; i++ {
+		diff := math.Mod(longitude-apparentLongitude(jde)+540, 360) - 180
+		jde += diff / 360 * 365.2422
+		if math.Abs(diff) < 1e-7 {
+			break
+		}
+	}
+	return fromJulianDay(jde - deltaT(year)/86400)
+}
+
+// apparentLongitude 太阳视黄经（度），jde 为力学时儒略日
+func apparentLongitude(jde float64) float64 {
+	tau := (jde - j2000) / 365250
+	t := tau * 10
+
+	// 太阳地心黄经 = 地球日心黄经 + 180°，加上到 FK5 的修正
+	l := earthLongitude(tau)*180/math.Pi + 180
+	l += -0.09033 / 3600
+
+	// 章动（主要项）
+	omega := deg2rad(125.04452 - 1934.136261*t)
+	sunL := deg2rad(280.4665 + 36000.7698*t)
+	moonL := deg2rad(218.3165 + 481267.8813*t)
+	nutation := -17.20*math.Sin(omega) - 1.32*math.Sin(2*sunL) - 0.23*math.Sin(2*moonL) + 0.21*math.Sin(2*omega)
+	l += nutation / 3600
+
+	// 光行差
+	l += -20.4898 / 3600
+
+	return math.Mod(math.Mod(l, 360)+360, 360)
+}
+
+const j2000 = 2451545.0
+
+func deg2rad(d float64) float64 { return d * math.Pi / 180 }
+
+const unixEpochJD = 2440587.5
+
+func julianDay(t time.Time) float64 {
+	return unixEpochJD + float64(t.UnixNano())/86400e9
+}
+
+func fromJulianDay(jd float64) time.Time {
+	ns := (jd - unixEpochJD) * 86400e9
+	return time.Unix(0, int64(math.Round(ns/1e9))*1e9).UTC()
+}
+
+// deltaT 力学时与世界时之差（秒），Espenak 和 Meeus 的多项式近似
+func deltaT(year int) float64 {
+	y := float64(year) + 0.5
+	switch {
+	case y >= 1900 && y < 1920:
+		t := y - 1900
+		return -2.79 + 1.494119*t - 0.0598939*t*t + 0.0061966*t*t*t - 0.000197*t*t*t*t
+	case y >= 1920 && y < 1941:
+		t := y - 1920
+		return 21.20 + 0.84493*t - 0.076100*t*t + 0.0020936*t*t*t
+	case y >= 1941 && y < 1961:
+		t := y - 1950
+		return 29.07 + 0.407*t - t*t/233 + t*t*t/2547
+	case y >= 1961 && y < 1986:
+		t := y - 1975
+		return 45.45 + 1.067*t - t*t/260 - t*t*t/718
+	case y >= 1986 && y < 2005:
+		t := y - 2000
+		return 63.86 + 0.3345*t - 0.060374*t*t + 0.0017275*t*t*t + 0.000651814*t*t*t*t + 0.00002373599*t*t*t*t*t
+	case y >= 2005 && y < 2050:
+		t := y - 2000
+		return 62.92 + 0.32217*t + 0.005589*t*t
+	case y >= 2050 && y < 2150:
+		u := (y - 1820) / 100
+		return -20 + 32*u*u - 0.5628*(2150-y)
+	default:
+		u := (y - 1820) / 100
+		return -20 + 32*u*u
+	}
+}
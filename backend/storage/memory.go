@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory 内存存储，用于测试和本地调试，进程退出后数据丢失
+type Memory struct {
+	mu      sync.RWMutex
+	objects map[string]memoryBlob
+}
+
+type memoryBlob struct {
+	data    []byte
+	modTime time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{objects: map[string]memoryBlob{}}
+}
+
+func (m *Memory) Put(ctx context.Context, key string, r io.Reader, contentType string) (int64, error) {
+	if err := validKey(key); err != nil {
+		return 0, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	m.objects[key] = memoryBlob{data: data, modTime: time.Now()}
+	m.mu.Unlock()
+	return int64(len(data)), nil
+}
+
+func (m *Memory) Open(ctx context.Context, key string) (Object, error) {
+	m.mu.RLock()
+	b, ok := m.objects[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &memoryObject{Reader: bytes.NewReader(b.data), blob: b}, nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	delete(m.objects, key)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) Walk(ctx context.Context, fn func(key string, size int64) error) error {
+	m.mu.RLock()
+	keys := make([]string, 0, len(m.objects))
+	sizes := make(map[string]int64, len(m.objects))
+	for k, b := range m.objects {
+		keys = append(keys, k)
+		sizes[k] = int64(len(b.data))
+	}
+	m.mu.RUnlock()
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := fn(k, sizes[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type memoryObject struct {
+	*bytes.Reader
+	blob memoryBlob
+}
+
+func (o *memoryObject) Close() error       { return nil }
+func (o *memoryObject) Size() int64        { return int64(len(o.blob.data)) }
+func (o *memoryObject) ModTime() time.Time { return o.blob.modTime }
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+
+	before := time.Now()
+	n, err := m.Put(ctx, "b/2", strings.NewReader("hello"), "text/plain")
+	if err != nil || n != 5 {
+		t.Fatalf("Put: %d, %v", n, err)
+	}
+	if _, err := m.Put(ctx, "a/1", strings.NewReader("x"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Put(ctx, "../escape", strings.NewReader("x"), ""); err == nil {
+		t.Fatal("Put accepted invalid key")
+	}
+
+	obj, err := m.Open(ctx, "b/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Size() != 5 || obj.ModTime().Before(before) {
+		t.Fatalf("Size %d ModTime %v", obj.Size(), obj.ModTime())
+	}
+	if _, err := obj.Seek(2, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	rest, err := io.ReadAll(obj)
+	if err != nil || string(rest) != "llo" {
+		t.Fatalf("read %q, %v", rest, err)
+	}
+	obj.Close()
+
+	// 覆盖写入不影响已打开的对象
+	obj, _ = m.Open(ctx, "b/2")
+	if _, err := m.Put(ctx, "b/2", strings.NewReader("replaced"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(obj); string(data) != "hello" {
+		t.Fatalf("open object changed to %q", data)
+	}
+
+	var keys []string
+	var sizes []int64
+	err = m.Walk(ctx, func(key string, size int64) error {
+		keys = append(keys, key)
+		sizes = append(sizes, size)
+		return nil
+	})
+	if err != nil || !slices.Equal(keys, []string{"a/1", "b/2"}) || !slices.Equal(sizes, []int64{1, 8}) {
+		t.Fatalf("Walk %q %v, %v", keys, sizes, err)
+	}
+	stop := errors.New("stop")
+	if err := m.Walk(ctx, func(string, int64) error { return stop }); err != stop {
+		t.Fatalf("Walk returned %v", err)
+	}
+
+	if err := m.Delete(ctx, "b/2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Delete(ctx, "b/2"); err != nil {
+		t.Fatalf("Delete missing: %v", err)
+	}
+	if _, err := m.Open(ctx, "b/2"); err != ErrNotFound {
+		t.Fatalf("Open deleted: %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("blob not found")
+
+// Object 打开的对象，支持 Seek 以便处理 HTTP Range 请求
+type Object interface {
+	io.ReadSeekCloser
+	Size() int64
+	ModTime() time.Time
+}
+
+// BlobStore 附件文件的存储后端。key 为不含前导 "/" 的相对路径
+type BlobStore interface {
+	// Put 写入对象，已存在时覆盖，返回写入的字节数
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (int64, error)
+	// Open 打开对象，不存在时返回 ErrNotFound
+	Open(ctx context.Context, key string) (Object, error)
+	// Delete 删除对象，不存在时不报错
+	Delete(ctx context.Context, key string) error
+	// Walk 遍历所有对象
+	Walk(ctx context.Context, fn func(key string, size int64) error) error
+}
+
+// Blobs 当前使用的存储后端，由 Init 设置
+var Blobs BlobStore
+
+// Config 存储后端配置
+type Config struct {
+	Driver string // local | s3 | memory
+
+	Dir string // local: 根目录
+
+	Endpoint  string // s3: 例如 https://s3.amazonaws.com 或 http://localhost:9000
+	Region    string
+	Bucket    string
+	Prefix    string // s3: 对象 key 前缀，可为空
+	AccessKey string
+	SecretKey string
+}
+
+// ConfigFromEnv 从 GONOTE_STORAGE、GONOTE_UPLOAD_DIR、GONOTE_S3_* 环境变量读取配置
+func ConfigFromEnv() Config {
+	return Config{
+		Driver:    getenv("GONOTE_STORAGE", "local"),
+		Dir:       getenv("GONOTE_UPLOAD_DIR", "./uploads"),
+		Endpoint:  os.Getenv("GONOTE_S3_ENDPOINT"),
+		Region:    getenv("GONOTE_S3_REGION", "us-east-1"),
+		Bucket:    os.Getenv("GONOTE_S3_BUCKET"),
+		Prefix:    os.Getenv("GONOTE_S3_PREFIX"),
+		AccessKey: os.Getenv("GONOTE_S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("GONOTE_S3_SECRET_KEY"),
+	}
+}
+
+func getenv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// Open 按配置创建存储后端
+func Open(cfg Config) (BlobStore, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return NewLocal(cfg.Dir)
+	case "s3":
+		return NewS3(cfg)
+	case "memory":
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
+
+// Init 按环境变量初始化 Blobs
+func Init() {
+	cfg := ConfigFromEnv()
+	store, err := Open(cfg)
+	if err != nil {
+		log.Fatal("Failed to open blob storage:", err)
+	}
+	Blobs = store
+	log.Printf("Blob storage: %s", cfg.Driver)
+}
+
+// validKey 拒绝绝对路径和包含 ".." 的 key，避免越出存储目录
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid blob key %q", key)
+		}
+	}
+	return nil
+}
+
+// Copy 将 src 中的对象复制到 dst
+func Copy(ctx context.Context, dst, src BlobStore, key, contentType string) (int64, error) {
+	obj, err := src.Open(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	defer obj.Close()
+	return dst.Put(ctx, key, obj, contentType)
+}
@@ -1,4 +1,4 @@
-import React, { useState, useEffect, useCallback } from 'react';
+import React, { useState, useEffect, useCallback, useRef } from 'react';
 import { Plus, Search, LogOut, ChevronRight, FileText, Settings, Menu, X, MoreHorizontal, Layout, Hash, Home, Calendar as CalendarIcon, Bell } from 'lucide-react';
 import { Note, Folder as FolderType, User, CalendarEvent, AppNotification } from './types';
 import Editor from './components/Editor';
@@ -106,6 +106,15 @@ Reference from [[Welcome to GoNote]]
   updatedAt: new Date().toISOString()
 };
 
+// 事件提醒的可选项，值为提前的分钟数
+const REMINDER_OPTIONS = [
+  { minutes: 0, label: '准时' },
+  { minutes: 10, label: '提前 10 分钟' },
+  { minutes: 60, label: '提前 1 小时' },
+  { minutes: 1440, label: '提前 1 天' },
+  { minutes: 10080, label: '提前 1 周' },
+];
+
 const App: React.FC = () => {
   const [user, setUser] = useState<User | null>(null);
   const [loading, setLoading] = useState(true);
@@ -137,9 +146,9 @@ const App: React.FC = () => {
   const [newEventTime, setNewEventTime] = useState('09:00');
   const [newEventRecurrence, setNewEventRecurrence] = useState<'none' | 'daily' | 'weekly' | 'monthly' | 'yearly'>('none');
   const [newEventShowCountdown, setNewEventShowCountdown] = useState(true);
-  const [notifications, setNotifications] = useState<AppNotification[]>([
-    { id: 'n1', userId: 'u1', title: 'Dad\'s Birthday', message: 'Coming up in 3 days!', isRead: false, createdAt: new Date().toISOString(), type: 'reminder' }
-  ]);
+  const [newEventReminders, setNewEventReminders] = useState<number[]>([]); // 提前的分钟数
+  const [newEventLunarLabel, setNewEventLunarLabel] = useState('');
+  const [notifications, setNotifications] = useState<AppNotification[]>([]);
   const [showNotifications, setShowNotifications] = useState(false);
   const [events, setEvents] = useState<CalendarEvent[]>([]);
 
@@ -160,16 +169,40 @@ const App: React.FC = () => {
     return date.toLocaleDateString(); // Simple date format
   };
   const [currentDate, setCurrentDate] = useState(new Date());
+  // 事件按日历所在年份的前后范围加载，年份变化时重新加载
+  const calendarYear = currentDate.getFullYear();
+
+  // 农历事件：显示所选日期对应的农历日期，每年按农历月日重复
+  useEffect(() => {
+    if (!showAddEvent || newEventDateType !== 'lunar') {
+      setNewEventLunarLabel('');
+      return;
+    }
+    const d = selectedDateForEvent;
+    const date = `${d.getFullYear()}-${String(d.getMonth() + 1).padStart(2, '0')}-${String(d.getDate()).padStart(2, '0')}`;
+    let cancelled = false;
+    api.lunarFromSolar(date)
+      .then(res => { if (!cancelled) setNewEventLunarLabel(res.label); })
+      .catch(() => { if (!cancelled) setNewEventLunarLabel(''); });
+    return () => { cancelled = true; };
+  }, [showAddEvent, newEventDateType, selectedDateForEvent]);
 
   // 从后端加载数据
   const loadDataFromBackend = useCallback(async () => {
     setIsDataLoading(true);
     try {
-      const [notesData, eventsData, familiesData] = await Promise.all([
+      const eventsStart = `${calendarYear - 1}-01-01`;
+      const eventsEnd = `${calendarYear + 1}-12-31`;
+      const [notesData, eventsData, familiesData, notificationsData] = await Promise.all([
         api.getNotes(),
-        api.getEvents(),
-        api.getMyFamilies()
+        api.getEvents(eventsStart, eventsEnd),
+        api.getMyFamilies(),
+        api.getNotifications().catch(e => {
+          console.error('Failed to load notifications', e);
+          return [] as AppNotification[];
+        })
       ]);
+      setNotifications(notificationsData);
 
       let allNotes = [...notesData];
       let allEvents = [...eventsData];
@@ -180,7 +213,7 @@ const App: React.FC = () => {
           try {
             const [fNotes, fEvents] = await Promise.all([
               api.getFamilyNotes(f.id),
-              api.getFamilyEvents(f.id)
+              api.getFamilyEvents(f.id, eventsStart, eventsEnd)
             ]);
             return {
               notes: fNotes.map((n: any) => ({ ...n, familyId: f.id })),
@@ -251,7 +284,7 @@ const App: React.FC = () => {
     } finally {
       setIsDataLoading(false);
     }
-  }, [activeFamilyId]);
+  }, [activeFamilyId, calendarYear]);
 
   const renderCalendar = () => {
     const year = currentDate.getFullYear();
@@ -303,8 +336,10 @@ const App: React.FC = () => {
           <div className="space-y-1 mt-1 px-1">
             {dayEvents.map(ev => (
               <div
-                key={ev.id}
+                key={`${ev.systemKey || ev.id}-${ev.occurrenceDate || ''}`}
                 className={`text-xs p-1 mb-1 rounded cursor-pointer transition-colors flex items-center gap-1 ${ev.type === 'lunar' ? 'bg-purple-50 text-purple-700 hover:bg-purple-100' :
+                  ev.type === 'holiday' ? (ev.workday ? 'bg-gray-100 text-gray-700' : 'bg-red-50 text-red-600') :
+                  ev.type === 'term' ? 'bg-green-50 text-green-700' :
                   (ev as any).isSystem ? 'bg-gray-100 text-gray-600' :
                     'bg-blue-50 text-blue-700 hover:bg-blue-100'
                   }`}
@@ -313,7 +348,7 @@ const App: React.FC = () => {
                   alert(`${ev.title}\nDate: ${new Date(ev.date).toLocaleDateString()}\nRecurrence: ${ev.recurrence}`);
                 }}
               >
-                <span className={`w-1.5 h-1.5 rounded-full ${ev.type === 'lunar' ? 'bg-purple-400' : 'bg-blue-400'}`}></span>
+                <span className={`w-1.5 h-1.5 rounded-full ${ev.type === 'lunar' ? 'bg-purple-400' : ev.type === 'holiday' ? (ev.workday ? 'bg-gray-400' : 'bg-red-400') : ev.type === 'term' ? 'bg-green-400' : 'bg-blue-400'}`}></span>
                 <span className="truncate flex-1 font-medium">{ev.title}</span>
                 {ev.showCountdown && <span className="text-[10px] text-notion-dim tabular-nums">
                   {Math.ceil((new Date(ev.date).getTime() - Date.now()) / (86400000))}d
@@ -347,6 +382,12 @@ const App: React.FC = () => {
             <span className="text-xs text-notion-dim flex items-center gap-1.5 px-2 py-1 bg-notion-sidebar/50 rounded">
               <div className="w-1.5 h-1.5 rounded-full bg-purple-400" /> Lunar
             </span>
+            <span className="text-xs text-notion-dim flex items-center gap-1.5 px-2 py-1 bg-notion-sidebar/50 rounded">
+              <div className="w-1.5 h-1.5 rounded-full bg-red-400" /> 节假日
+            </span>
+            <span className="text-xs text-notion-dim flex items-center gap-1.5 px-2 py-1 bg-notion-sidebar/50 rounded">
+              <div className="w-1.5 h-1.5 rounded-full bg-green-400" /> 节气
+            </span>
           </div>
         </div>
 
@@ -378,6 +419,29 @@ const App: React.FC = () => {
     setLoading(false);
   }, [loadDataFromBackend]);
 
+  // 实时推送：其他用户的修改、新评论、家庭成员变更和通知
+  const loadDataRef = useRef(loadDataFromBackend);
+  loadDataRef.current = loadDataFromBackend;
+  useEffect(() => {
+    if (!user) return;
+    let reloadTimer: ReturnType<typeof setTimeout> | undefined;
+    const unsubscribe = api.subscribeEvents((type, data) => {
+      if (type === 'notification') {
+        setNotifications(prev => prev.some(n => n.id === data.id) ? prev : [data, ...prev]);
+        return;
+      }
+      // 自己的操作已在本地更新；reset 表示错过的事件无法补发
+      if (type !== 'reset' && data.actorId === user.id) return;
+      // 短时间内的多个事件合并为一次重新加载
+      clearTimeout(reloadTimer);
+      reloadTimer = setTimeout(() => loadDataRef.current(), 500);
+    });
+    return () => {
+      clearTimeout(reloadTimer);
+      unsubscribe();
+    };
+  }, [user?.id]);
+
   const handleLogin = async (e: React.FormEvent) => {
     e.preventDefault();
     setAuthError(null);
@@ -457,6 +521,7 @@ const App: React.FC = () => {
     setFamilies([]);
     setActiveFamilyId(null);
     setFamilyMembers([]);
+    setNotifications([]);
   };
 
   const handleCreateEvent = async () => {
@@ -475,6 +540,7 @@ const App: React.FC = () => {
         type: newEventDateType,
         recurrence: newEventRecurrence,
         showCountdown: newEventShowCountdown,
+        reminders: newEventReminders,
       };
 
       const currentFamily = families.find(f => f.id === activeFolderId);
@@ -486,6 +552,7 @@ const App: React.FC = () => {
       await loadDataFromBackend();
       setShowAddEvent(false);
       setNewEventTitle('');
+      setNewEventReminders([]);
     } catch (e) {
       console.error(e);
       alert('Failed to save event');
@@ -648,6 +715,31 @@ const App: React.FC = () => {
     }
   };
 
+  // 点击通知：标记为已读，相关对象是笔记时跳转过去
+  const handleNotificationClick = async (notification: AppNotification) => {
+    if (!notification.isRead) {
+      setNotifications(prev => prev.map(n => n.id === notification.id ? { ...n, isRead: true } : n));
+      api.markNotificationRead(notification.id).catch(error => console.error('Failed to mark notification read:', error));
+    }
+    if (notification.targetType === 'note' && notification.targetId) {
+      handleNavigate(notification.targetId);
+      setShowNotifications(false);
+    } else if (notification.targetType === 'event') {
+      // 事件提醒：打开日历
+      setView('calendar');
+      setShowNotifications(false);
+    }
+  };
+
+  const handleMarkAllNotificationsRead = async () => {
+    try {
+      await api.markAllNotificationsRead();
+      setNotifications(prev => prev.map(n => ({ ...n, isRead: true })));
+    } catch (error) {
+      console.error('Failed to mark notifications read:', error);
+    }
+  };
+
   // 过滤笔记
   const filteredNotes = notes.filter(n => {
     const matchesSearch = n.title.toLowerCase().includes(searchQuery.toLowerCase()) ||
@@ -788,13 +880,21 @@ const App: React.FC = () => {
               </button>
               {showNotifications && (
                 <div className="absolute top-full left-0 mt-2 w-64 bg-white border border-notion-border rounded-lg shadow-xl z-50 p-2 animate-in fade-in zoom-in-95 origin-top-left">
-                  <h3 className="text-xs font-semibold text-notion-dim mb-2 uppercase px-2">Notifications</h3>
-                  {notifications.map(n => (
-                    <div key={n.id} className="p-2 hover:bg-notion-hover rounded cursor-pointer">
-                      <p className="text-sm font-medium text-notion-text">{n.title}</p>
-                      <p className="text-xs text-notion-dim">{n.message}</p>
-                    </div>
-                  ))}
+                  <div className="flex items-center justify-between mb-2 px-2">
+                    <h3 className="text-xs font-semibold text-notion-dim uppercase">Notifications</h3>
+                    {notifications.some(n => !n.isRead) && (
+                      <button onClick={handleMarkAllNotificationsRead} className="text-xs text-notion-dim hover:text-notion-text">全部已读</button>
+                    )}
+                  </div>
+                  {notifications.length === 0 && <p className="text-xs text-notion-dim px-2 py-1">暂无通知</p>}
+                  <div className="max-h-80 overflow-y-auto">
+                    {notifications.map(n => (
+                      <div key={n.id} onClick={() => handleNotificationClick(n)} className="p-2 hover:bg-notion-hover rounded cursor-pointer">
+                        <p className={`text-sm text-notion-text ${n.isRead ? '' : 'font-medium'}`}>{n.title}</p>
+                        {n.message && <p className="text-xs text-notion-dim line-clamp-2">{n.message}</p>}
+                      </div>
+                    ))}
+                  </div>
                 </div>
               )}
             </div>
@@ -977,10 +1077,18 @@ const App: React.FC = () => {
               <div className="grid grid-cols-2 gap-4">
                 <div>
                   <label className="block text-xs font-semibold text-notion-dim uppercase tracking-wider mb-1">Date Type</label>
-                  <select value={newEventDateType} onChange={e => setNewEventDateType(e.target.value as any)} className="w-full border border-notion-border rounded p-2 bg-white">
+                  <select value={newEventDateType} onChange={e => {
+                    const type = e.target.value as 'solar' | 'lunar';
+                    setNewEventDateType(type);
+                    // 农历事件只支持每年重复
+                    if (type === 'lunar' && newEventRecurrence !== 'none') setNewEventRecurrence('yearly');
+                  }} className="w-full border border-notion-border rounded p-2 bg-white">
                     <option value="solar">Solar (公历)</option>
                     <option value="lunar">Lunar (农历)</option>
                   </select>
+                  {newEventDateType === 'lunar' && newEventLunarLabel && (
+                    <div className="text-xs text-purple-600 mt-1">农历{newEventLunarLabel}</div>
+                  )}
                 </div>
                 <div>
                   <label className="block text-xs font-semibold text-notion-dim uppercase tracking-wider mb-1">Time</label>
@@ -992,13 +1100,32 @@ const App: React.FC = () => {
                 <label className="block text-xs font-semibold text-notion-dim uppercase tracking-wider mb-1">Recurrence</label>
                 <select value={newEventRecurrence} onChange={e => setNewEventRecurrence(e.target.value as any)} className="w-full border border-notion-border rounded p-2 bg-white">
                   <option value="none">One-time</option>
-                  <option value="daily">Daily</option>
-                  <option value="weekly">Weekly</option>
-                  <option value="monthly">Monthly</option>
+                  <option value="daily" disabled={newEventDateType === 'lunar'}>Daily</option>
+                  <option value="weekly" disabled={newEventDateType === 'lunar'}>Weekly</option>
+                  <option value="monthly" disabled={newEventDateType === 'lunar'}>Monthly</option>
                   <option value="yearly">Yearly</option>
                 </select>
               </div>
 
+              <div>
+                <label className="block text-xs font-semibold text-notion-dim uppercase tracking-wider mb-1">Reminders</label>
+                <div className="flex flex-wrap gap-2">
+                  {REMINDER_OPTIONS.map(opt => {
+                    const active = newEventReminders.includes(opt.minutes);
+                    return (
+                      <button
+                        key={opt.minutes}
+                        type="button"
+                        onClick={() => setNewEventReminders(prev => active ? prev.filter(m => m !== opt.minutes) : [...prev, opt.minutes])}
+                        className={`text-xs px-2 py-1 rounded border transition-colors ${active ? 'bg-blue-50 border-blue-300 text-blue-700' : 'border-notion-border text-notion-dim hover:bg-notion-hover'}`}
+                      >
+                        {opt.label}
+                      </button>
+                    );
+                  })}
+                </div>
+              </div>
+
               <div className="flex items-center justify-between p-3 bg-notion-sidebar rounded-lg">
                 <span className="text-sm font-medium">Show Countdown</span>
                 <input checked={newEventShowCountdown} onChange={e => setNewEventShowCountdown(e.target.checked)} type="checkbox" className="w-4 h-4 text-blue-600 rounded" />
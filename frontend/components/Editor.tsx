@@ -2,7 +2,7 @@ import React, { useState, useEffect, useRef } from 'react';
 import ReactMarkdown from 'react-markdown';
 import remarkGfm from 'remark-gfm';
 import rehypeRaw from 'rehype-raw';
-import { Note, ViewMode, Attachment, Comment, ShareConfig, User, Collaborator } from '../types';
+import { Note, ViewMode, Attachment, Comment, ShareConfig, User, Collaborator, ReactionCount } from '../types';
 import {
   Wand2, RefreshCw,
   Bold, Italic, List, Heading, Code, FileText, Trash2,
@@ -12,6 +12,7 @@ import {
 } from 'lucide-react';
 import { polishContent } from '../services/aiService';
 import { api } from '../services/api';
+import ReactionBar from './ReactionBar';
 
 // Helper for file size
 const formatBytes = (bytes: number, decimals = 2) => {
@@ -48,6 +49,7 @@ const Editor: React.FC<EditorProps> = ({ note, notes, onUpdate, onNavigate, onDe
   const [isSaving, setIsSaving] = useState(false);
   const [attachments, setAttachments] = useState<Attachment[]>([]);
   const [comments, setComments] = useState<Comment[]>([]);
+  const [reactions, setReactions] = useState<ReactionCount[]>([]);
   const [shareConfig, setShareConfig] = useState<ShareConfig>({ isPublic: false, publicPermission: 'read', collaborators: [] });
 
   // UI States
@@ -59,6 +61,7 @@ const Editor: React.FC<EditorProps> = ({ note, notes, onUpdate, onNavigate, onDe
 
   const [newComment, setNewComment] = useState('');
   const [activeQuote, setActiveQuote] = useState('');
+  const [activeQuoteStart, setActiveQuoteStart] = useState<number | undefined>(undefined);
 
   const textareaRef = useRef<HTMLTextAreaElement>(null);
   // Scroll Sync Refs
@@ -115,6 +118,7 @@ const Editor: React.FC<EditorProps> = ({ note, notes, onUpdate, onNavigate, onDe
         setTitle(note.title);
         setAttachments(note.attachments || []);
         setComments(note.comments || []);
+        setReactions(note.reactions || []);
         setShareConfig(note.shareConfig || { isPublic: false, publicPermission: 'read', collaborators: [] });
 
         latestNoteRef.current = { ...note };
@@ -126,6 +130,7 @@ const Editor: React.FC<EditorProps> = ({ note, notes, onUpdate, onNavigate, onDe
 
         setAttachments(note.attachments || []);
         setComments(note.comments || []);
+        setReactions(note.reactions || []);
         setShareConfig(note.shareConfig || { isPublic: false, publicPermission: 'read', collaborators: [] });
 
         // Update ref with new external data, but PRESERVE local title/content
@@ -146,6 +151,7 @@ const Editor: React.FC<EditorProps> = ({ note, notes, onUpdate, onNavigate, onDe
       setTitle('');
       setAttachments([]);
       setComments([]);
+      setReactions([]);
       setShareConfig({ isPublic: false, publicPermission: 'read', collaborators: [] });
       latestNoteRef.current = null;
       prevNoteIdRef.current = null;
@@ -275,8 +281,11 @@ const Editor: React.FC<EditorProps> = ({ note, notes, onUpdate, onNavigate, onDe
       const selection = textarea.value.substring(textarea.selectionStart, textarea.selectionEnd);
       if (selection) {
         setActiveQuote(selection);
+        // 后端按 Unicode 字符计算偏移
+        setActiveQuoteStart(Array.from(textarea.value.substring(0, textarea.selectionStart)).length);
       } else {
         setActiveQuote('');
+        setActiveQuoteStart(undefined);
       }
     }
     setContextMenu({ x: e.clientX, y: e.clientY });
@@ -291,7 +300,7 @@ const Editor: React.FC<EditorProps> = ({ note, notes, onUpdate, onNavigate, onDe
     if (!newComment.trim() || !note) return;
 
     try {
-      const response = await api.addComment(note.id, newComment, activeQuote);
+      const response = await api.addComment(note.id, newComment, activeQuote, undefined, activeQuoteStart);
 
       const comment: Comment = {
         id: response.id, // Use real ID
@@ -299,6 +308,8 @@ const Editor: React.FC<EditorProps> = ({ note, notes, onUpdate, onNavigate, onDe
         username: response.username,
         content: response.content,
         quotedText: activeQuote,
+        anchorStart: response.anchorStart,
+        anchorEnd: response.anchorEnd,
         createdAt: new Date(response.createdAt).toISOString()
       };
 
@@ -314,6 +325,23 @@ const Editor: React.FC<EditorProps> = ({ note, notes, onUpdate, onNavigate, onDe
     }
   };
 
+  // --- Reactions ---
+  const toggleReaction = async (emoji: string, reacted: boolean, commentId?: string) => {
+    if (!note) return;
+    try {
+      const { reactions: updated } = reacted
+        ? await api.removeReaction(note.id, emoji, commentId)
+        : await api.addReaction(note.id, emoji, commentId);
+      if (commentId) {
+        setComments(prev => prev.map(c => c.id === commentId ? { ...c, reactions: updated } : c));
+      } else {
+        setReactions(updated);
+      }
+    } catch (e) {
+      console.error('Failed to update reaction:', e);
+    }
+  };
+
   const applyColor = (color: string) => {
     insertText(`<span style="color: ${color}">`, `</span>`);
     setContextMenu(null);
@@ -395,18 +423,23 @@ const Editor: React.FC<EditorProps> = ({ note, notes, onUpdate, onNavigate, onDe
   };
 
   const handleFileUpload = async (e: React.ChangeEvent<HTMLInputElement>) => {
-    if (e.target.files && e.target.files[0]) {
+    if (e.target.files && e.target.files[0] && note) {
       const file = e.target.files[0];
       try {
-        const result = await api.uploadFile(file);
+        // 大文件分块上传，网络中断时可续传
+        const result = file.size > 5 * 1024 * 1024
+          ? await api.uploadFileResumable(note.id, file)
+          : await api.uploadFile(note.id, file);
+        // 下载地址需要登录，预览使用短期签名链接
+        const signed = await api.getAttachmentSignedUrl(result.id);
 
         const newAttachment: Attachment = {
-          id: Date.now().toString(), // Helper ID for React Key (Backend ID not critical for display list)
-          name: result.filename,
-          type: file.type,
+          id: result.id,
+          name: result.name,
+          type: result.type,
           size: result.size,
-          data: `http://localhost:8080${result.url}`, // Full URL
-          createdAt: new Date().toISOString()
+          data: `http://localhost:8080${signed.url}`, // Full URL
+          createdAt: result.createdAt
         };
 
         const newAttachments = [...attachments, newAttachment];
@@ -414,7 +447,7 @@ const Editor: React.FC<EditorProps> = ({ note, notes, onUpdate, onNavigate, onDe
         if (note) onUpdate({ ...note, attachments: newAttachments });
       } catch (error) {
         console.error("Upload error:", error);
-        alert("Upload failed");
+        alert(error instanceof Error ? error.message : "Upload failed");
       }
       e.target.value = ''; // Reset input
     }
@@ -614,8 +647,11 @@ const Editor: React.FC<EditorProps> = ({ note, notes, onUpdate, onNavigate, onDe
                 onChange={(e) => setTitle(e.target.value)}
                 onBlur={() => handleSave()}
                 placeholder="Untitled"
-                className="w-full text-4xl md:text-5xl font-bold text-notion-text placeholder:text-notion-dim/30 bg-transparent border-none focus:outline-none mb-6 leading-tight"
+                className="w-full text-4xl md:text-5xl font-bold text-notion-text placeholder:text-notion-dim/30 bg-transparent border-none focus:outline-none mb-2 leading-tight"
               />
+              <div className="mb-4">
+                <ReactionBar reactions={reactions} onToggle={(emoji, reacted) => toggleReaction(emoji, reacted)} />
+              </div>
 
               {/* Toolbar */}
               <div className="flex items-center gap-2 mb-4 pb-2 border-b border-notion-border sticky top-[-20px] bg-white z-10 opacity-60 hover:opacity-100 transition-opacity">
@@ -671,7 +707,16 @@ const Editor: React.FC<EditorProps> = ({ note, notes, onUpdate, onNavigate, onDe
                             <Download className="w-4 h-4" />
                           </a>
                           <button
-                            onClick={() => {
+                            onClick={async () => {
+                              if (note) {
+                                try {
+                                  await api.deleteAttachment(note.id, file.id);
+                                } catch (error) {
+                                  console.error("Delete attachment error:", error);
+                                  alert("Failed to delete attachment");
+                                  return;
+                                }
+                              }
                               const newAttachments = attachments.filter(a => a.id !== file.id);
                               setAttachments(newAttachments);
                               if (note) onUpdate({ ...note, attachments: newAttachments });
@@ -773,11 +818,14 @@ const Editor: React.FC<EditorProps> = ({ note, notes, onUpdate, onNavigate, onDe
                     <span className="text-[10px] text-notion-dim ml-auto">{new Date(c.createdAt).toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' })}</span>
                   </div>
                   {c.quotedText && (
-                    <div className="mb-2 pl-2 border-l-2 border-blue-200 text-xs text-notion-dim italic truncate">
+                    <div className={`mb-2 pl-2 border-l-2 text-xs text-notion-dim italic truncate ${c.orphaned ? 'border-gray-200 line-through' : 'border-blue-200'}`}>
                       "{c.quotedText}"
                     </div>
                   )}
                   <p className="text-sm text-notion-text">{c.content}</p>
+                  <div className="mt-2">
+                    <ReactionBar size="sm" reactions={c.reactions} onToggle={(emoji, reacted) => toggleReaction(emoji, reacted, c.id)} />
+                  </div>
                 </div>
               ))}
             </div>
@@ -0,0 +1,53 @@
+import React, { useState } from 'react';
+import { SmilePlus } from 'lucide-react';
+import { ReactionCount } from '../types';
+
+const QUICK_EMOJIS = ['👍', '❤️', '🎉', '😄', '👀', '🙏'];
+
+interface ReactionBarProps {
+  reactions?: ReactionCount[];
+  // reacted 为 true 时取消回应
+  onToggle: (emoji: string, reacted: boolean) => void;
+  size?: 'sm' | 'md';
+}
+
+// 表情回应：已有的表情及人数，点击切换；"+" 打开常用表情
+const ReactionBar: React.FC<ReactionBarProps> = ({ reactions = [], onToggle, size = 'md' }) => {
+  const [showPicker, setShowPicker] = useState(false);
+  const chip = size === 'sm' ? 'text-[11px] px-1.5 py-0' : 'text-xs px-2 py-0.5';
+
+  return (
+    <div className="flex flex-wrap items-center gap-1 relative">
+      {reactions.map(r => (
+        <button
+          key={r.emoji}
+          onClick={() => onToggle(r.emoji, r.reacted)}
+          className={`${chip} rounded-full border transition-colors ${r.reacted ? 'bg-blue-50 border-blue-200 text-blue-600' : 'bg-white border-notion-border text-notion-dim hover:bg-notion-hover'}`}
+        >
+          {r.emoji} {r.count}
+        </button>
+      ))}
+      <button onClick={() => setShowPicker(!showPicker)} className="p-0.5 rounded text-notion-dim hover:text-notion-text hover:bg-notion-hover" title="Add reaction">
+        <SmilePlus className={size === 'sm' ? 'w-3 h-3' : 'w-4 h-4'} />
+      </button>
+      {showPicker && (
+        <div className="absolute top-full left-0 mt-1 flex gap-0.5 bg-white border border-notion-border rounded-lg shadow-lg p-1 z-20">
+          {QUICK_EMOJIS.map(emoji => (
+            <button
+              key={emoji}
+              onClick={() => {
+                setShowPicker(false);
+                onToggle(emoji, reactions.some(r => r.emoji === emoji && r.reacted));
+              }}
+              className="w-7 h-7 rounded hover:bg-notion-hover text-base"
+            >
+              {emoji}
+            </button>
+          ))}
+        </div>
+      )}
+    </div>
+  );
+};
+
+export default ReactionBar;
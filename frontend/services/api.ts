@@ -1,4 +1,4 @@
-import { Note, CalendarEvent } from '../types';
+import { Note, CalendarEvent, Comment, AppNotification, ReactionCount, LunarDate } from '../types';
 
 const API_BASE = 'http://localhost:8080/api';
 
@@ -30,6 +30,20 @@ async function request<T>(endpoint: string, options?: RequestInit): Promise<T> {
     return response.json();
 }
 
+const STREAM_EVENT_TYPES = [
+    'note.created', 'note.updated', 'note.deleted', 'note.removed', 'comment.created',
+    'family.member_joined', 'family.member_left', 'notification', 'reset'
+] as const;
+
+export type StreamEventType = typeof STREAM_EVENT_TYPES[number];
+
+// 事件查询的时间范围参数，带上浏览器时区以便按本地日期展开重复
+const eventRangeQuery = (start?: string, end?: string) => {
+    if (!start || !end) return '';
+    const tz = Intl.DateTimeFormat().resolvedOptions().timeZone;
+    return `?start=${start}&end=${end}&tz=${encodeURIComponent(tz)}`;
+};
+
 export const api = {
     // Auth - 登录
     login: async (username: string, password: string) => {
@@ -56,14 +70,25 @@ export const api = {
     },
 
     // Events - 日历事件
-    getEvents: async () => {
-        return request<CalendarEvent[]>('/events');
+    // 指定 start / end（YYYY-MM-DD）时重复事件按范围展开为每一次
+    getEvents: async (start?: string, end?: string) => {
+        return request<CalendarEvent[]>(`/events${eventRangeQuery(start, end)}`);
     },
 
+    // 事件的时区默认为浏览器时区，重复按它展开
     createEvent: async (event: Partial<CalendarEvent>) => {
+        const tzid = Intl.DateTimeFormat().resolvedOptions().timeZone;
         return request<CalendarEvent>('/events', {
             method: 'POST',
-            body: JSON.stringify(event),
+            body: JSON.stringify({ tzid, ...event }),
+        });
+    },
+
+    // PATCH 只修改提供的字段；notifyUsers 为 JSON 数组字符串
+    updateEvent: async (id: string | number, changes: Partial<Omit<CalendarEvent, 'notifyUsers'>> & { notifyUsers?: string }) => {
+        return request<CalendarEvent>(`/events/${id}`, {
+            method: 'PATCH',
+            body: JSON.stringify(changes),
         });
     },
 
@@ -133,12 +158,32 @@ export const api = {
         return request<Note[]>(`/family/${familyId}/notes`);
     },
 
-    getFamilyEvents: async (familyId: string) => {
-        return request<CalendarEvent[]>(`/family/${familyId}/events`);
+    getFamilyEvents: async (familyId: string, start?: string, end?: string) => {
+        return request<CalendarEvent[]>(`/family/${familyId}/events${eventRangeQuery(start, end)}`);
+    },
+
+    // 农历 - 公历日期（YYYY-MM-DD）转农历
+    lunarFromSolar: async (date: string) => {
+        return request<LunarDate>(`/lunar/from-solar?date=${date}`);
+    },
+
+    // 单独修改重复事件的一次，occurrenceDate 为该次原本的时间
+    updateEventOccurrence: async (id: string | number, occurrenceDate: string, changes: { title?: string; description?: string; date?: string }) => {
+        return request<CalendarEvent>(`/events/${id}/occurrences/${encodeURIComponent(occurrenceDate)}`, {
+            method: 'PUT',
+            body: JSON.stringify(changes),
+        });
+    },
+
+    // 取消重复事件的一次
+    cancelEventOccurrence: async (id: string | number, occurrenceDate: string) => {
+        return request<CalendarEvent>(`/events/${id}/occurrences/${encodeURIComponent(occurrenceDate)}`, {
+            method: 'DELETE',
+        });
     },
 
     // Extra - 附件与评论
-    uploadFile: async (file: File) => {
+    uploadFile: async (noteId: string, file: File) => {
         const formData = new FormData();
         formData.append('file', file);
 
@@ -147,24 +192,234 @@ export const api = {
         const headers: Record<string, string> = {};
         if (token) headers['Authorization'] = `Bearer ${token}`;
 
-        const response = await fetch(`${API_BASE}/upload`, {
+        const response = await fetch(`${API_BASE}/notes/${noteId}/attachments`, {
             method: 'POST',
             body: formData,
             headers
         });
 
-        if (!response.ok) throw new Error('Upload failed');
-        return response.json() as Promise<{ url: string; filename: string; size: number }>;
+        if (!response.ok) {
+            // 413 超出大小/配额、415 类型不允许，后端返回具体原因
+            const body = await response.json().catch(() => ({}));
+            throw new Error(body.error || 'Upload failed');
+        }
+        return response.json() as Promise<{ id: string; name: string; type: string; url: string; size: number; createdAt: string }>;
     },
 
-    addComment: async (noteId: string, content: string, quotedText?: string) => {
-        return request<{ id: string; content: string; username: string; createdAt: string }>(`/notes/${noteId}/comments`, {
+    // 大文件使用 tus 协议分块上传，网络中断后从已上传的位置继续
+    uploadFileResumable: async (noteId: string, file: File, onProgress?: (uploaded: number, total: number) => void) => {
+        const CHUNK_SIZE = 5 * 1024 * 1024;
+        const MAX_RETRIES = 5;
+        const token = getToken();
+        const headers: Record<string, string> = { 'Tus-Resumable': '1.0.0' };
+        if (token) headers['Authorization'] = `Bearer ${token}`;
+
+        const fail = async (response: Response) => {
+            const body = await response.json().catch(() => ({}));
+            return new Error(body.error || `HTTP Error ${response.status}`);
+        };
+
+        // 同一文件再次上传时复用之前的会话
+        const storageKey = `gonote_upload:${noteId}:${file.name}:${file.size}:${file.lastModified}`;
+        let uploadUrl = localStorage.getItem(storageKey);
+        let offset = -1;
+        if (uploadUrl) {
+            const head = await fetch(uploadUrl, { method: 'HEAD', headers }).catch(() => null);
+            if (head?.ok) {
+                offset = Number(head.headers.get('Upload-Offset'));
+            } else {
+                localStorage.removeItem(storageKey);
+            }
+        }
+        if (offset < 0) {
+            const utf8 = (s: string) => btoa(String.fromCharCode(...new TextEncoder().encode(s)));
+            const response = await fetch(`${API_BASE}/uploads`, {
+                method: 'POST',
+                headers: {
+                    ...headers,
+                    'Upload-Length': String(file.size),
+                    'Upload-Metadata': `filename ${utf8(file.name)},noteId ${utf8(noteId)}`,
+                },
+            });
+            if (!response.ok) throw await fail(response);
+            const id = response.headers.get('Location')!.split('/').pop();
+            uploadUrl = `${API_BASE}/uploads/${id}`;
+            localStorage.setItem(storageKey, uploadUrl);
+            offset = Number(response.headers.get('Upload-Offset'));
+        }
+
+        let retries = 0;
+        while (offset < file.size) {
+            const response = await fetch(uploadUrl!, {
+                method: 'PATCH',
+                headers: {
+                    ...headers,
+                    'Content-Type': 'application/offset+octet-stream',
+                    'Upload-Offset': String(offset),
+                },
+                body: file.slice(offset, offset + CHUNK_SIZE),
+            }).catch(() => null);
+
+            if (response?.ok) {
+                offset = Number(response.headers.get('Upload-Offset'));
+                retries = 0;
+                onProgress?.(offset, file.size);
+                continue;
+            }
+            // 网络中断、偏移不一致和服务端错误时重试；类型、配额、权限等校验错误直接失败
+            const retryable = !response || response.status === 400 || response.status === 409 || response.status >= 500;
+            if (!retryable) {
+                localStorage.removeItem(storageKey);
+                throw await fail(response);
+            }
+            if (++retries > MAX_RETRIES) {
+                throw new Error('Upload failed');
+            }
+            await new Promise(resolve => setTimeout(resolve, 1000 * retries));
+            // 出错后向服务端查询实际已接收的字节数
+            const head = await fetch(uploadUrl!, { method: 'HEAD', headers }).catch(() => null);
+            if (head?.ok) offset = Number(head.headers.get('Upload-Offset'));
+        }
+
+        localStorage.removeItem(storageKey);
+        const status = await fetch(uploadUrl!, { headers });
+        if (!status.ok) throw await fail(status);
+        const session = await status.json();
+        return session.attachment as { id: string; name: string; type: string; url: string; size: number; createdAt: string };
+    },
+
+    deleteAttachment: async (noteId: string, attachmentId: string) => {
+        return request<{ message: string }>(`/notes/${noteId}/attachments/${attachmentId}`, {
+            method: 'DELETE',
+        });
+    },
+
+    getAttachmentSignedUrl: async (attachmentId: string) => {
+        return request<{ url: string; expiresAt: string }>(`/attachments/${attachmentId}/signed-url`);
+    },
+
+    addComment: async (noteId: string, content: string, quotedText?: string, parentId?: string, anchorStart?: number) => {
+        return request<Comment>(`/notes/${noteId}/comments`, {
             method: 'POST',
-            body: JSON.stringify({ content, quotedText })
+            body: JSON.stringify({ content, quotedText, parentId, anchorStart })
         });
     },
 
+    // 按讨论串获取评论，resolved 为 true / false 时只返回已解决 / 未解决的讨论
+    getComments: async (noteId: string, resolved?: boolean) => {
+        const query = resolved === undefined ? '' : `?resolved=${resolved}`;
+        return request<Comment[]>(`/notes/${noteId}/comments${query}`);
+    },
+
+    updateComment: async (noteId: string, commentId: string, content: string) => {
+        return request<Comment>(`/notes/${noteId}/comments/${commentId}`, {
+            method: 'PUT',
+            body: JSON.stringify({ content })
+        });
+    },
+
+    deleteComment: async (noteId: string, commentId: string) => {
+        return request<{ message: string }>(`/notes/${noteId}/comments/${commentId}`, {
+            method: 'DELETE',
+        });
+    },
+
+    resolveComment: async (noteId: string, commentId: string, resolved: boolean) => {
+        return request<Comment>(`/notes/${noteId}/comments/${commentId}/resolve`, {
+            method: 'PUT',
+            body: JSON.stringify({ resolved })
+        });
+    },
+
+    // ========== 表情回应 ==========
+
+    // commentId 为空时回应笔记本身，返回该对象最新的回应统计
+    addReaction: async (noteId: string, emoji: string, commentId?: string) => {
+        const base = commentId ? `/notes/${noteId}/comments/${commentId}` : `/notes/${noteId}`;
+        return request<{ reactions: ReactionCount[] }>(`${base}/reactions`, {
+            method: 'POST',
+            body: JSON.stringify({ emoji })
+        });
+    },
+
+    removeReaction: async (noteId: string, emoji: string, commentId?: string) => {
+        const base = commentId ? `/notes/${noteId}/comments/${commentId}` : `/notes/${noteId}`;
+        return request<{ reactions: ReactionCount[] }>(`${base}/reactions/${encodeURIComponent(emoji)}`, { method: 'DELETE' });
+    },
+
     searchUsers: async (query: string) => {
         return request<{ id: string; username: string; avatarColor: string }[]>(`/users/search?q=${encodeURIComponent(query)}`);
+    },
+
+    // ========== 通知 ==========
+
+    getNotifications: async (options: { unread?: boolean; limit?: number; before?: string } = {}) => {
+        const params = new URLSearchParams();
+        if (options.unread) params.set('unread', 'true');
+        if (options.limit) params.set('limit', String(options.limit));
+        if (options.before) params.set('before', options.before);
+        const query = params.toString();
+        return request<AppNotification[]>(`/notifications${query ? `?${query}` : ''}`);
+    },
+
+    getUnreadNotificationCount: async () => {
+        return request<{ count: number }>('/notifications/unread-count');
+    },
+
+    markNotificationRead: async (id: string) => {
+        return request<AppNotification>(`/notifications/${id}/read`, { method: 'PUT' });
+    },
+
+    markAllNotificationsRead: async () => {
+        return request<{ updated: number }>('/notifications/read-all', { method: 'PUT' });
+    },
+
+    // ========== 实时推送 ==========
+
+    // 订阅服务端推送（SSE）；返回取消订阅的函数。
+    // EventSource 无法设置 Authorization 头，先用登录 Token 换取短期有效的事件流票据。
+    // 票据只在建立连接时有效，断线后重新换票据，并带上 lastEventId 补发错过的事件
+    subscribeEvents: (onEvent: (type: StreamEventType, data: any) => void) => {
+        let source: EventSource | null = null;
+        let lastEventId = '';
+        let closed = false;
+        let retry: ReturnType<typeof setTimeout> | undefined;
+
+        const reconnect = () => {
+            if (!closed) retry = setTimeout(connect, 3000);
+        };
+        const connect = async () => {
+            if (closed || !getToken()) return;
+            try {
+                const { ticket } = await request<{ ticket: string; expiresAt: string }>('/stream/ticket', { method: 'POST' });
+                if (closed) return;
+                const params = new URLSearchParams({ ticket });
+                if (lastEventId) params.set('lastEventId', lastEventId);
+                const es = new EventSource(`${API_BASE}/stream?${params}`);
+                STREAM_EVENT_TYPES.forEach(type => {
+                    es.addEventListener(type, e => {
+                        const message = e as MessageEvent;
+                        if (message.lastEventId) lastEventId = message.lastEventId;
+                        onEvent(type, JSON.parse(message.data));
+                    });
+                });
+                es.onerror = () => {
+                    // 浏览器自动重连会用到已过期的票据，改为自己重连
+                    es.close();
+                    source = null;
+                    reconnect();
+                };
+                source = es;
+            } catch {
+                reconnect();
+            }
+        };
+
+        connect();
+        return () => {
+            closed = true;
+            clearTimeout(retry);
+            source?.close();
+        };
     }
 };
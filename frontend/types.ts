@@ -14,6 +14,22 @@ export interface Comment {
   content: string;
   quotedText?: string;
   createdAt: string; // ISO String
+  parentId?: string; // 回复所属的主题评论
+  anchorStart?: number; // 引用文本在笔记中的位置（Unicode 字符偏移）
+  anchorEnd?: number;
+  orphaned?: boolean; // 引用的文本已被删除
+  editedAt?: string;
+  resolved?: boolean; // 仅主题评论
+  resolvedBy?: string;
+  resolvedAt?: string;
+  replies?: Comment[]; // GET /notes/:id/comments 返回
+  reactions?: ReactionCount[];
+}
+
+export interface ReactionCount {
+  emoji: string;
+  count: number;
+  reacted: boolean; // 当前用户是否回应过
 }
 
 export interface Collaborator {
@@ -40,6 +56,7 @@ export interface Note {
   attachments?: Attachment[];
   comments?: Comment[];
   shareConfig?: ShareConfig;
+  reactions?: ReactionCount[];
   createdAt: string; // ISO String
   updatedAt: string; // ISO String
 }
@@ -73,21 +90,43 @@ export interface ArchitectureTip {
 }
 
 export type RecurrenceType = 'none' | 'daily' | 'weekly' | 'monthly' | 'yearly';
-export type CalendarType = 'solar' | 'lunar';
+export type CalendarType = 'solar' | 'lunar' | 'holiday' | 'term'; // holiday、term 仅用于内置的系统事件
 
 export interface CalendarEvent {
   id: string;
   title: string;
   date: string; // ISO String
+  tzid?: string; // 事件的时区（IANA），重复按该时区展开
   type: CalendarType;
   recurrence: RecurrenceType;
+  rrule?: string; // RFC 5545 RRULE, e.g. FREQ=WEEKLY;BYDAY=MO,WE
+  exdate?: string; // 取消的重复，逗号分隔的 UTC 时间
+  occurrenceDate?: string; // 按范围查询时该次重复原本的时间
+  overridden?: boolean;
+  lunarMonth?: number; // 农历事件的农历月日
+  lunarDay?: number;
+  lunarLeap?: boolean;
+  systemKey?: string; // 内置节气、节假日的标识，这些事件不落库，id 为 0
+  workday?: boolean; // 调休上班日
   notifyUsers: string[];
   showCountdown: boolean;
+  reminders?: number[]; // 提前多少分钟提醒，如 [1440, 60]
   description?: string;
   familyId?: string;
   isSystem?: boolean;
 }
 
+export interface LunarDate {
+  year: number;
+  month: number;
+  day: number;
+  leap: boolean;
+  monthName: string; // 如 闰四月
+  dayName: string; // 如 初十
+  label: string;
+  solar: string; // YYYY-MM-DD
+}
+
 export interface AppNotification {
   id: string;
   userId: string;
@@ -95,7 +134,11 @@ export interface AppNotification {
   message: string;
   isRead: boolean;
   createdAt: string; // ISO String
-  type: 'reminder' | 'system' | 'mention';
+  type: 'comment' | 'collaborator' | 'family' | 'reminder' | 'mention' | 'reaction' | 'system';
+  actorId?: string;
+  targetType?: 'note' | 'event' | 'family';
+  targetId?: string;
+  readAt?: string;
 }
 
 export interface Family {